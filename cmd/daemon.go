@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/itocode21/backup-tool/pkg/api"
+	"github.com/itocode21/backup-tool/pkg/backup"
+	"github.com/itocode21/backup-tool/pkg/catalog"
+	"github.com/itocode21/backup-tool/pkg/config"
+	"github.com/itocode21/backup-tool/pkg/logging"
+	"github.com/itocode21/backup-tool/pkg/manifest"
+	"github.com/itocode21/backup-tool/pkg/progress"
+)
+
+// defaultDaemonInterval is used when daemon.interval is not set.
+const defaultDaemonInterval = 24 * time.Hour
+
+// runDaemon backs up targets every daemon.interval until SIGINT or SIGTERM.
+// A round in progress is finished before it returns.
+func runDaemon(cfg *config.Config, targets []config.Target, pool *backup.Pool, logger *logging.Logger) error {
+	interval := cfg.Daemon.Interval
+	if interval == 0 {
+		interval = defaultDaemonInterval
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	metrics := backup.NewMetrics()
+	pool.Progress = progress.Multi{pool.Progress, metrics}
+	if cfg.Daemon.Listen != "" {
+		listener, err := net.Listen("tcp", cfg.Daemon.Listen)
+		if err != nil {
+			return err
+		}
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", metrics.Registry)
+		if cfg.Daemon.API.Enabled {
+			apiServer := newAPIServer(cfg, targets, pool, metrics, logger)
+			mux.Handle("/", apiServer.Handler())
+			defer apiServer.Wait()
+		}
+		server := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+		go server.Serve(listener)
+		defer func() {
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			server.Shutdown(shutdownCtx)
+		}()
+		logger.Info("Serving HTTP", "address", listener.Addr().String(), "api", cfg.Daemon.API.Enabled)
+	}
+	observeCatalogs(metrics, targets, logger)
+
+	logger.Info("Daemon started", "interval", interval, "targets", len(targets))
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		daemonRound(cfg, targets, pool, metrics, logger)
+		select {
+		case <-ctx.Done():
+			logger.Info("Daemon stopped")
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// daemonRound backs up every target once, then applies retention and
+// refreshes the catalog metrics.
+func daemonRound(cfg *config.Config, targets []config.Target, pool *backup.Pool, metrics *backup.Metrics, logger *logging.Logger) {
+	expanded, err := backup.ExpandTargets(targets, logger)
+	if err != nil {
+		logger.Error("Failed to discover databases", "error", err)
+		return
+	}
+
+	jobs := make([]backup.Job, 0, len(expanded))
+	for _, target := range expanded {
+		jobs = append(jobs, backup.Job{Target: target, Command: "backup", Params: target.Params("")})
+	}
+	results := pool.Run(jobs)
+	metrics.Observe(results, time.Now())
+	if failed := backup.Failed(results); failed > 0 {
+		logger.Warn("Backup round finished with failures", "failed", failed, "total", len(results))
+	}
+
+	policy := catalog.Policy{KeepLast: cfg.Daemon.KeepLast, KeepWithin: cfg.Daemon.KeepWithin}
+	if policy.KeepLast > 0 || policy.KeepWithin > 0 {
+		for _, target := range targets {
+			start := time.Now()
+			removed, err := pruneTarget(target, policy, false, pool.Locker)
+			metrics.ObservePrune(removed)
+			if err != nil {
+				logger.Error("Failed to prune backups", "target", target.Name, "error", err)
+			}
+			if err := recordPrune(pool.History, target, start, removed, err); err != nil {
+				logger.Warn("Failed to record prune history", "target", target.Name, "error", err)
+			}
+		}
+	}
+	observeCatalogs(metrics, targets, logger)
+}
+
+func observeCatalogs(metrics *backup.Metrics, targets []config.Target, logger *logging.Logger) {
+	all := &catalog.Catalog{}
+	for _, target := range targets {
+		c, err := backup.LoadCatalog(target)
+		if err != nil {
+			logger.Error("Failed to scan catalog", "target", target.Name, "error", err)
+			continue
+		}
+		all.Manifests = append(all.Manifests, c.Manifests...)
+	}
+	metrics.ObserveCatalog(all)
+}
+
+// newAPIServer serves the HTTP API for the daemon's targets. Requests
+// select among them and are turned into jobs as CLI flags are.
+func newAPIServer(cfg *config.Config, targets []config.Target, pool *backup.Pool, metrics *backup.Metrics, logger *logging.Logger) *api.Server {
+	server := api.NewServer(cfg.Daemon.API.Token, pool, logger.With("phase", "api"))
+	server.Jobs = func(command string, req api.Request) ([]backup.Job, error) {
+		selected, err := config.SelectTargets(targets, config.ParseSelectors(req.Target))
+		if err != nil {
+			return nil, err
+		}
+		if req.BackupFile != "" {
+			for _, target := range selected {
+				if !insideStorage(target, req.BackupFile) {
+					return nil, fmt.Errorf("backup file must be under storage.local_path of target %s", target.Name)
+				}
+			}
+		}
+		return buildJobs(cfg, selected, jobOptions{
+			Command:       command,
+			BackupFile:    req.BackupFile,
+			Tables:        strings.Join(req.Tables, ","),
+			ExcludeTables: strings.Join(req.ExcludeTables, ","),
+			Kind:          req.Kind,
+			RestoreTo:     req.RestoreTo,
+			RestoreDBName: req.RestoreDBName,
+			NSMap:         strings.Join(req.NSMap, ","),
+			Force:         req.Force,
+		}, logger)
+	}
+	server.Backups = func(target string) ([]*manifest.Manifest, error) {
+		selected, err := config.SelectTargets(targets, config.ParseSelectors(target))
+		if err != nil {
+			return nil, err
+		}
+		var manifests []*manifest.Manifest
+		for _, t := range selected {
+			c, err := backup.LoadCatalog(t)
+			if err != nil {
+				return nil, err
+			}
+			manifests = append(manifests, c.Manifests...)
+		}
+		return manifests, nil
+	}
+	server.Finished = func(results []backup.Result) {
+		metrics.Observe(results, time.Now())
+	}
+	return server
+}
+
+// insideStorage reports whether path lies under the target's local storage,
+// so that API clients cannot read or overwrite arbitrary files through
+// backup_file.
+func insideStorage(target config.Target, path string) bool {
+	if target.Storage.LocalPath == "" {
+		return false
+	}
+	root, err := filepath.Abs(target.Storage.LocalPath)
+	if err != nil {
+		return false
+	}
+	path, err = filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+	rel, err := filepath.Rel(root, path)
+	return err == nil && rel != "." && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
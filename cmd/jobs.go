@@ -0,0 +1,175 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/itocode21/backup-tool/pkg/backup"
+	"github.com/itocode21/backup-tool/pkg/config"
+	"github.com/itocode21/backup-tool/pkg/database"
+	"github.com/itocode21/backup-tool/pkg/logging"
+	"github.com/itocode21/backup-tool/pkg/manifest"
+)
+
+// jobOptions are the settings of a backup or restore run, given as CLI
+// flags or in an HTTP API request.
+type jobOptions struct {
+	Command         string
+	BackupFile      string
+	Tables          string
+	ExcludeTables   string
+	Kind            string
+	IncrementalFrom string
+	Incrementals    string
+	RestoreTo       string
+	RestoreDBName   string
+	NSMap           string
+	Force           bool
+}
+
+// legacyBackupFile points a restore without a backup file at the engine's
+// own default path when nothing was backed up under storage.local_path:
+// before targets existed, backups ignored local_path and were written to
+// backups/<engine>/.
+func legacyBackupFile(params map[string]string, target config.Target, logger *logging.Logger) {
+	if params["backup-file"] != "" || params["backup-dir"] == "" {
+		return
+	}
+	current, err := database.ArtifactPath(target.Database.Type, params)
+	if err != nil || exists(current) || exists(manifest.Path(current)) {
+		return
+	}
+	legacy := make(map[string]string, len(params))
+	for key, value := range params {
+		legacy[key] = value
+	}
+	delete(legacy, "backup-dir")
+	old, err := database.ArtifactPath(target.Database.Type, legacy)
+	if err != nil || !exists(old) {
+		return
+	}
+	logger.Warn("No backup under storage.local_path, restoring from the old default path", "target", target.Name, "artifact", old)
+	params["backup-file"] = old
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// buildJobs checks opts against the selected targets, discovers their
+// databases and returns one job per database. Restores take the databases
+// from the stored backups rather than from the server.
+func buildJobs(cfg *config.Config, targets []config.Target, opts jobOptions, logger *logging.Logger) ([]backup.Job, error) {
+	if opts.Command != "backup" && opts.Command != "restore" {
+		return nil, fmt.Errorf("unknown command: %s", opts.Command)
+	}
+	if opts.BackupFile != "" && len(targets) > 1 {
+		return nil, fmt.Errorf("backup file can only be used with a single target, %d selected", len(targets))
+	}
+	if opts.Kind == "" {
+		opts.Kind = manifest.KindFull
+	}
+	if opts.Kind != manifest.KindFull {
+		if opts.Kind != manifest.KindIncremental && opts.Kind != manifest.KindDifferential {
+			return nil, fmt.Errorf("unknown backup kind: %s", opts.Kind)
+		}
+		if opts.Command != "backup" {
+			return nil, fmt.Errorf("backup kind can only be used with backup")
+		}
+		for _, target := range targets {
+			if !backup.IncrementalEngines[target.Database.Type] {
+				return nil, fmt.Errorf("target %s: %s backups are not supported for %s", target.Name, opts.Kind, target.Database.Type)
+			}
+		}
+	}
+	if opts.IncrementalFrom != "" || opts.Incrementals != "" {
+		if len(targets) != 1 || !backup.IncrementalEngines[targets[0].Database.Type] {
+			return nil, fmt.Errorf("incremental-from and incrementals can only be used with a single mariadb target")
+		}
+		if opts.IncrementalFrom != "" && opts.Command != "backup" {
+			return nil, fmt.Errorf("incremental-from can only be used with backup")
+		}
+		if opts.Incrementals != "" && opts.Command != "restore" {
+			return nil, fmt.Errorf("incrementals can only be used with restore")
+		}
+	}
+
+	expand := backup.ExpandTargets
+	if opts.Command == "restore" {
+		expand = backup.ExpandStoredTargets
+	}
+	targets, err := expand(targets, logger)
+	if err != nil {
+		return nil, fmt.Errorf("discover databases: %w", err)
+	}
+	if opts.BackupFile != "" && len(targets) > 1 {
+		return nil, fmt.Errorf("backup file can only be used with a single database, %d discovered", len(targets))
+	}
+
+	var destination *config.Target
+	if opts.RestoreTo != "" || opts.RestoreDBName != "" {
+		if opts.Command != "restore" {
+			return nil, fmt.Errorf("restore-to and restore-dbname can only be used with restore")
+		}
+		if len(targets) > 1 {
+			return nil, fmt.Errorf("restore-to and restore-dbname can only be used with a single target, %d selected", len(targets))
+		}
+	}
+	if opts.RestoreTo != "" {
+		destinations, err := config.SelectTargets(cfg.Targets(), []string{opts.RestoreTo})
+		if err != nil || len(destinations) != 1 {
+			return nil, fmt.Errorf("restore-to must name exactly one configured target: %s", opts.RestoreTo)
+		}
+		destination = &destinations[0]
+	}
+
+	jobs := make([]backup.Job, 0, len(targets))
+	for _, target := range targets {
+		params := target.Params(opts.BackupFile)
+		if opts.Tables != "" {
+			params["tables"] = opts.Tables
+		}
+		if opts.ExcludeTables != "" {
+			params["exclude-tables"] = opts.ExcludeTables
+		}
+		if opts.IncrementalFrom != "" {
+			params["incremental-base"] = opts.IncrementalFrom
+			params["backup-kind"] = manifest.KindIncremental
+		}
+		if opts.Incrementals != "" {
+			params["incrementals"] = opts.Incrementals
+		}
+		if opts.Command == "backup" && opts.Kind != manifest.KindFull {
+			params["backup-kind"] = opts.Kind
+			if opts.IncrementalFrom == "" {
+				if err := chooseParent(params, target, opts.Kind); err != nil {
+					return nil, fmt.Errorf("prepare %s backup of target %s: %w", opts.Kind, target.Name, err)
+				}
+			}
+		}
+		if opts.Command == "restore" {
+			if opts.Incrementals == "" && backup.KeepsEveryBackup(target) {
+				if err := resolveChain(params, target); err != nil {
+					return nil, fmt.Errorf("resolve backup chain of target %s: %w", target.Name, err)
+				}
+			}
+			legacyBackupFile(params, target, logger)
+			if err := redirectRestore(params, target, destination, opts.RestoreDBName); err != nil {
+				return nil, fmt.Errorf("prepare restore of target %s: %w", target.Name, err)
+			}
+			if opts.NSMap != "" {
+				params["ns-map"] = opts.NSMap
+			}
+			if opts.Force {
+				params["force"] = "true"
+			}
+		}
+		jobs = append(jobs, backup.Job{
+			Target:  target,
+			Command: opts.Command,
+			Params:  params,
+		})
+	}
+	return jobs, nil
+}
@@ -1,30 +1,63 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"log"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
+	"github.com/itocode21/backup-tool/pkg/backup"
+	"github.com/itocode21/backup-tool/pkg/catalog"
 	"github.com/itocode21/backup-tool/pkg/config"
 	"github.com/itocode21/backup-tool/pkg/database"
+	"github.com/itocode21/backup-tool/pkg/history"
+	"github.com/itocode21/backup-tool/pkg/lock"
 	"github.com/itocode21/backup-tool/pkg/logging"
+	"github.com/itocode21/backup-tool/pkg/manifest"
+	"github.com/itocode21/backup-tool/pkg/progress"
+	"github.com/itocode21/backup-tool/pkg/throttle"
 )
 
+// barInterval is how often progress bars are redrawn.
+const barInterval = 500 * time.Millisecond
+
 func main() {
 	configPath := flag.String("config", "", "Path to the configuration file (required)")
-	dbType := flag.String("type", "", "Database type (mysql|postgresql|mongodb) (required)")
-	command := flag.String("command", "", "Command to execute (backup|restore) (required)")
-	backupFile := flag.String("backup-file", "", "Path to the backup file (optional for restore/backup)")
+	dbType := flag.String("type", "", "Database type (mysql|mariadb|postgresql|mongodb|sqlite|redis), limits the run to targets of this type")
+	command := flag.String("command", "", "Command to execute (backup|restore|list|prune|history|daemon) (required)")
+	backupFile := flag.String("backup-file", "", "Path to the backup file (optional for restore/backup, single target only)")
+	targetFlag := flag.String("target", "", "Comma-separated target names, globs or label=value selectors (default: all targets)")
+	tables := flag.String("tables", "", "Comma-separated tables (collections for MongoDB) to back up or to extract on restore")
+	excludeTables := flag.String("exclude-tables", "", "Comma-separated tables (collections for MongoDB) to skip")
+	restoreTo := flag.String("restore-to", "", "Restore into the connection and database of this configured target")
+	restoreDBName := flag.String("restore-dbname", "", "Restore into this database name instead of the target's own")
+	nsMap := flag.String("ns-map", "", "Comma-separated from:to renames applied on restore (MySQL databases, PostgreSQL schemas, MongoDB namespaces)")
+	force := flag.Bool("force", false, "Allow restoring over a database that already has data")
+	kind := flag.String("kind", manifest.KindFull, "Backup kind (full|incremental|differential); the parent is taken from the catalog")
+	keepLast := flag.Int("keep-last", 0, "Prune: keep this many newest backups of each target")
+	keepWithin := flag.Duration("keep-within", 0, "Prune: keep backups younger than this duration, e.g. 720h")
+	dryRun := flag.Bool("dry-run", false, "Prune: only print what would be removed")
+	incrementalFrom := flag.String("incremental-from", "", "Take an incremental backup relative to this artifact (MariaDB, single target only)")
+	incrementals := flag.String("incrementals", "", "Comma-separated incremental artifacts applied in order on top of --backup-file (MariaDB restore)")
+	status := flag.String("status", "", "History: only runs with this status (success|failure)")
+	since := flag.Duration("since", 0, "History: only runs started within this duration, e.g. 24h")
+	limit := flag.Int("limit", 0, "History: only the newest runs, at most this many")
+	historyCommand := flag.String("history-command", "", "History: only runs of this command (backup|restore|prune)")
+	jsonOutput := flag.Bool("json", false, "History: print runs as JSON")
+	workers := flag.Int("workers", 0, "Number of targets processed concurrently (overrides parallel.workers)")
+	perHost := flag.Int("per-host", 0, "Maximum concurrent jobs per database host (overrides parallel.per_host)")
 	flag.Parse()
 
-	if *configPath == "" || *dbType == "" || *command == "" {
-		log.Fatal("Missing required parameters: --config, --type, and --command are required.")
+	if *configPath == "" || *command == "" {
+		log.Fatal("Missing required parameters: --config and --command are required.")
 	}
 
 	fullConfigPath := *configPath
-	if !filepath.IsAbs(*configPath) {	
+	if !filepath.IsAbs(*configPath) {
 		workingDir, err := os.Getwd()
 		if err != nil {
 			log.Fatalf("Failed to get working directory: %v", err)
@@ -37,41 +70,354 @@ func main() {
 		log.Fatalf("Failed to load config: %v", err)
 	}
 
-	logger := logging.NewLogger(cfg)
+	// History records name discovered databases too, so --target is matched
+	// against the recorded names instead of the configured targets.
+	if *command == "history" {
+		filter := history.Filter{Target: *targetFlag, Command: *historyCommand, Status: *status, Limit: *limit}
+		if *since > 0 {
+			filter.Since = time.Now().Add(-*since)
+		}
+		if err := showHistory(cfg, filter, *jsonOutput); err != nil {
+			log.Fatalf("Failed to show history: %v", err)
+		}
+		return
+	}
 
-	backup, err := database.NewBackup(*dbType, logger)
+	targets, err := config.SelectTargets(cfg.Targets(), config.ParseSelectors(*targetFlag))
 	if err != nil {
-		log.Fatalf("Failed to create backup instance: %v", err)
+		log.Fatalf("Failed to select targets: %v", err)
+	}
+	if *dbType != "" {
+		targets = filterByType(targets, *dbType)
+		if len(targets) == 0 {
+			log.Fatalf("No targets of type %s selected", *dbType)
+		}
 	}
 
+	// Listing only reads the catalog, so it works without the lock path.
+	if *command == "list" {
+		if err := listBackups(targets); err != nil {
+			log.Fatalf("Failed to list backups: %v", err)
+		}
+		return
+	}
 	switch *command {
-	case "backup":
-		config := map[string]string{
-			"host":        cfg.Database.Host,
-			"port":        fmt.Sprintf("%d", cfg.Database.Port),
-			"username":    cfg.Database.Username,
-			"password":    cfg.Database.Password,
-			"dbname":      cfg.Database.DBName,
-			"backup-file": *backupFile,
-		}
-		if err := backup.PerformFullBackup(config); err != nil {
-			log.Fatalf("Backup failed: %v", err)
-		}
-		fmt.Println("Backup completed successfully.")
-	case "restore":
-		config := map[string]string{
-			"host":        cfg.Database.Host,
-			"port":        fmt.Sprintf("%d", cfg.Database.Port),
-			"username":    cfg.Database.Username,
-			"password":    cfg.Database.Password,
-			"dbname":      cfg.Database.DBName,
-			"backup-file": *backupFile,
-		}
-		if err := backup.RestoreBackup(config); err != nil {
-			log.Fatalf("Restore failed: %v", err)
-		}
-		fmt.Println("Restore completed successfully.")
+	case "backup", "restore", "prune", "daemon":
 	default:
 		log.Fatalf("Unknown command: %s", *command)
 	}
+
+	locker, err := backup.OpenLocker(cfg)
+	if err != nil {
+		log.Fatalf("Failed to set up locking: %v", err)
+	}
+
+	if *command == "prune" {
+		policy := catalog.Policy{KeepLast: *keepLast, KeepWithin: *keepWithin}
+		if err := pruneBackups(targets, policy, *dryRun, backup.OpenHistory(cfg), locker); err != nil {
+			log.Fatalf("Failed to prune backups: %v", err)
+		}
+		return
+	}
+
+	logger, err := logging.NewLogger(cfg)
+	if err != nil {
+		log.Fatalf("Failed to create logger: %v", err)
+	}
+	defer logger.Close()
+
+	pool := backup.NewPool(cfg.Parallel, logger)
+	if *workers > 0 {
+		pool.Workers = *workers
+	}
+	if *perHost > 0 {
+		pool.PerHost = *perHost
+	}
+	pool.History = backup.OpenHistory(cfg)
+	pool.Throttle = throttle.NewSchedule(cfg.Throttle)
+	pool.Sanity = cfg.Sanity
+	pool.Locker = locker
+	setupProgress(cfg, pool, logger)
+
+	if *command == "daemon" {
+		if *backupFile != "" || *kind != manifest.KindFull {
+			log.Fatal("--backup-file and --kind cannot be used with --command daemon")
+		}
+		if err := runDaemon(cfg, targets, pool, logger); err != nil {
+			log.Fatalf("Daemon failed: %v", err)
+		}
+		return
+	}
+
+	jobs, err := buildJobs(cfg, targets, jobOptions{
+		Command:         *command,
+		BackupFile:      *backupFile,
+		Tables:          *tables,
+		ExcludeTables:   *excludeTables,
+		Kind:            *kind,
+		IncrementalFrom: *incrementalFrom,
+		Incrementals:    *incrementals,
+		RestoreTo:       *restoreTo,
+		RestoreDBName:   *restoreDBName,
+		NSMap:           *nsMap,
+		Force:           *force,
+	}, logger)
+	if err != nil {
+		log.Fatalf("Failed to prepare jobs: %v", err)
+	}
+
+	results := pool.Run(jobs)
+	if err := backup.WriteSummary(os.Stdout, results); err != nil {
+		log.Printf("Failed to write summary: %v", err)
+	}
+
+	if failed := backup.Failed(results); failed > 0 {
+		log.Fatalf("%d of %d targets failed", failed, len(results))
+	}
+}
+
+// redirectRestore points a restore of source at another database. The
+// artifact path is pinned first because engines derive it from dbname, and
+// source-dbname tells them which names to rewrite.
+func redirectRestore(params map[string]string, source config.Target, destination *config.Target, dbname string) error {
+	if destination == nil && dbname == "" {
+		return nil
+	}
+
+	artifact, err := database.ArtifactPath(source.Database.Type, params)
+	if err != nil {
+		return err
+	}
+	params["backup-file"] = artifact
+	params["source-dbname"] = source.Database.DBName
+
+	if destination != nil {
+		if destination.Database.Type != source.Database.Type {
+			return fmt.Errorf("cannot restore a %s backup into %s target %s", source.Database.Type, destination.Database.Type, destination.Name)
+		}
+		connection := destination.Params("")
+		for _, key := range []string{"host", "port", "username", "password", "dbname", "data-dir"} {
+			params[key] = connection[key]
+		}
+	}
+	if dbname != "" {
+		params["dbname"] = dbname
+	}
+	return nil
+}
+
+// setupProgress draws progress bars when stdout is a terminal and logs
+// progress every progress.interval otherwise.
+func setupProgress(cfg *config.Config, pool *backup.Pool, logger *logging.Logger) {
+	if progress.IsTerminal(os.Stdout) {
+		bar := progress.NewBar(os.Stdout)
+		logger.WrapOutput(bar.Wrap)
+		pool.Progress = bar
+		pool.ProgressInterval = barInterval
+		return
+	}
+	pool.Progress = progress.Log{Logger: logger}
+	pool.ProgressInterval = cfg.Progress.Interval
+}
+
+// chooseParent sets the artifact a non-full backup of target builds on.
+func chooseParent(params map[string]string, target config.Target, kind string) error {
+	c, err := backup.LoadCatalog(target)
+	if err != nil {
+		return err
+	}
+	parent, err := c.Parent(target.Name, kind)
+	if err != nil {
+		return err
+	}
+	params["incremental-base"] = parent.Artifact
+	return nil
+}
+
+// resolveChain turns a restore of a non-full backup into a restore of its
+// whole chain. Without --backup-file the newest backup of the target in the
+// catalog is restored, since every backup of such targets has its own path.
+func resolveChain(params map[string]string, target config.Target) error {
+	c, err := backup.LoadCatalog(target)
+	if err != nil {
+		return err
+	}
+	m := c.Latest(target.Name, false)
+	if params["backup-file"] != "" {
+		m = c.Lookup(params["backup-file"])
+	}
+	if m == nil {
+		return nil
+	}
+	if m.IsFull() {
+		// Every backup has its own path, so the newest is found by manifest.
+		params["backup-file"] = m.Artifact
+		return nil
+	}
+
+	chain, err := c.Chain(m.Artifact)
+	if err != nil {
+		return err
+	}
+	incrementals := make([]string, 0, len(chain)-1)
+	for _, link := range chain[1:] {
+		incrementals = append(incrementals, link.Artifact)
+	}
+	params["backup-file"] = chain[0].Artifact
+	params["incrementals"] = strings.Join(incrementals, ",")
+	return nil
+}
+
+func listBackups(targets []config.Target) error {
+	all := &catalog.Catalog{}
+	for _, target := range targets {
+		c, err := backup.LoadCatalog(target)
+		if err != nil {
+			return err
+		}
+		all.Manifests = append(all.Manifests, c.Manifests...)
+	}
+	return all.WriteTree(os.Stdout)
+}
+
+func pruneBackups(targets []config.Target, policy catalog.Policy, dryRun bool, store *history.Store, locker lock.Locker) error {
+	for _, target := range targets {
+		start := time.Now()
+		removed, err := pruneTarget(target, policy, dryRun, locker)
+		if !dryRun {
+			if err := recordPrune(store, target, start, removed, err); err != nil {
+				log.Printf("Failed to record prune history: %v", err)
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// recordPrune adds a prune of target to the history.
+func recordPrune(store *history.Store, target config.Target, start time.Time, removed []*manifest.Manifest, pruneErr error) error {
+	host, _ := os.Hostname()
+	record := history.Record{
+		ID:         backup.NewJobID(),
+		Command:    "prune",
+		Target:     target.Name,
+		Engine:     target.Database.Type,
+		Host:       host,
+		DBHost:     target.Database.Host,
+		Status:     history.StatusSuccess,
+		StartedAt:  start.UTC(),
+		FinishedAt: time.Now().UTC(),
+		Removed:    len(removed),
+	}
+	if pruneErr != nil {
+		record.Status, record.Error = history.StatusFailure, pruneErr.Error()
+	}
+	return store.Append(record)
+}
+
+func showHistory(cfg *config.Config, filter history.Filter, jsonOutput bool) error {
+	records, err := backup.OpenHistory(cfg).Query(filter)
+	if err != nil {
+		return err
+	}
+	if jsonOutput {
+		return history.WriteJSON(os.Stdout, records)
+	}
+	return history.WriteTable(os.Stdout, records)
+}
+
+// pruneTarget removes the backups of target the policy lets go and returns
+// them, holding the locks jobs take for target and every database
+// discovered under it. A dry run only prints them.
+func pruneTarget(target config.Target, policy catalog.Policy, dryRun bool, locker lock.Locker) ([]*manifest.Manifest, error) {
+	c, err := backup.LoadCatalog(target)
+	if err != nil {
+		return nil, err
+	}
+	if !dryRun {
+		release, err := lockTargets(locker, c.Targets(target.Name))
+		if err != nil {
+			return nil, err
+		}
+		defer release()
+		// Jobs may have finished while the locks were taken.
+		if c, err = backup.LoadCatalog(target); err != nil {
+			return nil, err
+		}
+	}
+	expired, err := c.Prune(policy, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	repository := backup.OpenRepository(target.Storage)
+	released := false
+	var removed []*manifest.Manifest
+	for _, m := range expired {
+		if dryRun {
+			fmt.Println("would remove " + m.Artifact)
+			continue
+		}
+		if m.Repository != "" {
+			if err := repository.Delete(m.Repository); err != nil {
+				return removed, err
+			}
+			released = true
+		}
+		if err := catalog.Remove(m); err != nil {
+			return removed, err
+		}
+		removed = append(removed, m)
+		fmt.Println("removed " + m.Artifact)
+	}
+	if released {
+		// Uploads of other targets may be writing chunks they have not
+		// listed in an index yet, so GC waits for none to run.
+		held, err := locker.Acquire(backup.RepositoryLockName(target.Storage))
+		if errors.Is(err, lock.ErrLocked) {
+			fmt.Println("repository is in use, unreferenced chunks are removed by a later prune")
+			return removed, nil
+		}
+		if err != nil {
+			return removed, err
+		}
+		defer held.Release()
+		chunks, err := repository.GC()
+		if err != nil {
+			return removed, err
+		}
+		fmt.Printf("removed %d unreferenced chunks\n", chunks)
+	}
+	return removed, nil
+}
+
+// lockTargets acquires the locks of names in order. The returned function
+// releases them; on error none are held.
+func lockTargets(locker lock.Locker, names []string) (func(), error) {
+	var held []lock.Lock
+	release := func() {
+		for _, l := range held {
+			l.Release()
+		}
+	}
+	for _, name := range names {
+		l, err := locker.Acquire(name)
+		if err != nil {
+			release()
+			return nil, err
+		}
+		held = append(held, l)
+	}
+	return release, nil
+}
+
+func filterByType(targets []config.Target, dbType string) []config.Target {
+	var filtered []config.Target
+	for _, target := range targets {
+		if target.Database.Type == dbType {
+			filtered = append(filtered, target)
+		}
+	}
+	return filtered
 }
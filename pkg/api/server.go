@@ -0,0 +1,386 @@
+package api
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	_ "embed"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/itocode21/backup-tool/pkg/backup"
+	"github.com/itocode21/backup-tool/pkg/logging"
+	"github.com/itocode21/backup-tool/pkg/manifest"
+)
+
+//go:embed openapi.json
+var openAPI []byte
+
+const (
+	// maxJobs bounds how many job statuses are kept; the oldest finished
+	// ones are forgotten first.
+	maxJobs = 1000
+	// confirmationTTL is how long a restore confirmation token is valid.
+	confirmationTTL = 5 * time.Minute
+	maxBodySize     = 1 << 20
+)
+
+// Job states reported by the API.
+const (
+	StatusQueued    = "queued"
+	StatusRunning   = "running"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+)
+
+// Request is the body of POST /backups and POST /restores. The fields
+// mirror the CLI flags of the same names; Target takes the same selectors
+// as --target.
+type Request struct {
+	Target        string   `json:"target"`
+	Kind          string   `json:"kind,omitempty"`
+	BackupFile    string   `json:"backup_file,omitempty"`
+	Tables        []string `json:"tables,omitempty"`
+	ExcludeTables []string `json:"exclude_tables,omitempty"`
+	RestoreTo     string   `json:"restore_to,omitempty"`
+	RestoreDBName string   `json:"restore_dbname,omitempty"`
+	NSMap         []string `json:"ns_map,omitempty"`
+	Force         bool     `json:"force,omitempty"`
+	Confirm       string   `json:"confirm,omitempty"`
+}
+
+// JobStatus is what GET /jobs/{id} returns for a job started by the API.
+type JobStatus struct {
+	ID         string     `json:"id"`
+	Target     string     `json:"target"`
+	Command    string     `json:"command"`
+	Status     string     `json:"status"`
+	CreatedAt  time.Time  `json:"created_at"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	Bytes      int64      `json:"bytes,omitempty"`
+	Phase      string     `json:"phase,omitempty"`
+	Error      string     `json:"error,omitempty"`
+}
+
+func (s JobStatus) done() bool {
+	return s.Status == StatusSucceeded || s.Status == StatusFailed
+}
+
+type job struct {
+	status  JobStatus
+	changed chan struct{}
+}
+
+type confirmation struct {
+	request string
+	expires time.Time
+}
+
+// Server is the HTTP API of the daemon. Jobs it starts run on the daemon's
+// pool, so they share its worker and per-host limits.
+type Server struct {
+	Token  string
+	Pool   *backup.Pool
+	Logger *logging.Logger
+
+	// Jobs builds the jobs of a backup or restore request. Backups lists
+	// the cataloged backups of the targets a selector matches.
+	Jobs    func(command string, req Request) ([]backup.Job, error)
+	Backups func(target string) ([]*manifest.Manifest, error)
+	// Finished, if set, receives the results of every request's jobs.
+	Finished func([]backup.Result)
+
+	mu            sync.Mutex
+	jobs          map[string]*job
+	order         []string
+	confirmations map[string]confirmation
+	running       sync.WaitGroup
+}
+
+// NewServer returns a server running jobs on pool. It takes over the
+// pool's OnStart callback to track when queued jobs start.
+func NewServer(token string, pool *backup.Pool, logger *logging.Logger) *Server {
+	s := &Server{
+		Token:         token,
+		Pool:          pool,
+		Logger:        logger,
+		jobs:          make(map[string]*job),
+		confirmations: make(map[string]confirmation),
+	}
+	pool.OnStart = func(j backup.Job) {
+		s.update(j.ID, func(status *JobStatus) {
+			now := time.Now().UTC()
+			status.Status, status.StartedAt = StatusRunning, &now
+		})
+	}
+	return s
+}
+
+// Handler routes the API endpoints.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	})
+	mux.HandleFunc("GET /openapi.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(openAPI)
+	})
+	mux.Handle("GET /backups", s.authorize(s.listBackups))
+	mux.Handle("POST /backups", s.authorize(s.startBackup))
+	mux.Handle("POST /restores", s.authorize(s.startRestore))
+	mux.Handle("GET /jobs/{id}", s.authorize(s.getJob))
+	mux.Handle("GET /jobs/{id}/events", s.authorize(s.streamJob))
+	return mux
+}
+
+// Wait blocks until the jobs started through the API have finished.
+func (s *Server) Wait() {
+	s.running.Wait()
+}
+
+func (s *Server) authorize(next http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(s.Token)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeError(w, http.StatusUnauthorized, errors.New("missing or invalid token"))
+			return
+		}
+		next(w, r)
+	})
+}
+
+func (s *Server) listBackups(w http.ResponseWriter, r *http.Request) {
+	manifests, err := s.Backups(r.URL.Query().Get("target"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if manifests == nil {
+		manifests = []*manifest.Manifest{}
+	}
+	writeJSON(w, http.StatusOK, manifests)
+}
+
+func (s *Server) startBackup(w http.ResponseWriter, r *http.Request) {
+	req, ok := decodeRequest(w, r)
+	if !ok {
+		return
+	}
+	jobs, err := s.Jobs("backup", req)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	writeJSON(w, http.StatusAccepted, map[string]any{"jobs": s.submit(jobs)})
+}
+
+// startRestore answers a request without a confirmation token with a token
+// for exactly that request. Sending the request again with the token
+// starts the restore.
+func (s *Server) startRestore(w http.ResponseWriter, r *http.Request) {
+	req, ok := decodeRequest(w, r)
+	if !ok {
+		return
+	}
+	jobs, err := s.Jobs("restore", req)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	token := req.Confirm
+	req.Confirm = ""
+	fingerprint, _ := json.Marshal(req)
+
+	s.mu.Lock()
+	now := time.Now()
+	for key, c := range s.confirmations {
+		if now.After(c.expires) {
+			delete(s.confirmations, key)
+		}
+	}
+	if token == "" {
+		token = newToken()
+		expires := now.Add(confirmationTTL)
+		s.confirmations[token] = confirmation{request: string(fingerprint), expires: expires}
+		s.mu.Unlock()
+
+		targets := make([]string, 0, len(jobs))
+		for _, j := range jobs {
+			targets = append(targets, j.Target.Name)
+		}
+		writeJSON(w, http.StatusPreconditionRequired, map[string]any{
+			"confirm":    token,
+			"expires_at": expires.UTC(),
+			"targets":    targets,
+		})
+		return
+	}
+	c, ok := s.confirmations[token]
+	if ok && c.request == string(fingerprint) {
+		delete(s.confirmations, token)
+	}
+	s.mu.Unlock()
+	if !ok || c.request != string(fingerprint) {
+		writeError(w, http.StatusForbidden, errors.New("confirmation token is invalid, expired or for another request"))
+		return
+	}
+
+	s.Logger.Warn("Restore confirmed through the API", "remote", r.RemoteAddr, "target", req.Target)
+	writeJSON(w, http.StatusAccepted, map[string]any{"jobs": s.submit(jobs)})
+}
+
+func (s *Server) getJob(w http.ResponseWriter, r *http.Request) {
+	status, _, ok := s.snapshot(r.PathValue("id"))
+	if !ok {
+		writeError(w, http.StatusNotFound, errors.New("unknown job"))
+		return
+	}
+	writeJSON(w, http.StatusOK, status)
+}
+
+// streamJob sends the status of a job as server-sent events, one on every
+// change, until the job is done or the client goes away.
+func (s *Server) streamJob(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if _, _, ok := s.snapshot(id); !ok {
+		writeError(w, http.StatusNotFound, errors.New("unknown job"))
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, errors.New("streaming is not supported"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	for {
+		status, changed, _ := s.snapshot(id)
+		data, _ := json.Marshal(status)
+		if _, err := fmt.Fprintf(w, "event: status\ndata: %s\n\n", data); err != nil {
+			return
+		}
+		flusher.Flush()
+		if status.done() {
+			return
+		}
+		select {
+		case <-changed:
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// submit queues jobs on the pool and returns their initial statuses.
+func (s *Server) submit(jobs []backup.Job) []JobStatus {
+	statuses := make([]JobStatus, len(jobs))
+	s.mu.Lock()
+	for i := range jobs {
+		jobs[i].ID = backup.NewJobID()
+		statuses[i] = JobStatus{
+			ID:        jobs[i].ID,
+			Target:    jobs[i].Target.Name,
+			Command:   jobs[i].Command,
+			Status:    StatusQueued,
+			CreatedAt: time.Now().UTC(),
+		}
+		s.jobs[jobs[i].ID] = &job{status: statuses[i], changed: make(chan struct{})}
+		s.order = append(s.order, jobs[i].ID)
+	}
+	s.forget()
+	s.mu.Unlock()
+
+	s.running.Add(1)
+	go func() {
+		defer s.running.Done()
+		results := s.Pool.Run(jobs)
+		for _, result := range results {
+			s.update(result.ID, func(status *JobStatus) {
+				now := time.Now().UTC()
+				status.Status, status.FinishedAt, status.Bytes = StatusSucceeded, &now, result.Bytes
+				if result.Err != nil {
+					status.Status, status.Phase, status.Error = StatusFailed, result.Phase, result.Err.Error()
+				}
+			})
+		}
+		if s.Finished != nil {
+			s.Finished(results)
+		}
+	}()
+	return statuses
+}
+
+// forget drops the oldest finished jobs beyond maxJobs. s.mu must be held.
+func (s *Server) forget() {
+	excess := len(s.order) - maxJobs
+	kept := s.order[:0]
+	for _, id := range s.order {
+		if excess > 0 && s.jobs[id].status.done() {
+			delete(s.jobs, id)
+			excess--
+			continue
+		}
+		kept = append(kept, id)
+	}
+	s.order = kept
+}
+
+func (s *Server) update(id string, change func(*JobStatus)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j, ok := s.jobs[id]
+	if !ok {
+		// Jobs of scheduled rounds share the pool but are not tracked.
+		return
+	}
+	change(&j.status)
+	close(j.changed)
+	j.changed = make(chan struct{})
+}
+
+func (s *Server) snapshot(id string) (JobStatus, <-chan struct{}, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j, ok := s.jobs[id]
+	if !ok {
+		return JobStatus{}, nil, false
+	}
+	return j.status, j.changed, true
+}
+
+func decodeRequest(w http.ResponseWriter, r *http.Request) (Request, bool) {
+	var req Request
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodySize))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request body: %w", err))
+		return req, false
+	}
+	return req, true
+}
+
+func newToken() string {
+	var b [16]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, code int, err error) {
+	writeJSON(w, code, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,203 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/itocode21/backup-tool/pkg/backup"
+	"github.com/itocode21/backup-tool/pkg/config"
+	"github.com/itocode21/backup-tool/pkg/logging"
+	"github.com/itocode21/backup-tool/pkg/manifest"
+)
+
+// fakeManager завершается ошибкой для цели "broken"
+type fakeManager struct{}
+
+func (fakeManager) PerformFullBackup(params map[string]string) error {
+	if params["dbname"] == "broken" {
+		return errors.New("dump failed")
+	}
+	return os.WriteFile(params["backup-file"], []byte("dump"), 0644)
+}
+
+func (fakeManager) RestoreBackup(params map[string]string) error {
+	return nil
+}
+
+func newTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	logger, err := logging.NewLogger(&config.Config{})
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+	logger.SetOutput(io.Discard)
+
+	pool := &backup.Pool{
+		Logger: logger,
+		NewManager: func(dbType string, logger *logging.Logger) (backup.BackupManagerInterface, error) {
+			return fakeManager{}, nil
+		},
+	}
+	dir := t.TempDir()
+	s := NewServer("secret", pool, logger)
+	s.Jobs = func(command string, req Request) ([]backup.Job, error) {
+		if req.Target == "" {
+			return nil, errors.New("target is required")
+		}
+		target := config.Target{
+			Name:     req.Target,
+			Database: config.DatabaseConfig{Type: "sqlite", DBName: req.Target},
+		}
+		params := map[string]string{"dbname": req.Target, "backup-file": filepath.Join(dir, req.Target+".sqlite")}
+		return []backup.Job{{Target: target, Command: command, Params: params}}, nil
+	}
+	s.Backups = func(target string) ([]*manifest.Manifest, error) {
+		return []*manifest.Manifest{{Target: "orders"}}, nil
+	}
+
+	server := httptest.NewServer(s.Handler())
+	t.Cleanup(func() {
+		server.Close()
+		s.Wait()
+	})
+	return server
+}
+
+func call(t *testing.T, server *httptest.Server, method, path, token string, body any) (*http.Response, map[string]any) {
+	t.Helper()
+	var reader io.Reader
+	if body != nil {
+		data, _ := json.Marshal(body)
+		reader = bytes.NewReader(data)
+	}
+	req, _ := http.NewRequest(method, server.URL+path, reader)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("%s %s failed: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+	var decoded map[string]any
+	json.NewDecoder(resp.Body).Decode(&decoded)
+	return resp, decoded
+}
+
+// waitForJob reads the event stream of a job and returns its final status.
+func waitForJob(t *testing.T, server *httptest.Server, id string) JobStatus {
+	t.Helper()
+	req, _ := http.NewRequest("GET", server.URL+"/jobs/"+id+"/events", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Failed to stream job: %v", err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Unexpected content type %q", ct)
+	}
+
+	var status JobStatus
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		if data, ok := strings.CutPrefix(scanner.Text(), "data: "); ok {
+			if err := json.Unmarshal([]byte(data), &status); err != nil {
+				t.Fatalf("Invalid event %q: %v", data, err)
+			}
+		}
+	}
+	return status
+}
+
+func jobID(t *testing.T, body map[string]any) string {
+	t.Helper()
+	jobs, _ := body["jobs"].([]any)
+	if len(jobs) != 1 {
+		t.Fatalf("Expected one job, got %v", body)
+	}
+	return jobs[0].(map[string]any)["id"].(string)
+}
+
+func TestAuthentication(t *testing.T) {
+	server := newTestServer(t)
+
+	if resp, _ := call(t, server, "GET", "/healthz", "", nil); resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected healthz without a token to succeed, got %d", resp.StatusCode)
+	}
+	if resp, _ := call(t, server, "GET", "/backups", "", nil); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected 401 without a token, got %d", resp.StatusCode)
+	}
+	if resp, _ := call(t, server, "GET", "/backups", "wrong", nil); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected 401 with a wrong token, got %d", resp.StatusCode)
+	}
+	if resp, _ := call(t, server, "GET", "/backups", "secret", nil); resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected 200 with the token, got %d", resp.StatusCode)
+	}
+}
+
+func TestBackupJobs(t *testing.T) {
+	server := newTestServer(t)
+
+	resp, body := call(t, server, "POST", "/backups", "secret", Request{Target: "orders"})
+	if resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("Expected 202, got %d: %v", resp.StatusCode, body)
+	}
+	status := waitForJob(t, server, jobID(t, body))
+	if status.Status != StatusSucceeded || status.Target != "orders" || status.StartedAt == nil || status.FinishedAt == nil {
+		t.Errorf("Unexpected final status: %+v", status)
+	}
+
+	_, body = call(t, server, "POST", "/backups", "secret", Request{Target: "broken"})
+	status = waitForJob(t, server, jobID(t, body))
+	if status.Status != StatusFailed || status.Phase != backup.PhaseDump || status.Error != "dump failed" {
+		t.Errorf("Unexpected final status: %+v", status)
+	}
+
+	resp, body = call(t, server, "GET", "/jobs/"+status.ID, "secret", nil)
+	if resp.StatusCode != http.StatusOK || body["status"] != StatusFailed {
+		t.Errorf("Unexpected job lookup: %d %v", resp.StatusCode, body)
+	}
+	if resp, _ := call(t, server, "GET", "/jobs/unknown", "secret", nil); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown job, got %d", resp.StatusCode)
+	}
+	if resp, _ := call(t, server, "POST", "/backups", "secret", map[string]any{"target": "orders", "bogus": true}); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an unknown field, got %d", resp.StatusCode)
+	}
+}
+
+func TestRestoreNeedsConfirmation(t *testing.T) {
+	server := newTestServer(t)
+
+	resp, body := call(t, server, "POST", "/restores", "secret", Request{Target: "orders"})
+	if resp.StatusCode != http.StatusPreconditionRequired {
+		t.Fatalf("Expected 428, got %d: %v", resp.StatusCode, body)
+	}
+	token, _ := body["confirm"].(string)
+	if token == "" {
+		t.Fatalf("Expected a confirmation token, got %v", body)
+	}
+
+	if resp, _ := call(t, server, "POST", "/restores", "secret", Request{Target: "billing", Confirm: token}); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("Expected a token for another request to be rejected, got %d", resp.StatusCode)
+	}
+	resp, body = call(t, server, "POST", "/restores", "secret", Request{Target: "orders", Confirm: token})
+	if resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("Expected 202 with the token, got %d: %v", resp.StatusCode, body)
+	}
+	if status := waitForJob(t, server, jobID(t, body)); status.Status != StatusSucceeded || status.Command != "restore" {
+		t.Errorf("Unexpected final status: %+v", status)
+	}
+	if resp, _ := call(t, server, "POST", "/restores", "secret", Request{Target: "orders", Confirm: token}); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("Expected a used token to be rejected, got %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,96 @@
+package backup
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/itocode21/backup-tool/pkg/catalog"
+	"github.com/itocode21/backup-tool/pkg/config"
+	"github.com/itocode21/backup-tool/pkg/database"
+	"github.com/itocode21/backup-tool/pkg/logging"
+)
+
+// ExpandTargets replaces every server-wide target with one target per
+// discovered database that passes its include/exclude filters. Other
+// targets are returned unchanged.
+func ExpandTargets(targets []config.Target, logger *logging.Logger) ([]config.Target, error) {
+	var expanded []config.Target
+	for _, target := range targets {
+		if !target.Database.AllDatabases {
+			expanded = append(expanded, target)
+			continue
+		}
+
+		engine, err := database.NewBackup(target.Database.Type, logger.With("target", target.Name))
+		if err != nil {
+			return nil, err
+		}
+		discoverer, ok := engine.(database.Discoverer)
+		if !ok {
+			return nil, fmt.Errorf("target %s: %s does not support all_databases", target.Name, target.Database.Type)
+		}
+
+		names, err := discoverer.ListDatabases(target.Params(""))
+		if err != nil {
+			return nil, fmt.Errorf("target %s: failed to list databases: %w", target.Name, err)
+		}
+
+		selected := target.FilterDatabases(names)
+		logger.Info(fmt.Sprintf("Target %s: discovered %d databases, %d selected", target.Name, len(names), len(selected)))
+		for _, name := range selected {
+			expanded = append(expanded, target.ForDatabase(name))
+		}
+	}
+	return expanded, nil
+}
+
+// ExpandStoredTargets is ExpandTargets for restores: a server-wide target is
+// replaced by one target per database it has backups of, so databases that
+// are missing on the destination server are restored as well.
+func ExpandStoredTargets(targets []config.Target, logger *logging.Logger) ([]config.Target, error) {
+	var expanded []config.Target
+	for _, target := range targets {
+		if !target.Database.AllDatabases {
+			expanded = append(expanded, target)
+			continue
+		}
+
+		c, err := LoadCatalog(target)
+		if err != nil {
+			return nil, fmt.Errorf("target %s: failed to load catalog: %w", target.Name, err)
+		}
+		seen := make(map[string]bool)
+		var names []string
+		for _, m := range c.Manifests {
+			name, ok := strings.CutPrefix(m.Target, target.Name+"/")
+			if !ok || seen[name] {
+				continue
+			}
+			seen[name] = true
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		selected := target.FilterDatabases(names)
+		logger.Info(fmt.Sprintf("Target %s: found backups of %d databases, %d selected", target.Name, len(names), len(selected)))
+		for _, name := range selected {
+			expanded = append(expanded, target.ForDatabase(name))
+		}
+	}
+	return expanded, nil
+}
+
+// LoadCatalog scans the directory the target's backups are written to.
+func LoadCatalog(target config.Target) (*catalog.Catalog, error) {
+	artifact, err := database.ArtifactPath(target.Database.Type, target.Params(""))
+	if err != nil {
+		return nil, err
+	}
+	c, err := catalog.Scan(filepath.Dir(artifact))
+	if err != nil {
+		return nil, err
+	}
+	return c.Filter(target.Name), nil
+}
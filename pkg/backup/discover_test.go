@@ -0,0 +1,50 @@
+package backup
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/itocode21/backup-tool/pkg/config"
+	"github.com/itocode21/backup-tool/pkg/logging"
+	"github.com/itocode21/backup-tool/pkg/manifest"
+)
+
+func TestExpandStoredTargets(t *testing.T) {
+	logger, err := logging.NewLogger(&config.Config{})
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+	logger.SetOutput(&bytes.Buffer{})
+
+	server := config.Target{
+		Name:     "pg",
+		Database: config.DatabaseConfig{Type: "postgresql", Host: "db1", AllDatabases: true, Exclude: []string{"^tmp_"}},
+		Storage:  config.StorageConfig{LocalPath: t.TempDir()},
+	}
+	// Бэкапы есть у трёх баз, а на сервере их может уже не быть
+	for _, name := range []string{"orders", "users", "tmp_import"} {
+		target := server.ForDatabase(name)
+		artifact := filepath.Join(server.Storage.LocalPath, target.Name, name+".sql")
+		os.MkdirAll(filepath.Dir(artifact), 0755)
+		os.WriteFile(artifact, []byte("dump"), 0644)
+		manifest.Write(&manifest.Manifest{Target: target.Name, Database: name, Artifact: artifact})
+	}
+	single := config.Target{Name: "app", Database: config.DatabaseConfig{Type: "sqlite"}}
+
+	expanded, err := ExpandStoredTargets([]config.Target{server, single}, logger)
+	if err != nil {
+		t.Fatalf("ExpandStoredTargets failed: %v", err)
+	}
+	var names []string
+	for _, target := range expanded {
+		names = append(names, target.Name)
+	}
+	if len(names) != 3 || names[0] != "pg/orders" || names[1] != "pg/users" || names[2] != "app" {
+		t.Errorf("Expected pg/orders, pg/users and app, got %v", names)
+	}
+	if expanded[0].Database.DBName != "orders" || expanded[0].Database.AllDatabases {
+		t.Errorf("Expected a per-database target, got %+v", expanded[0].Database)
+	}
+}
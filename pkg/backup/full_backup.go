@@ -1,8 +1,12 @@
 package backup
 
 import (
+	"context"
+	"fmt"
+
 	"github.com/itocode21/backup-tool/pkg/database"
 	"github.com/itocode21/backup-tool/pkg/logging"
+	"github.com/itocode21/backup-tool/pkg/throttle"
 )
 
 type BackupManager struct {
@@ -26,10 +30,53 @@ func NewBackupManager(dbtype string, logger *logging.Logger) (*BackupManager, er
 
 func (b *BackupManager) PerformFullBackup(config map[string]string) error {
 	b.Logger.Info("Starting full backup for " + b.DatabaseType)
-	return b.Backup.RestoreBackup(config)
+	return b.Backup.PerformFullBackup(config)
+}
+
+// Exec runs a statement for a SQL hook, if the engine supports it.
+func (b *BackupManager) Exec(ctx context.Context, config map[string]string, statement string) error {
+	executor, ok := b.Backup.(database.Executor)
+	if !ok {
+		return fmt.Errorf("sql hooks are not supported for %s", b.DatabaseType)
+	}
+	return executor.Exec(ctx, config, statement)
+}
+
+// CountTables counts the tables of a finished backup, if the engine can.
+func (b *BackupManager) CountTables(config map[string]string) (int, error) {
+	counter, ok := b.Backup.(database.TableCounter)
+	if !ok {
+		return 0, fmt.Errorf("counting tables is not supported for %s", b.DatabaseType)
+	}
+	return counter.CountTables(config)
+}
+
+// LimitWrites passes the write limiter on to engines that pace their
+// artifact writes; other engines ignore it.
+func (b *BackupManager) LimitWrites(l *throttle.Limiter) {
+	if limited, ok := b.Backup.(database.WriteLimiter); ok {
+		limited.LimitWrites(l)
+	}
 }
 
+// RestoreBackup refuses to restore a whole database over one that already
+// has data unless config["force"] is "true". Selective table restores are
+// let through, since they target existing databases by design.
 func (b *BackupManager) RestoreBackup(config map[string]string) error {
 	b.Logger.Info("Starting restore for " + b.DatabaseType)
+
+	inspector, ok := b.Backup.(database.Inspector)
+	if ok && config["force"] != "true" && config["tables"] == "" && config["exclude-tables"] == "" {
+		hasData, err := inspector.HasData(config)
+		if err != nil {
+			b.Logger.Error("Failed to inspect restore target: " + err.Error())
+			return err
+		}
+		if hasData {
+			b.Logger.Error("Database " + config["dbname"] + " on " + config["host"] + " is not empty")
+			return database.ErrTargetNotEmpty
+		}
+	}
+
 	return b.Backup.RestoreBackup(config)
 }
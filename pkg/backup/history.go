@@ -0,0 +1,58 @@
+package backup
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/itocode21/backup-tool/pkg/config"
+	"github.com/itocode21/backup-tool/pkg/database/toolerr"
+	"github.com/itocode21/backup-tool/pkg/history"
+	"github.com/itocode21/backup-tool/pkg/manifest"
+)
+
+// OpenHistory returns the job history of a config. It defaults to
+// history.jsonl in the shared local storage.
+func OpenHistory(cfg *config.Config) *history.Store {
+	path := cfg.History.Path
+	if path == "" {
+		path = filepath.Join(cfg.Storage.LocalPath, "history.jsonl")
+	}
+	return history.Open(path)
+}
+
+// historyRecord describes a finished job for the history.
+func historyRecord(job Job, result Result, start time.Time) history.Record {
+	host, _ := os.Hostname()
+	record := history.Record{
+		ID:         result.ID,
+		Command:    job.Command,
+		Target:     job.Target.Name,
+		Engine:     job.Target.Database.Type,
+		Host:       host,
+		DBHost:     job.Target.Database.Host,
+		Status:     history.StatusSuccess,
+		StartedAt:  start.UTC(),
+		FinishedAt: start.Add(result.Duration).UTC(),
+		Artifact:   result.Artifact,
+		Bytes:      result.Bytes,
+		Tables:     result.Tables,
+		Suspicious: result.Suspicious,
+	}
+	if job.Command == "backup" {
+		record.Kind = backupKind(job.Params)
+	}
+	// A failed backup may have left the manifest of an earlier one.
+	if result.Err == nil || job.Command == "restore" {
+		if m, err := manifest.Read(result.Artifact); err == nil {
+			record.Repository = m.Repository
+		}
+	}
+	if result.Err != nil {
+		record.Status = history.StatusFailure
+		record.Phase = result.Phase
+		record.Error = result.Err.Error()
+		record.Stderr = toolerr.Stderr(result.Err)
+	}
+	return record
+}
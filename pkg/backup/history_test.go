@@ -0,0 +1,71 @@
+package backup
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/itocode21/backup-tool/pkg/config"
+	"github.com/itocode21/backup-tool/pkg/database/toolerr"
+	"github.com/itocode21/backup-tool/pkg/history"
+	"github.com/itocode21/backup-tool/pkg/logging"
+)
+
+// failingManager падает так же, как движки при ошибке утилиты
+type failingManager struct{}
+
+func (failingManager) PerformFullBackup(params map[string]string) error {
+	return toolerr.Wrap(errors.New("exit status 2"), "mysqldump: Got error: 1045: Access denied")
+}
+
+func (failingManager) RestoreBackup(params map[string]string) error {
+	return nil
+}
+
+func TestPoolRecordsHistory(t *testing.T) {
+	logger, err := logging.NewLogger(&config.Config{})
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+	logger.SetOutput(&bytes.Buffer{})
+
+	store := history.Open(filepath.Join(t.TempDir(), "history.jsonl"))
+	managers := map[string]BackupManagerInterface{"mysql": failingManager{}, "sqlite": &dumpManager{}}
+	pool := &Pool{
+		Logger:  logger,
+		History: store,
+		NewManager: func(dbType string, logger *logging.Logger) (BackupManagerInterface, error) {
+			return managers[dbType], nil
+		},
+	}
+
+	storage := config.StorageConfig{LocalPath: t.TempDir()}
+	var jobs []Job
+	for _, target := range []config.Target{
+		{Name: "orders", Database: config.DatabaseConfig{Type: "mysql", Host: "db1", DBName: "orders"}, Storage: storage},
+		{Name: "app", Database: config.DatabaseConfig{Type: "sqlite", DBName: "app"}, Storage: storage},
+	} {
+		jobs = append(jobs, Job{Target: target, Command: "backup", Params: target.Params("")})
+	}
+	results := pool.Run(jobs)
+
+	records, err := store.Query(history.Filter{})
+	if err != nil || len(records) != 2 {
+		t.Fatalf("Expected 2 records, got %v, %v", records, err)
+	}
+	byTarget := map[string]history.Record{records[0].Target: records[0], records[1].Target: records[1]}
+
+	failed := byTarget["orders"]
+	if failed.ID != results[0].ID || failed.Status != history.StatusFailure || failed.Phase != PhaseDump || failed.DBHost != "db1" {
+		t.Errorf("Unexpected failure record: %+v", failed)
+	}
+	if failed.Stderr != "mysqldump: Got error: 1045: Access denied" || failed.Error != "exit status 2" {
+		t.Errorf("Expected the tool's stderr to be recorded, got %+v", failed)
+	}
+
+	succeeded := byTarget["app"]
+	if succeeded.Status != history.StatusSuccess || succeeded.Artifact != results[1].Artifact || succeeded.Bytes == 0 || succeeded.FinishedAt.Before(succeeded.StartedAt) {
+		t.Errorf("Unexpected success record: %+v", succeeded)
+	}
+}
@@ -0,0 +1,111 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/itocode21/backup-tool/pkg/config"
+	"github.com/itocode21/backup-tool/pkg/database"
+	"github.com/itocode21/backup-tool/pkg/logging"
+)
+
+// DefaultHookTimeout bounds hooks that do not set their own timeout.
+const DefaultHookTimeout = 5 * time.Minute
+
+// hookRun carries what the hooks of one job see about it.
+type hookRun struct {
+	job      Job
+	params   map[string]string
+	artifact string
+	manager  BackupManagerInterface
+	logger   *logging.Logger
+	status   string
+	jobErr   error
+}
+
+// run executes the hooks of a stage in order. It returns the error of the
+// first failed hook that has abort_on_failure set; other failures are
+// logged and skipped.
+func (h *hookRun) run(stage string, hooks []config.Hook) error {
+	logger := h.logger.With("phase", PhaseHook, "hook", stage)
+	for i, hook := range hooks {
+		name := fmt.Sprintf("%s[%d]", stage, i)
+		logger.Info("Running hook " + name)
+		err := h.runHook(logger, name, hook)
+		if err == nil {
+			continue
+		}
+		if hook.AbortOnFailure {
+			logger.Error("Hook " + name + " failed: " + err.Error())
+			return fmt.Errorf("hook %s: %w", name, err)
+		}
+		logger.Warn("Hook " + name + " failed, continuing: " + err.Error())
+	}
+	return nil
+}
+
+func (h *hookRun) runHook(logger *logging.Logger, name string, hook config.Hook) error {
+	timeout := hook.Timeout
+	if timeout == 0 {
+		timeout = DefaultHookTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if hook.SQL != "" {
+		executor, ok := h.manager.(database.Executor)
+		if !ok {
+			return fmt.Errorf("sql hooks are not supported for %s", h.job.Target.Database.Type)
+		}
+		err := executor.Exec(ctx, h.params, hook.SQL)
+		if ctx.Err() == context.DeadlineExceeded {
+			return fmt.Errorf("timed out after %s", timeout)
+		}
+		return err
+	}
+
+	cmd := exec.CommandContext(ctx, "sh", "-c", hook.Command)
+	cmd.Env = append(os.Environ(), h.environment(name)...)
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	killProcessGroup(cmd)
+	cmd.WaitDelay = time.Second
+
+	err := cmd.Run()
+	if text := strings.TrimSpace(output.String()); text != "" {
+		logger.Debug("Hook " + name + " output: " + text)
+	}
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("timed out after %s", timeout)
+	}
+	if err != nil {
+		return fmt.Errorf("%w. Output: %s", err, output.String())
+	}
+	return nil
+}
+
+// environment describes the job to shell hooks. Credentials are left out.
+func (h *hookRun) environment(name string) []string {
+	errText := ""
+	if h.jobErr != nil {
+		errText = h.jobErr.Error()
+	}
+	return []string{
+		"BACKUP_TOOL_HOOK=" + name,
+		"BACKUP_TOOL_COMMAND=" + h.job.Command,
+		"BACKUP_TOOL_TARGET=" + h.job.Target.Name,
+		"BACKUP_TOOL_DB_TYPE=" + h.job.Target.Database.Type,
+		"BACKUP_TOOL_DB_HOST=" + h.params["host"],
+		"BACKUP_TOOL_DB_PORT=" + h.params["port"],
+		"BACKUP_TOOL_DB_NAME=" + h.params["dbname"],
+		"BACKUP_TOOL_ARTIFACT=" + h.artifact,
+		"BACKUP_TOOL_STATUS=" + h.status,
+		"BACKUP_TOOL_ERROR=" + errText,
+	}
+}
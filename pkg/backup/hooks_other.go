@@ -0,0 +1,7 @@
+//go:build !unix
+
+package backup
+
+import "os/exec"
+
+func killProcessGroup(cmd *exec.Cmd) {}
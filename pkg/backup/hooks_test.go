@@ -0,0 +1,152 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/itocode21/backup-tool/pkg/config"
+	"github.com/itocode21/backup-tool/pkg/logging"
+)
+
+func runWithHooks(t *testing.T, hooks config.HooksConfig, manager BackupManagerInterface) Result {
+	t.Helper()
+	target := config.Target{
+		Name:     "orders",
+		Database: config.DatabaseConfig{Type: "mysql", Host: "db", DBName: "orders"},
+		Storage:  config.StorageConfig{LocalPath: t.TempDir()},
+		Hooks:    hooks,
+	}
+	logger, err := logging.NewLogger(&config.Config{})
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+	logger.SetOutput(&bytes.Buffer{})
+	pool := &Pool{
+		Logger: logger,
+		NewManager: func(dbType string, logger *logging.Logger) (BackupManagerInterface, error) {
+			return manager, nil
+		},
+	}
+	return pool.Run([]Job{{Target: target, Command: "backup", Params: target.Params("")}})[0]
+}
+
+func TestHooksSeeJobEnvironment(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "hooks.log")
+	record := `echo "$BACKUP_TOOL_HOOK $BACKUP_TOOL_DB_NAME $BACKUP_TOOL_STATUS $(basename "$BACKUP_TOOL_ARTIFACT")" >> ` + out
+	result := runWithHooks(t, config.HooksConfig{
+		PreBackup:  []config.Hook{{Command: record}},
+		PostBackup: []config.Hook{{Command: record}},
+		OnFailure:  []config.Hook{{Command: record}},
+	}, &dumpManager{})
+	if result.Err != nil {
+		t.Fatalf("Backup failed: %v", result.Err)
+	}
+
+	data, _ := os.ReadFile(out)
+	want := "pre_backup[0] orders running orders.sql\npost_backup[0] orders success orders.sql\n"
+	if string(data) != want {
+		t.Errorf("Unexpected hook log:\n%s\nwant:\n%s", data, want)
+	}
+}
+
+func TestFailedPreHookAbortsJob(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "hooks.log")
+	manager := &dumpManager{}
+	result := runWithHooks(t, config.HooksConfig{
+		PreBackup: []config.Hook{{Command: "exit 3", AbortOnFailure: true}},
+		OnFailure: []config.Hook{{Command: `echo "$BACKUP_TOOL_STATUS $BACKUP_TOOL_ERROR" > ` + out}},
+	}, manager)
+	if result.Err == nil {
+		t.Fatal("Expected the job to fail")
+	}
+	if result.Phase != PhaseHook {
+		t.Errorf("Expected the job to fail in phase %s, got %q", PhaseHook, result.Phase)
+	}
+
+	data, _ := os.ReadFile(out)
+	if !strings.HasPrefix(string(data), "failure hook pre_backup[0]") {
+		t.Errorf("Expected on_failure hook to see the failure, got %q", data)
+	}
+}
+
+func TestHookFailureWithoutAbortContinues(t *testing.T) {
+	result := runWithHooks(t, config.HooksConfig{
+		PreBackup: []config.Hook{{Command: "sleep 5", Timeout: 50 * time.Millisecond}},
+	}, &dumpManager{})
+	if result.Err != nil {
+		t.Errorf("Expected a non-aborting hook failure to be ignored, got %v", result.Err)
+	}
+	if result.Duration > 4*time.Second {
+		t.Errorf("Expected the hook to time out, job took %s", result.Duration)
+	}
+}
+
+func TestSQLHookNeedsExecutor(t *testing.T) {
+	result := runWithHooks(t, config.HooksConfig{
+		PostBackup: []config.Hook{{SQL: "ANALYZE", AbortOnFailure: true}},
+	}, &dumpManager{})
+	if result.Err == nil || !strings.Contains(result.Err.Error(), "not supported") {
+		t.Errorf("Expected sql hook to fail without an executor, got %v", result.Err)
+	}
+}
+
+// slowExecutor ждёт, пока контекст хука не истечёт
+type slowExecutor struct {
+	dumpManager
+	cancelled bool
+}
+
+func (e *slowExecutor) Exec(ctx context.Context, config map[string]string, statement string) error {
+	<-ctx.Done()
+	e.cancelled = true
+	return ctx.Err()
+}
+
+func TestSQLHookTimeoutCancelsStatement(t *testing.T) {
+	executor := &slowExecutor{}
+	result := runWithHooks(t, config.HooksConfig{
+		PreBackup: []config.Hook{{SQL: "SELECT SLEEP(60)", Timeout: 50 * time.Millisecond, AbortOnFailure: true}},
+	}, executor)
+	if result.Err == nil || !strings.Contains(result.Err.Error(), "timed out") {
+		t.Errorf("Expected the sql hook to time out, got %v", result.Err)
+	}
+	if !executor.cancelled {
+		t.Error("Expected the statement to see its context cancelled")
+	}
+}
+
+func TestOnFailureRunsForSetupFailures(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "hooks.log")
+	target := config.Target{
+		Name:     "orders",
+		Database: config.DatabaseConfig{Type: "mysql", Host: "db", DBName: "orders"},
+		Storage:  config.StorageConfig{LocalPath: t.TempDir()},
+		Hooks:    config.HooksConfig{OnFailure: []config.Hook{{Command: `echo "$BACKUP_TOOL_STATUS $BACKUP_TOOL_ERROR" >> ` + out}}},
+	}
+	logger, err := logging.NewLogger(&config.Config{})
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+	logger.SetOutput(&bytes.Buffer{})
+	pool := &Pool{
+		Logger: logger,
+		NewManager: func(dbType string, logger *logging.Logger) (BackupManagerInterface, error) {
+			return nil, errors.New("no engine")
+		},
+	}
+	result := pool.Run([]Job{{Target: target, Command: "backup", Params: target.Params("")}})[0]
+	if result.Err == nil || result.Phase != PhaseSetup {
+		t.Fatalf("Expected the job to fail in setup, got %+v", result)
+	}
+
+	data, _ := os.ReadFile(out)
+	if string(data) != "failure no engine\n" {
+		t.Errorf("Expected on_failure hook to run once for a setup failure, got %q", data)
+	}
+}
@@ -0,0 +1,17 @@
+//go:build unix
+
+package backup
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// killProcessGroup makes a timed out hook take its child processes down
+// with it instead of leaving them holding the output pipe.
+func killProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+}
@@ -0,0 +1,35 @@
+package backup
+
+import (
+	"path/filepath"
+
+	"github.com/itocode21/backup-tool/pkg/config"
+	"github.com/itocode21/backup-tool/pkg/lock"
+	"github.com/itocode21/backup-tool/pkg/storage"
+)
+
+// OpenLocker returns the locker of a config that keeps backups, restores
+// and prunes of the same target apart. Locks live in locks in the local
+// storage unless lock.path is set; leases need lock.path on a filesystem
+// every host mounts.
+func OpenLocker(cfg *config.Config) (lock.Locker, error) {
+	path := cfg.Lock.Path
+	if path == "" {
+		path = filepath.Join(cfg.Storage.LocalPath, "locks")
+	}
+
+	var locker lock.Locker
+	switch cfg.Lock.Type {
+	case "none":
+		return lock.None{}, nil
+	case "lease":
+		lease, err := lock.NewLease(storage.NewLocal(path), cfg.Lock.TTL)
+		if err != nil {
+			return nil, err
+		}
+		locker = lease
+	default:
+		locker = lock.File{Dir: path}
+	}
+	return lock.WithWait(locker, cfg.Lock.Wait), nil
+}
@@ -0,0 +1,90 @@
+package backup
+
+import (
+	"time"
+
+	"github.com/itocode21/backup-tool/pkg/catalog"
+	"github.com/itocode21/backup-tool/pkg/manifest"
+	"github.com/itocode21/backup-tool/pkg/metrics"
+	"github.com/itocode21/backup-tool/pkg/progress"
+)
+
+// Metrics are the per-target series the daemon exposes on /metrics.
+type Metrics struct {
+	Registry *metrics.Registry
+
+	lastSuccess  *metrics.Vec
+	lastDuration *metrics.Vec
+	artifactSize *metrics.Vec
+	uploaded     *metrics.Vec
+	runs         *metrics.Vec
+	failures     *metrics.Vec
+	catalogCount *metrics.Vec
+	catalogSize  *metrics.Vec
+	deletions    *metrics.Vec
+	progress     *metrics.Vec
+	expected     *metrics.Vec
+}
+
+func NewMetrics() *Metrics {
+	r := metrics.NewRegistry()
+	return &Metrics{
+		Registry:     r,
+		lastSuccess:  r.Gauge("backup_tool_last_success_timestamp_seconds", "Unix time of the last successful backup.", "target"),
+		lastDuration: r.Gauge("backup_tool_last_run_duration_seconds", "Duration of the last run.", "target", "command"),
+		artifactSize: r.Gauge("backup_tool_artifact_size_bytes", "Size of the last backup artifact.", "target"),
+		uploaded:     r.Counter("backup_tool_uploaded_bytes_total", "Bytes newly written to the repository.", "target"),
+		runs:         r.Counter("backup_tool_runs_total", "Finished runs by status.", "target", "command", "status"),
+		failures:     r.Counter("backup_tool_failures_total", "Failed runs by the phase they failed in.", "target", "command", "phase"),
+		catalogCount: r.Gauge("backup_tool_catalog_backups", "Backups in the catalog.", "target"),
+		catalogSize:  r.Gauge("backup_tool_catalog_size_bytes", "Total size of the backups in the catalog.", "target"),
+		deletions:    r.Counter("backup_tool_retention_deletions_total", "Backups removed by retention.", "target"),
+		progress:     r.Gauge("backup_tool_job_progress_bytes", "Bytes processed by the running phase of a job.", "target", "phase"),
+		expected:     r.Gauge("backup_tool_job_expected_bytes", "Expected size of the running phase, from the previous backup.", "target", "phase"),
+	}
+}
+
+// Observe records the results of a pool run finished at now.
+func (m *Metrics) Observe(results []Result, now time.Time) {
+	for _, r := range results {
+		m.lastDuration.Set(r.Duration.Seconds(), r.Target, r.Command)
+		if r.Err != nil {
+			m.runs.Add(1, r.Target, r.Command, "failure")
+			m.failures.Add(1, r.Target, r.Command, r.Phase)
+			continue
+		}
+		m.runs.Add(1, r.Target, r.Command, "success")
+		if r.Command == "backup" {
+			m.lastSuccess.Max(float64(now.Unix()), r.Target)
+			m.artifactSize.Set(float64(r.Bytes), r.Target)
+			m.uploaded.Add(float64(r.Uploaded), r.Target)
+		}
+	}
+}
+
+// ObserveCatalog rebuilds the catalog gauges from c. The newest backup of
+// each target also counts as its last success, so the series survives a
+// daemon restart.
+func (m *Metrics) ObserveCatalog(c *catalog.Catalog) {
+	m.catalogCount.Reset()
+	m.catalogSize.Reset()
+	for _, b := range c.Manifests {
+		m.catalogCount.Add(1, b.Target)
+		m.catalogSize.Add(float64(b.SizeBytes), b.Target)
+		m.lastSuccess.Max(float64(b.CreatedAt.Unix()), b.Target)
+	}
+}
+
+// ObservePrune counts backups removed by retention.
+func (m *Metrics) ObservePrune(removed []*manifest.Manifest) {
+	for _, b := range removed {
+		m.deletions.Add(1, b.Target)
+	}
+}
+
+// Report exposes the progress of running phases; it makes Metrics a
+// progress.Reporter. The gauges keep the final value once a phase is done.
+func (m *Metrics) Report(s progress.Snapshot) {
+	m.progress.Set(float64(s.Bytes), s.Target, s.Phase)
+	m.expected.Set(float64(s.Total), s.Target, s.Phase)
+}
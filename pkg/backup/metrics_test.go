@@ -0,0 +1,48 @@
+package backup
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/itocode21/backup-tool/pkg/catalog"
+	"github.com/itocode21/backup-tool/pkg/manifest"
+)
+
+func TestMetrics(t *testing.T) {
+	m := NewMetrics()
+	now := time.Unix(1760000000, 0)
+	m.ObserveCatalog(&catalog.Catalog{Manifests: []*manifest.Manifest{
+		{Target: "orders", SizeBytes: 100, CreatedAt: now.Add(-time.Hour)},
+		{Target: "orders", SizeBytes: 200, CreatedAt: now.Add(-48 * time.Hour)},
+	}})
+	m.Observe([]Result{
+		{Target: "orders", Command: "backup", Duration: 2 * time.Second, Bytes: 300, Uploaded: 50},
+		{Target: "billing", Command: "backup", Phase: PhaseDump, Err: errors.New("dump failed")},
+	}, now)
+	m.ObservePrune([]*manifest.Manifest{{Target: "orders"}})
+
+	var buf bytes.Buffer
+	if err := m.Registry.Write(&buf); err != nil {
+		t.Fatalf("Write returned error: %v", err)
+	}
+	for _, line := range []string{
+		`backup_tool_last_success_timestamp_seconds{target="orders"} 1.76e+09`,
+		`backup_tool_last_run_duration_seconds{target="orders",command="backup"} 2`,
+		`backup_tool_artifact_size_bytes{target="orders"} 300`,
+		`backup_tool_uploaded_bytes_total{target="orders"} 50`,
+		`backup_tool_failures_total{target="billing",command="backup",phase="dump"} 1`,
+		`backup_tool_catalog_backups{target="orders"} 2`,
+		`backup_tool_catalog_size_bytes{target="orders"} 300`,
+		`backup_tool_retention_deletions_total{target="orders"} 1`,
+	} {
+		if !strings.Contains(buf.String(), line+"\n") {
+			t.Errorf("Expected %q in:\n%s", line, buf.String())
+		}
+	}
+	if strings.Contains(buf.String(), `backup_tool_last_success_timestamp_seconds{target="billing"}`) {
+		t.Error("Expected no last success for a failed target")
+	}
+}
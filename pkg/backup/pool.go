@@ -0,0 +1,521 @@
+package backup
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"text/tabwriter"
+	"time"
+
+	"github.com/itocode21/backup-tool/pkg/catalog"
+	"github.com/itocode21/backup-tool/pkg/config"
+	"github.com/itocode21/backup-tool/pkg/database"
+	"github.com/itocode21/backup-tool/pkg/database/params"
+	"github.com/itocode21/backup-tool/pkg/database/partial"
+	"github.com/itocode21/backup-tool/pkg/database/priority"
+	"github.com/itocode21/backup-tool/pkg/history"
+	"github.com/itocode21/backup-tool/pkg/lock"
+	"github.com/itocode21/backup-tool/pkg/logging"
+	"github.com/itocode21/backup-tool/pkg/manifest"
+	"github.com/itocode21/backup-tool/pkg/progress"
+	"github.com/itocode21/backup-tool/pkg/throttle"
+)
+
+// Job is a single command to run against one target. ID is generated when
+// the job runs unless the caller already assigned one.
+type Job struct {
+	ID      string
+	Target  config.Target
+	Command string
+	Params  map[string]string
+}
+
+// Result is the outcome of a Job. ID identifies the run in logs. Bytes is
+// the size of the artifact written or restored, Uploaded the part of it
+// that was new to the repository. Phase names the step a failed job
+// stopped at; Timings holds the duration of each phase that ran. Tables
+// and Suspicious are the table count and sanity findings of a backup.
+type Result struct {
+	ID         string
+	Target     string
+	Command    string
+	Artifact   string
+	Duration   time.Duration
+	Bytes      int64
+	Uploaded   int64
+	Phase      string
+	Timings    map[string]time.Duration
+	Tables     *int
+	Suspicious []string
+	Err        error
+}
+
+// DefaultProgressInterval is how often progress is reported when the pool
+// does not set an interval.
+const DefaultProgressInterval = 30 * time.Second
+
+// Phases a job can fail in.
+const (
+	PhaseSetup    = "setup"
+	PhaseLock     = "lock"
+	PhaseHook     = "hook"
+	PhaseDump     = "dump"
+	PhaseManifest = "manifest"
+	PhaseUpload   = "upload"
+	PhaseSanity   = "sanity"
+	PhaseFetch    = "fetch"
+	PhaseRestore  = "restore"
+)
+
+// phaseError tags an error with the phase it happened in.
+type phaseError struct {
+	phase string
+	err   error
+}
+
+func (e *phaseError) Error() string { return e.err.Error() }
+func (e *phaseError) Unwrap() error { return e.err }
+
+func inPhase(phase string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &phaseError{phase: phase, err: err}
+}
+
+// Pool runs jobs on a bounded number of workers. PerHost additionally caps
+// the number of jobs hitting the same database host at once. The limits
+// hold across concurrent calls to Run, so scheduled rounds and API requests
+// can share one pool.
+type Pool struct {
+	Workers int
+	PerHost int
+	Logger  *logging.Logger
+
+	// NewManager creates the manager for a job; it defaults to
+	// NewBackupManager and is replaced in tests.
+	NewManager func(dbType string, logger *logging.Logger) (BackupManagerInterface, error)
+
+	// OnStart, if set, is called when a job got its slots and starts.
+	OnStart func(Job)
+	// History, if set, records every finished job.
+	History *history.Store
+	// Progress, if set, receives the progress of dumps and uploads every
+	// ProgressInterval.
+	Progress         progress.Reporter
+	ProgressInterval time.Duration
+	// Throttle, if set, limits uploads, artifact writes and the priority
+	// of dump processes.
+	Throttle *throttle.Schedule
+	// Sanity compares each backup with the earlier ones in History.
+	Sanity config.SanityConfig
+	// Locker, if set, keeps jobs on the same target from running at once,
+	// also across processes.
+	Locker lock.Locker
+
+	once         sync.Once
+	slots        chan struct{}
+	sharedOnce   sync.Once
+	sharedLocker lock.Locker
+	mu           sync.Mutex
+	hostSlots    map[string]chan struct{}
+}
+
+func NewPool(cfg config.ParallelConfig, logger *logging.Logger) *Pool {
+	return &Pool{
+		Workers: cfg.Workers,
+		PerHost: cfg.PerHost,
+		Logger:  logger,
+		NewManager: func(dbType string, logger *logging.Logger) (BackupManagerInterface, error) {
+			return NewBackupManager(dbType, logger)
+		},
+	}
+}
+
+// Run executes all jobs and returns their results in the order of jobs.
+// Jobs are started in order as slots become free.
+func (p *Pool) Run(jobs []Job) []Result {
+	p.once.Do(func() {
+		p.slots = make(chan struct{}, max(p.Workers, 1))
+		p.hostSlots = make(map[string]chan struct{})
+	})
+
+	results := make([]Result, len(jobs))
+	var wg sync.WaitGroup
+	for i, job := range jobs {
+		p.slots <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-p.slots }()
+			if slots := p.acquireHost(job.Target.Database.Host); slots != nil {
+				defer func() { <-slots }()
+			}
+			results[i] = p.runJob(job)
+		}()
+	}
+	wg.Wait()
+
+	return results
+}
+
+// acquireHost takes a slot of host, blocking while PerHost jobs run on it.
+func (p *Pool) acquireHost(host string) chan struct{} {
+	if p.PerHost <= 0 {
+		return nil
+	}
+	p.mu.Lock()
+	slots, ok := p.hostSlots[host]
+	if !ok {
+		slots = make(chan struct{}, p.PerHost)
+		p.hostSlots[host] = slots
+	}
+	p.mu.Unlock()
+	slots <- struct{}{}
+	return slots
+}
+
+func (p *Pool) runJob(job Job) (result Result) {
+	if job.ID == "" {
+		job.ID = NewJobID()
+	}
+	if p.OnStart != nil {
+		p.OnStart(job)
+	}
+	result = Result{ID: job.ID, Target: job.Target.Name, Command: job.Command, Timings: make(map[string]time.Duration)}
+	logger := p.Logger.With("job_id", result.ID, "target", job.Target.Name, "engine", job.Target.Database.Type, "command", job.Command)
+	start := time.Now()
+	defer func() {
+		result.Duration = time.Since(start)
+		if result.Err != nil {
+			var phased *phaseError
+			result.Phase = PhaseSetup
+			if errors.As(result.Err, &phased) {
+				result.Phase = phased.phase
+			}
+			logger.Error("Job failed", "phase", result.Phase, "duration", result.Duration, "error", result.Err)
+		} else {
+			logger.Info("Job finished", "duration", result.Duration, "bytes", result.Bytes)
+		}
+		if p.History != nil {
+			if err := p.History.Append(historyRecord(job, result, start)); err != nil {
+				logger.Warn("Failed to record job history: " + err.Error())
+			}
+		}
+	}()
+
+	// Jobs that fail before their hooks are set up still run on_failure.
+	hooks := &hookRun{job: job, params: job.Params, logger: logger, status: "running"}
+	defer func() {
+		if result.Err != nil && hooks.status != "failure" {
+			hooks.status, hooks.jobErr = "failure", result.Err
+			hooks.run("on_failure", job.Target.Hooks.OnFailure)
+		}
+	}()
+
+	if p.Locker != nil {
+		held, err := p.Locker.Acquire(job.Target.Name)
+		if err != nil {
+			result.Err = inPhase(PhaseLock, err)
+			return result
+		}
+		defer func() {
+			if err := held.Release(); err != nil {
+				logger.Warn("Failed to release lock", "error", err)
+			}
+		}()
+	}
+
+	tempDir, err := os.MkdirTemp("", "backup-tool-"+strings.ReplaceAll(job.Target.Name, "/", "_")+"-")
+	if err != nil {
+		logger.Error("Failed to create temp directory: " + err.Error())
+		result.Err = err
+		return result
+	}
+	defer os.RemoveAll(tempDir)
+
+	jobParams := make(map[string]string, len(job.Params)+1)
+	for key, value := range job.Params {
+		jobParams[key] = value
+	}
+	jobParams["temp-dir"] = tempDir
+
+	artifact, err := database.ArtifactPath(job.Target.Database.Type, jobParams)
+	if err != nil {
+		logger.Error("Failed to resolve backup path: " + err.Error())
+		result.Err = err
+		return result
+	}
+	if job.Command == "backup" && job.Params["backup-file"] == "" &&
+		(KeepsEveryBackup(job.Target) || backupKind(jobParams) != manifest.KindFull) {
+		if job.Target.Database.Type == "mongodb" {
+			artifact = catalog.UniqueDir(artifact, start)
+			jobParams["backup-path"] = filepath.Dir(artifact)
+		} else {
+			artifact = catalog.UniquePath(artifact, start)
+		}
+	}
+	if job.Target.Database.Type != "mongodb" {
+		jobParams["backup-file"] = artifact
+	}
+	result.Artifact = artifact
+	hooks.params, hooks.artifact = jobParams, artifact
+
+	manager, err := p.NewManager(job.Target.Database.Type, logger)
+	if err != nil {
+		logger.Error("Failed to create backup instance: " + err.Error())
+		result.Err = err
+		return result
+	}
+
+	hooks.manager = manager
+	pre, post := job.Target.Hooks.PreBackup, job.Target.Hooks.PostBackup
+	if job.Command == "restore" {
+		pre, post = job.Target.Hooks.PreRestore, job.Target.Hooks.PostRestore
+	}
+
+	hooksStart := time.Now()
+	result.Err = inPhase(PhaseHook, hooks.run("pre_"+job.Command, pre))
+	if len(pre) > 0 {
+		result.Timings["pre_hooks"] = time.Since(hooksStart)
+	}
+	if result.Err == nil {
+		result.Err = p.execute(job, jobParams, artifact, manager, logger, start, &result)
+	}
+	if result.Err == nil {
+		hooks.status = "success"
+		result.Err = inPhase(PhaseHook, hooks.run("post_"+job.Command, post))
+	}
+	if result.Err != nil {
+		hooks.status, hooks.jobErr = "failure", result.Err
+		hooks.run("on_failure", job.Target.Hooks.OnFailure)
+	}
+	return result
+}
+
+// execute performs the command of a job once its hooks let it run and
+// records the size of the artifact it produced or restored in result.
+func (p *Pool) execute(job Job, jobParams map[string]string, artifact string, manager BackupManagerInterface, logger *logging.Logger, start time.Time, result *Result) error {
+	switch job.Command {
+	case "backup":
+		p.throttle(jobParams, manager, logger)
+		dumped := p.stage(job, jobParams, artifact)
+		if dumped != artifact {
+			defer os.RemoveAll(stagingPath(artifact))
+		}
+		dumpStart := time.Now()
+		stop := p.watch(job, PhaseDump, func() int64 {
+			return expectedSize(job.Target, artifact, jobParams["backup-kind"])
+		}, func() int64 {
+			return artifactSize(dumped)
+		})
+		err := manager.PerformFullBackup(jobParams)
+		stop()
+		result.Timings[PhaseDump] = time.Since(dumpStart)
+		if err != nil {
+			return inPhase(PhaseDump, err)
+		}
+		result.Tables = countTables(manager, jobParams, logger)
+		if result.Bytes, err = manifest.Size(dumped); err != nil {
+			return inPhase(PhaseManifest, err)
+		}
+		sanityErr := p.checkSanity(job, jobParams, artifact, start, result, logger)
+		if dumped != artifact {
+			if sanityErr != nil {
+				logger.Warn("Keeping the previous backup", "artifact", artifact)
+				return inPhase(PhaseSanity, sanityErr)
+			}
+			if err := partial.Commit(dumped, artifact); err != nil {
+				logger.Error("Failed to move backup into place: " + err.Error())
+				return inPhase(PhaseDump, err)
+			}
+		}
+		if err := writeManifest(job.Target, jobParams, artifact, time.Since(start), result); err != nil {
+			logger.Error("Failed to write manifest: " + err.Error())
+			return inPhase(PhaseManifest, err)
+		}
+		if job.Target.Storage.Repository.Enabled {
+			var read atomic.Int64
+			uploadStart := time.Now()
+			size := result.Bytes
+			stop := p.watch(job, PhaseUpload, func() int64 { return size }, read.Load)
+			uploaded, err := p.storeArtifact(job.Target, artifact, logger, read.Store)
+			stop()
+			result.Timings[PhaseUpload] = time.Since(uploadStart)
+			if err != nil {
+				logger.Error("Failed to store artifact in repository: " + err.Error())
+				return inPhase(PhaseUpload, err)
+			}
+			result.Uploaded = uploaded
+		}
+		return inPhase(PhaseSanity, sanityErr)
+	case "restore":
+		cleanup, err := fetchArtifacts(job.Target, append([]string{artifact}, params.List(jobParams, "incrementals")...), logger)
+		if err != nil {
+			logger.Error("Failed to fetch artifact from repository: " + err.Error())
+			return inPhase(PhaseFetch, err)
+		}
+		defer cleanup()
+		result.Bytes, _ = manifest.Size(artifact)
+		return inPhase(PhaseRestore, manager.RestoreBackup(jobParams))
+	default:
+		return fmt.Errorf("unknown command: %s", job.Command)
+	}
+}
+
+// storeArtifact uploads an artifact to the repository while holding the
+// repository lock, which the uploads of this pool share.
+func (p *Pool) storeArtifact(target config.Target, artifact string, logger *logging.Logger, progress func(int64)) (int64, error) {
+	if p.Locker != nil {
+		p.sharedOnce.Do(func() {
+			p.sharedLocker = lock.Shared(lock.WithWait(p.Locker, repositoryWait))
+		})
+		held, err := p.sharedLocker.Acquire(RepositoryLockName(target.Storage))
+		if err != nil {
+			return 0, err
+		}
+		defer held.Release()
+	}
+	return storeArtifact(target, artifact, logger, progress, p.Throttle.Upload())
+}
+
+// throttle applies the throttle limits in force to a backup: the priority
+// of the dump tool through its parameters and the shared write limiter.
+func (p *Pool) throttle(jobParams map[string]string, manager BackupManagerInterface, logger *logging.Logger) {
+	if p.Throttle == nil {
+		return
+	}
+	limits, profile := p.Throttle.At(time.Now())
+	if limits.Nice != 0 {
+		jobParams[priority.Nice] = strconv.Itoa(limits.Nice)
+	}
+	if limits.IONiceClass != "" {
+		jobParams[priority.IONiceClass] = limits.IONiceClass
+		jobParams[priority.IONiceLevel] = strconv.Itoa(limits.IONiceLevel)
+	}
+	if limited, ok := manager.(database.WriteLimiter); ok {
+		limited.LimitWrites(p.Throttle.Write())
+	}
+	logger.Debug("Throttling backup", "profile", profile, "upload_kb_per_second", limits.UploadKBPerSecond,
+		"write_kb_per_second", limits.WriteKBPerSecond, "nice", limits.Nice, "ionice_class", limits.IONiceClass)
+}
+
+// watch reports the progress of a phase of job to p.Progress until the
+// returned function is called. total is only evaluated when reporting.
+func (p *Pool) watch(job Job, phase string, total, size func() int64) (stop func()) {
+	if p.Progress == nil {
+		return func() {}
+	}
+	interval := p.ProgressInterval
+	if interval <= 0 {
+		interval = DefaultProgressInterval
+	}
+	snapshot := progress.Snapshot{JobID: job.ID, Target: job.Target.Name, Phase: phase, Total: total()}
+	return progress.Watch(p.Progress, snapshot, interval, size)
+}
+
+// expectedSize returns the size of the previous backup of the same kind
+// of target, the best guess for how large this one gets.
+func expectedSize(target config.Target, artifact, kind string) int64 {
+	c, err := catalog.Scan(filepath.Dir(artifact))
+	if err != nil {
+		return 0
+	}
+	previous := c.Latest(target.Name, kind == "" || kind == manifest.KindFull)
+	if previous == nil {
+		return 0
+	}
+	return previous.SizeBytes
+}
+
+// artifactSize returns how much of an artifact has been written, counting
+// the partial file engines write before renaming it into place.
+func artifactSize(artifact string) int64 {
+	size, _ := manifest.Size(artifact)
+	written, _ := manifest.Size(partial.Path(artifact))
+	return size + written
+}
+
+// NewJobID returns a short random id for a job run.
+func NewJobID() string {
+	var b [6]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 36)
+	}
+	return hex.EncodeToString(b[:])
+}
+
+func writeManifest(target config.Target, jobParams map[string]string, artifact string, duration time.Duration, result *Result) error {
+	return manifest.Write(&manifest.Manifest{
+		Target:     target.Name,
+		Engine:     target.Database.Type,
+		Host:       target.Database.Host,
+		Database:   target.Database.DBName,
+		Artifact:   artifact,
+		Format:     jobParams["format"],
+		Tables:     params.List(jobParams, "tables"),
+		SizeBytes:  result.Bytes,
+		CreatedAt:  time.Now().UTC(),
+		Duration:   duration,
+		Kind:       backupKind(jobParams),
+		Parent:     jobParams["incremental-base"],
+		Timings:    result.Timings,
+		TableCount: result.Tables,
+		Suspicious: result.Suspicious,
+	})
+}
+
+// IncrementalEngines lists the engines that can take backups relative to a
+// parent artifact. All their backups get unique paths, since a later full
+// backup must not replace the base of an existing chain.
+var IncrementalEngines = map[string]bool{
+	"mariadb": true,
+}
+
+// KeepsEveryBackup reports whether every backup of target gets a unique
+// path: those of chain engines, and those kept in a repository, where prune
+// rather than the next backup decides when an old one goes.
+func KeepsEveryBackup(target config.Target) bool {
+	return IncrementalEngines[target.Database.Type] || target.Storage.Repository.Enabled
+}
+
+// backupKind returns the kind of backup jobParams ask for.
+func backupKind(jobParams map[string]string) string {
+	if jobParams["backup-kind"] == "" {
+		return manifest.KindFull
+	}
+	return jobParams["backup-kind"]
+}
+
+// Failed returns the number of results that carry an error.
+func Failed(results []Result) int {
+	failed := 0
+	for _, r := range results {
+		if r.Err != nil {
+			failed++
+		}
+	}
+	return failed
+}
+
+// WriteSummary prints one row per result as an aligned table.
+func WriteSummary(w io.Writer, results []Result) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "TARGET\tCOMMAND\tSTATUS\tDURATION\tERROR")
+	for _, r := range results {
+		status, errText := "ok", ""
+		if r.Err != nil {
+			status, errText = "failed", r.Err.Error()
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", r.Target, r.Command, status, r.Duration.Round(time.Millisecond), errText)
+	}
+	return tw.Flush()
+}
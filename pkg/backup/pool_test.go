@@ -0,0 +1,367 @@
+package backup
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/itocode21/backup-tool/pkg/config"
+	"github.com/itocode21/backup-tool/pkg/database"
+	"github.com/itocode21/backup-tool/pkg/lock"
+	"github.com/itocode21/backup-tool/pkg/logging"
+	"github.com/itocode21/backup-tool/pkg/manifest"
+	"github.com/itocode21/backup-tool/pkg/progress"
+	"github.com/itocode21/backup-tool/pkg/throttle"
+)
+
+// fakeManager записывает, сколько задач выполняется одновременно (всего и по хостам)
+type fakeManager struct {
+	mu       sync.Mutex
+	running  int
+	peak     int
+	perHost  map[string]int
+	hostPeak map[string]int
+}
+
+func (f *fakeManager) PerformFullBackup(params map[string]string) error {
+	host := params["host"]
+
+	f.mu.Lock()
+	f.running++
+	f.perHost[host]++
+	f.peak = max(f.peak, f.running)
+	f.hostPeak[host] = max(f.hostPeak[host], f.perHost[host])
+	f.mu.Unlock()
+
+	time.Sleep(20 * time.Millisecond)
+
+	f.mu.Lock()
+	f.running--
+	f.perHost[host]--
+	f.mu.Unlock()
+
+	if params["temp-dir"] == "" {
+		return errors.New("temp-dir not set")
+	}
+	if err := os.MkdirAll(filepath.Dir(params["backup-file"]), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(params["backup-file"], []byte("dump"), 0644)
+}
+
+func (f *fakeManager) RestoreBackup(params map[string]string) error {
+	return nil
+}
+
+func TestPoolRespectsLimits(t *testing.T) {
+	fake := &fakeManager{perHost: map[string]int{}, hostPeak: map[string]int{}}
+
+	storage := config.StorageConfig{LocalPath: t.TempDir()}
+	var jobs []Job
+	for _, name := range []string{"a1", "a2", "a3", "b1", "b2", "c1"} {
+		target := config.Target{
+			Name:     name,
+			Database: config.DatabaseConfig{Type: "mysql", Host: name[:1], DBName: "db"},
+			Storage:  storage,
+		}
+		jobs = append(jobs, Job{Target: target, Command: "backup", Params: target.Params("")})
+	}
+
+	logger, err := logging.NewLogger(&config.Config{})
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+	logger.SetOutput(&bytes.Buffer{})
+	pool := &Pool{
+		Workers: 3,
+		PerHost: 1,
+		Logger:  logger,
+		NewManager: func(dbType string, logger *logging.Logger) (BackupManagerInterface, error) {
+			return fake, nil
+		},
+	}
+
+	results := pool.Run(jobs)
+	if len(results) != len(jobs) {
+		t.Fatalf("Expected %d results, got %d", len(jobs), len(results))
+	}
+	if Failed(results) != 0 {
+		t.Errorf("Expected no failures, got %+v", results)
+	}
+	if fake.peak > 3 {
+		t.Errorf("Expected at most 3 concurrent jobs, got %d", fake.peak)
+	}
+	for host, peak := range fake.hostPeak {
+		if peak > 1 {
+			t.Errorf("Expected at most 1 concurrent job on host %s, got %d", host, peak)
+		}
+	}
+
+	// После успешного бэкапа рядом с артефактом должен появиться манифест
+	m, err := manifest.Read(filepath.Join(storage.LocalPath, "a1", "db.sql"))
+	if err != nil {
+		t.Fatalf("Failed to read manifest: %v", err)
+	}
+	if m.Target != "a1" || m.SizeBytes != int64(len("dump")) || m.Kind != manifest.KindFull {
+		t.Errorf("Unexpected manifest: %+v", m)
+	}
+}
+
+func TestPoolReportsProgress(t *testing.T) {
+	logger, err := logging.NewLogger(&config.Config{})
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+	logger.SetOutput(&bytes.Buffer{})
+
+	var mu sync.Mutex
+	var done []progress.Snapshot
+	pool := &Pool{
+		Logger: logger,
+		NewManager: func(dbType string, logger *logging.Logger) (BackupManagerInterface, error) {
+			return &fakeManager{perHost: map[string]int{}, hostPeak: map[string]int{}}, nil
+		},
+		Progress: progress.ReporterFunc(func(s progress.Snapshot) {
+			mu.Lock()
+			defer mu.Unlock()
+			if s.Done {
+				done = append(done, s)
+			}
+		}),
+		ProgressInterval: 5 * time.Millisecond,
+	}
+
+	target := config.Target{
+		Name:     "orders",
+		Database: config.DatabaseConfig{Type: "mysql", Host: "db1", DBName: "orders"},
+		Storage:  config.StorageConfig{LocalPath: t.TempDir()},
+	}
+	// Второй запуск берёт ожидаемый размер из манифеста первого
+	for range 2 {
+		if results := pool.Run([]Job{{Target: target, Command: "backup", Params: target.Params("")}}); Failed(results) != 0 {
+			t.Fatalf("Backup failed: %+v", results)
+		}
+	}
+
+	if len(done) != 2 {
+		t.Fatalf("Expected a final report per run, got %+v", done)
+	}
+	if done[0].Phase != PhaseDump || done[0].Bytes != int64(len("dump")) || done[0].Total != 0 {
+		t.Errorf("Unexpected report of the first run: %+v", done[0])
+	}
+	if done[1].Total != int64(len("dump")) || done[1].JobID == "" {
+		t.Errorf("Expected the previous size as total, got %+v", done[1])
+	}
+
+	m, err := manifest.Read(filepath.Join(target.Storage.LocalPath, "orders", "orders.sql"))
+	if err != nil {
+		t.Fatalf("Failed to read manifest: %v", err)
+	}
+	if _, ok := m.Timings[PhaseDump]; !ok {
+		t.Errorf("Expected the dump timing in the manifest, got %+v", m.Timings)
+	}
+}
+
+func TestPoolLocksTargets(t *testing.T) {
+	logger, err := logging.NewLogger(&config.Config{})
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+	logger.SetOutput(&bytes.Buffer{})
+	locker := lock.File{Dir: t.TempDir()}
+	pool := &Pool{
+		Logger: logger,
+		Locker: locker,
+		NewManager: func(dbType string, logger *logging.Logger) (BackupManagerInterface, error) {
+			return &fakeManager{perHost: map[string]int{}, hostPeak: map[string]int{}}, nil
+		},
+	}
+
+	target := config.Target{
+		Name:     "orders",
+		Database: config.DatabaseConfig{Type: "mysql", Host: "db1", DBName: "orders"},
+		Storage:  config.StorageConfig{LocalPath: t.TempDir()},
+	}
+	// Цель уже занята другим процессом, например бэкапом из cron
+	held, err := locker.Acquire("orders")
+	if err != nil {
+		t.Fatalf("Acquire failed: %v", err)
+	}
+	results := pool.Run([]Job{{Target: target, Command: "backup", Params: target.Params("")}})
+	if !errors.Is(results[0].Err, lock.ErrLocked) || results[0].Phase != PhaseLock {
+		t.Errorf("Expected the job to fail on the lock, got %+v", results[0])
+	}
+
+	held.Release()
+	if results := pool.Run([]Job{{Target: target, Command: "backup", Params: target.Params("")}}); results[0].Err != nil {
+		t.Errorf("Expected the job to run once the lock is free, got %v", results[0].Err)
+	}
+}
+
+// limitedEngine запоминает ограничитель записи, который ему передали
+type limitedEngine struct {
+	fakeManager
+	writes *throttle.Limiter
+}
+
+func (e *limitedEngine) LimitWrites(l *throttle.Limiter) {
+	e.writes = l
+}
+
+func TestPoolLimitsWritesThroughManager(t *testing.T) {
+	logger, err := logging.NewLogger(&config.Config{})
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+	logger.SetOutput(&bytes.Buffer{})
+	engine := &limitedEngine{fakeManager: fakeManager{perHost: map[string]int{}, hostPeak: map[string]int{}}}
+	pool := &Pool{
+		Logger:   logger,
+		Throttle: throttle.NewSchedule(config.ThrottleConfig{ThrottleLimits: config.ThrottleLimits{WriteKBPerSecond: 1024}}),
+		// Настоящий BackupManager поверх движка, как в NewPool
+		NewManager: func(dbType string, logger *logging.Logger) (BackupManagerInterface, error) {
+			return &BackupManager{DatabaseType: dbType, Backup: engine, Logger: logger}, nil
+		},
+	}
+
+	target := config.Target{
+		Name:     "orders",
+		Database: config.DatabaseConfig{Type: "mysql", Host: "db1", DBName: "orders"},
+		Storage:  config.StorageConfig{LocalPath: t.TempDir()},
+	}
+	results := pool.Run([]Job{{Target: target, Command: "backup", Params: target.Params("")}})
+	if results[0].Err != nil {
+		t.Fatalf("Backup failed: %v", results[0].Err)
+	}
+	if engine.writes == nil {
+		t.Error("Expected the write limiter to reach the engine through BackupManager")
+	}
+}
+
+func TestPoolGivesChainBackupsUniquePaths(t *testing.T) {
+	logger, err := logging.NewLogger(&config.Config{})
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+	logger.SetOutput(&bytes.Buffer{})
+	pool := &Pool{
+		Logger: logger,
+		NewManager: func(dbType string, logger *logging.Logger) (BackupManagerInterface, error) {
+			return &fakeManager{perHost: map[string]int{}, hostPeak: map[string]int{}}, nil
+		},
+	}
+
+	// Полный бэкап MariaDB — основа цепочки, его нельзя перезаписывать следующим
+	target := config.Target{
+		Name:     "shop",
+		Database: config.DatabaseConfig{Type: "mariadb", Host: "db1", DBName: "shop"},
+		Storage:  config.StorageConfig{LocalPath: t.TempDir()},
+	}
+	fixed, err := database.ArtifactPath("mariadb", target.Params(""))
+	if err != nil {
+		t.Fatalf("ArtifactPath failed: %v", err)
+	}
+	results := pool.Run([]Job{{Target: target, Command: "backup", Params: target.Params("")}})
+	if results[0].Err != nil {
+		t.Fatalf("Backup failed: %v", results[0].Err)
+	}
+	if results[0].Artifact == fixed {
+		t.Errorf("Expected a unique path for a full MariaDB backup, got %s", results[0].Artifact)
+	}
+}
+
+func TestPoolLimitsHoldAcrossRuns(t *testing.T) {
+	fake := &fakeManager{perHost: map[string]int{}, hostPeak: map[string]int{}}
+	logger, err := logging.NewLogger(&config.Config{})
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+	logger.SetOutput(&bytes.Buffer{})
+	pool := &Pool{
+		Workers: 2,
+		Logger:  logger,
+		NewManager: func(dbType string, logger *logging.Logger) (BackupManagerInterface, error) {
+			return fake, nil
+		},
+	}
+
+	storage := config.StorageConfig{LocalPath: t.TempDir()}
+	var wg sync.WaitGroup
+	for _, host := range []string{"a", "b", "c"} {
+		target := config.Target{
+			Name:     host,
+			Database: config.DatabaseConfig{Type: "mysql", Host: host, DBName: "db"},
+			Storage:  storage,
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			pool.Run([]Job{{Target: target, Command: "backup", Params: target.Params("")}, {Target: target, Command: "backup", Params: target.Params("")}})
+		}()
+	}
+	wg.Wait()
+
+	if fake.peak > 2 {
+		t.Errorf("Expected at most 2 concurrent jobs across runs, got %d", fake.peak)
+	}
+}
+
+func TestWriteSummary(t *testing.T) {
+	results := []Result{
+		{Target: "orders", Command: "backup", Duration: time.Second},
+		{Target: "billing", Command: "backup", Err: errors.New("dump failed")},
+	}
+
+	var buf bytes.Buffer
+	if err := WriteSummary(&buf, results); err != nil {
+		t.Fatalf("WriteSummary returned error: %v", err)
+	}
+	if !strings.Contains(buf.String(), "failed") || !strings.Contains(buf.String(), "dump failed") {
+		t.Errorf("Summary does not contain the failure: %s", buf.String())
+	}
+	if Failed(results) != 1 {
+		t.Errorf("Expected 1 failed result, got %d", Failed(results))
+	}
+}
+
+func TestJobLogsCommandAndPhase(t *testing.T) {
+	logger, err := logging.NewLogger(&config.Config{Logging: config.LoggingConfig{Format: "json"}})
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+	var out bytes.Buffer
+	logger.SetOutput(&out)
+	pool := &Pool{
+		Logger: logger,
+		NewManager: func(dbType string, logger *logging.Logger) (BackupManagerInterface, error) {
+			return failingManager{}, nil
+		},
+	}
+	target := config.Target{
+		Name:     "orders",
+		Database: config.DatabaseConfig{Type: "mysql", Host: "db1", DBName: "orders"},
+		Storage:  config.StorageConfig{LocalPath: t.TempDir()},
+	}
+	pool.Run([]Job{{Target: target, Command: "backup", Params: target.Params("")}})
+
+	// Поле phase означает фазу, как в API и метриках, а команда идёт в command
+	var failed map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		var record map[string]any
+		if json.Unmarshal([]byte(line), &record) == nil && record["msg"] == "Job failed" {
+			failed = record
+		}
+	}
+	if failed["command"] != "backup" || failed["phase"] != PhaseDump {
+		t.Errorf("Expected command=backup and phase=dump, got %v", failed)
+	}
+	if _, ok := failed["failed_phase"]; ok {
+		t.Errorf("Expected no failed_phase field, got %v", failed)
+	}
+}
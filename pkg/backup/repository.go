@@ -0,0 +1,102 @@
+package backup
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/itocode21/backup-tool/pkg/config"
+	"github.com/itocode21/backup-tool/pkg/logging"
+	"github.com/itocode21/backup-tool/pkg/manifest"
+	"github.com/itocode21/backup-tool/pkg/repository"
+	"github.com/itocode21/backup-tool/pkg/storage"
+	"github.com/itocode21/backup-tool/pkg/throttle"
+)
+
+// OpenRepository returns the deduplicating repository of a storage config.
+func OpenRepository(cfg config.StorageConfig) *repository.Repository {
+	return repository.New(storage.NewLocal(repositoryPath(cfg)), cfg.Repository.AvgChunkSize)
+}
+
+func repositoryPath(cfg config.StorageConfig) string {
+	if cfg.Repository.Path != "" {
+		return cfg.Repository.Path
+	}
+	return filepath.Join(cfg.LocalPath, "repository")
+}
+
+// RepositoryLockName names the lock of the repository of a storage config.
+// Uploads share it and GC takes it alone, since GC would delete chunks an
+// upload has written but not yet listed in an index.
+func RepositoryLockName(cfg config.StorageConfig) string {
+	return "repository:" + filepath.Clean(repositoryPath(cfg))
+}
+
+// repositoryWait is how long an upload waits for a GC to finish.
+const repositoryWait = time.Hour
+
+// storeArtifact moves a finished artifact into the target's repository and
+// records the repository id and upload time in its manifest. It returns
+// the number of bytes that were new to the repository; progress is called
+// with the bytes read so far and new chunks are sent no faster than upload
+// allows.
+func storeArtifact(target config.Target, artifact string, logger *logging.Logger, progress func(int64), upload *throttle.Limiter) (int64, error) {
+	m, err := manifest.Read(artifact)
+	if err != nil {
+		return 0, err
+	}
+
+	start := time.Now()
+	id := target.Name + "/" + filepath.Base(artifact) + "-" + m.CreatedAt.Format("20060102T150405Z")
+	repository := OpenRepository(target.Storage)
+	repository.Progress = progress
+	repository.Storage = throttle.Storage(repository.Storage, upload)
+	stats, err := repository.Store(id, artifact)
+	if err != nil {
+		return 0, err
+	}
+	logger.Info("Stored artifact in repository", "artifact", artifact, "bytes", stats.Bytes,
+		"new_bytes", stats.NewBytes, "chunks", stats.Chunks, "new_chunks", stats.NewChunks)
+
+	m.Repository = id
+	if m.Timings == nil {
+		m.Timings = make(map[string]time.Duration)
+	}
+	m.Timings["upload"] = time.Since(start)
+	if err := manifest.Write(m); err != nil {
+		return 0, err
+	}
+	return stats.NewBytes, os.RemoveAll(artifact)
+}
+
+// fetchArtifacts restores artifacts that only exist in the repository to
+// their original paths. The returned function removes them again.
+func fetchArtifacts(target config.Target, artifacts []string, logger *logging.Logger) (func(), error) {
+	var fetched []string
+	cleanup := func() {
+		for _, artifact := range fetched {
+			os.RemoveAll(artifact)
+		}
+	}
+
+	for _, artifact := range artifacts {
+		if _, err := os.Stat(artifact); err == nil {
+			continue
+		}
+		m, err := manifest.Read(artifact)
+		if err != nil || m.Repository == "" {
+			// Leave reporting the missing artifact to the engine.
+			continue
+		}
+
+		logger.Info("Fetching " + artifact + " from repository")
+		start := time.Now()
+		fetched = append(fetched, artifact)
+		if err := OpenRepository(target.Storage).Restore(m.Repository, artifact); err != nil {
+			cleanup()
+			return nil, err
+		}
+		logger.Debug("Fetched " + artifact + " in " + time.Since(start).Round(time.Millisecond).String())
+	}
+	return cleanup, nil
+}
@@ -0,0 +1,153 @@
+package backup
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/itocode21/backup-tool/pkg/catalog"
+	"github.com/itocode21/backup-tool/pkg/config"
+	"github.com/itocode21/backup-tool/pkg/lock"
+	"github.com/itocode21/backup-tool/pkg/logging"
+	"github.com/itocode21/backup-tool/pkg/manifest"
+)
+
+// dumpManager пишет фиксированный дамп и запоминает, что прочитал при восстановлении
+type dumpManager struct {
+	dump     string
+	restored string
+}
+
+func (d *dumpManager) PerformFullBackup(params map[string]string) error {
+	if err := os.MkdirAll(filepath.Dir(params["backup-file"]), 0755); err != nil {
+		return err
+	}
+	dump := d.dump
+	if dump == "" {
+		dump = "CREATE TABLE t (id int);"
+	}
+	return os.WriteFile(params["backup-file"], []byte(dump), 0644)
+}
+
+func (d *dumpManager) RestoreBackup(params map[string]string) error {
+	data, err := os.ReadFile(params["backup-file"])
+	d.restored = string(data)
+	return err
+}
+
+func TestPoolStoresArtifactsInRepository(t *testing.T) {
+	storage := config.StorageConfig{
+		LocalPath:  t.TempDir(),
+		Repository: config.RepositoryConfig{Enabled: true},
+	}
+	target := config.Target{
+		Name:     "orders",
+		Database: config.DatabaseConfig{Type: "mysql", Host: "db", DBName: "orders"},
+		Storage:  storage,
+	}
+
+	logger, err := logging.NewLogger(&config.Config{})
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+	logger.SetOutput(&bytes.Buffer{})
+	fake := &dumpManager{}
+	pool := &Pool{
+		Logger: logger,
+		Locker: lock.File{Dir: t.TempDir()},
+		NewManager: func(dbType string, logger *logging.Logger) (BackupManagerInterface, error) {
+			return fake, nil
+		},
+	}
+
+	results := pool.Run([]Job{{Target: target, Command: "backup", Params: target.Params("")}})
+	if Failed(results) != 0 {
+		t.Fatalf("Backup failed: %+v", results)
+	}
+
+	// Артефакт должен переехать в репозиторий, а манифест — остаться на месте
+	artifact := results[0].Artifact
+	if _, err := os.Stat(artifact); !os.IsNotExist(err) {
+		t.Errorf("Expected %s to be moved into the repository", artifact)
+	}
+	m, err := manifest.Read(artifact)
+	if err != nil || m.Repository == "" {
+		t.Fatalf("Expected manifest with repository id, got %+v, %v", m, err)
+	}
+
+	results = pool.Run([]Job{{Target: target, Command: "restore", Params: target.Params(artifact)}})
+	if Failed(results) != 0 {
+		t.Fatalf("Restore failed: %+v", results)
+	}
+	if fake.restored != "CREATE TABLE t (id int);" {
+		t.Errorf("Expected restore to read the fetched dump, got %q", fake.restored)
+	}
+	if _, err := os.Stat(artifact); !os.IsNotExist(err) {
+		t.Errorf("Expected fetched %s to be cleaned up after restore", artifact)
+	}
+}
+
+func TestRepositoryKeepsEveryBackup(t *testing.T) {
+	storage := config.StorageConfig{
+		LocalPath:  t.TempDir(),
+		Repository: config.RepositoryConfig{Enabled: true},
+	}
+	target := config.Target{
+		Name:     "orders",
+		Database: config.DatabaseConfig{Type: "mysql", Host: "db", DBName: "orders"},
+		Storage:  storage,
+	}
+	logger, err := logging.NewLogger(&config.Config{})
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+	logger.SetOutput(&bytes.Buffer{})
+	fake := &dumpManager{}
+	pool := &Pool{
+		Logger: logger,
+		NewManager: func(dbType string, logger *logging.Logger) (BackupManagerInterface, error) {
+			return fake, nil
+		},
+	}
+
+	// Два ночных дампа; пути различаются меткой времени с точностью до секунды
+	var artifacts []string
+	for i, dump := range []string{"first dump", "second dump"} {
+		if i > 0 {
+			time.Sleep(time.Second)
+		}
+		fake.dump = dump
+		results := pool.Run([]Job{{Target: target, Command: "backup", Params: target.Params("")}})
+		if Failed(results) != 0 {
+			t.Fatalf("Backup failed: %+v", results)
+		}
+		artifacts = append(artifacts, results[0].Artifact)
+	}
+	if artifacts[0] == artifacts[1] {
+		t.Fatalf("Expected every repository backup to get its own path, got %s twice", artifacts[0])
+	}
+
+	c, err := LoadCatalog(target)
+	if err != nil {
+		t.Fatalf("LoadCatalog failed: %v", err)
+	}
+	expired, err := c.Prune(catalog.Policy{KeepLast: 2}, time.Now())
+	if err != nil || len(expired) != 0 {
+		t.Fatalf("Expected keep-last 2 to keep both backups, got %v, %v", expired, err)
+	}
+	if _, err := OpenRepository(storage).GC(); err != nil {
+		t.Fatalf("GC failed: %v", err)
+	}
+
+	for i, want := range []string{"first dump", "second dump"} {
+		results := pool.Run([]Job{{Target: target, Command: "restore", Params: target.Params(artifacts[i])}})
+		if Failed(results) != 0 {
+			t.Fatalf("Restore of %s failed: %+v", artifacts[i], results)
+		}
+		if fake.restored != want {
+			t.Errorf("Expected %s to restore %q, got %q", artifacts[i], want, fake.restored)
+		}
+	}
+}
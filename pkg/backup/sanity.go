@@ -0,0 +1,131 @@
+package backup
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/itocode21/backup-tool/pkg/database"
+	"github.com/itocode21/backup-tool/pkg/history"
+	"github.com/itocode21/backup-tool/pkg/logging"
+	"github.com/itocode21/backup-tool/pkg/manifest"
+	"github.com/itocode21/backup-tool/pkg/notify"
+	"github.com/itocode21/backup-tool/pkg/sanity"
+)
+
+// countTables returns the number of tables in a finished backup, or nil
+// when the engine cannot tell.
+func countTables(manager BackupManagerInterface, jobParams map[string]string, logger *logging.Logger) *int {
+	counter, ok := manager.(database.TableCounter)
+	if !ok {
+		return nil
+	}
+	// BackupManager always has CountTables, so check the engine behind it.
+	if m, ok := manager.(*BackupManager); ok {
+		if _, ok := m.Backup.(database.TableCounter); !ok {
+			return nil
+		}
+	}
+	tables, err := counter.CountTables(jobParams)
+	if err != nil {
+		logger.Warn("Failed to count tables", "error", err)
+		return nil
+	}
+	return &tables
+}
+
+// checkSanity compares a finished dump with the recent backups of its
+// target and kind in the history, before its manifest is written.
+// Deviations are recorded in result, logged and sent to the target's Slack
+// webhook; under the fail policy they also fail the job.
+func (p *Pool) checkSanity(job Job, jobParams map[string]string, artifact string, start time.Time, result *Result, logger *logging.Logger) error {
+	if p.Sanity.Policy == "" {
+		return nil
+	}
+	baseline, err := p.baseline(job.Target.Name, backupKind(jobParams))
+	if err != nil {
+		logger.Warn("Failed to read backup history", "error", err)
+	}
+	current := sanity.Sample{Bytes: result.Bytes, Tables: result.Tables, Duration: time.Since(start)}
+	reasons := sanity.Check(current, baseline, p.Sanity)
+	if len(reasons) == 0 {
+		return nil
+	}
+
+	result.Suspicious = reasons
+	logger.Warn("Backup looks suspicious", "artifact", artifact, "reasons", strings.Join(reasons, "; "))
+	if url := job.Target.Notification.SlackWebhookURL; url != "" {
+		text := fmt.Sprintf("Suspicious backup of %s (%s on %s): %s\nArtifact: %s", job.Target.Name,
+			job.Target.Database.Type, job.Target.Database.Host, strings.Join(reasons, "; "), artifact)
+		if err := notify.Slack(url, text); err != nil {
+			logger.Warn("Failed to send notification", "error", err)
+		}
+	}
+
+	if p.Sanity.Policy == sanityFail {
+		return fmt.Errorf("backup looks suspicious: %s", strings.Join(reasons, "; "))
+	}
+	return nil
+}
+
+// sanityFail is the sanity policy that fails suspicious backups.
+const sanityFail = "fail"
+
+// stage points a backup that would replace an existing one under the fail
+// policy at a staging path next to it and returns where the dump lands.
+// Only a dump that passes the sanity checks is moved into place, so a
+// suspicious one never replaces the last good backup. Chain engines are
+// left alone: their backups get unique paths and sidecar files.
+func (p *Pool) stage(job Job, jobParams map[string]string, artifact string) string {
+	if p.Sanity.Policy != sanityFail || IncrementalEngines[job.Target.Database.Type] {
+		return artifact
+	}
+	if _, err := os.Stat(manifest.Path(artifact)); err != nil {
+		if _, err := os.Stat(artifact); err != nil {
+			return artifact
+		}
+	}
+	staging := stagingPath(artifact)
+	os.RemoveAll(staging)
+	// mongodump writes a <dbname> directory under backup-path.
+	if job.Target.Database.Type == "mongodb" {
+		jobParams["backup-path"] = staging
+		return filepath.Join(staging, filepath.Base(artifact))
+	}
+	jobParams["backup-file"] = staging
+	return staging
+}
+
+// stagingPath is where stage puts the dump of artifact.
+func stagingPath(artifact string) string {
+	return artifact + ".staged"
+}
+
+// baseline returns the newest successful backups of target and kind from
+// the history, up to sanity.baseline of them. Records written before kinds
+// were recorded count as full backups.
+func (p *Pool) baseline(target, kind string) ([]sanity.Sample, error) {
+	if p.History == nil {
+		return nil, nil
+	}
+	records, err := p.History.Query(history.Filter{Command: "backup", Status: history.StatusSuccess})
+	if err != nil {
+		return nil, err
+	}
+
+	limit := p.Sanity.Baseline
+	if limit == 0 {
+		limit = sanity.DefaultBaseline
+	}
+	var samples []sanity.Sample
+	for i := len(records) - 1; i >= 0 && len(samples) < limit; i-- {
+		r := records[i]
+		if r.Target != target || (r.Kind != kind && (r.Kind != "" || kind != manifest.KindFull)) {
+			continue
+		}
+		samples = append(samples, sanity.Sample{Bytes: r.Bytes, Tables: r.Tables, Duration: r.FinishedAt.Sub(r.StartedAt)})
+	}
+	return samples, nil
+}
@@ -0,0 +1,157 @@
+package backup
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/itocode21/backup-tool/pkg/config"
+	"github.com/itocode21/backup-tool/pkg/history"
+	"github.com/itocode21/backup-tool/pkg/logging"
+	"github.com/itocode21/backup-tool/pkg/manifest"
+)
+
+func TestPoolFlagsSuspiciousBackups(t *testing.T) {
+	logger, err := logging.NewLogger(&config.Config{})
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+	logger.SetOutput(&bytes.Buffer{})
+
+	var notified []string
+	slack := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]string
+		json.NewDecoder(r.Body).Decode(&payload)
+		notified = append(notified, payload["text"])
+	}))
+	defer slack.Close()
+
+	// Обычно дамп весит около 10 МиБ, а fakeManager пишет 4 байта
+	store := history.Open(filepath.Join(t.TempDir(), "history.jsonl"))
+	start := time.Now().Add(-time.Hour)
+	for i := range 3 {
+		store.Append(history.Record{ID: "old", Command: "backup", Target: "orders", Kind: manifest.KindFull, Status: history.StatusSuccess,
+			StartedAt: start.Add(time.Duration(i) * time.Minute), FinishedAt: start.Add(time.Duration(i)*time.Minute + time.Second), Bytes: 10 << 20})
+	}
+
+	pool := &Pool{
+		Logger:  logger,
+		History: store,
+		Sanity:  config.SanityConfig{Policy: "fail", SizeChangePercent: 50},
+		NewManager: func(dbType string, logger *logging.Logger) (BackupManagerInterface, error) {
+			return &fakeManager{perHost: map[string]int{}, hostPeak: map[string]int{}}, nil
+		},
+	}
+	target := config.Target{
+		Name:         "orders",
+		Database:     config.DatabaseConfig{Type: "mysql", Host: "db1", DBName: "orders"},
+		Storage:      config.StorageConfig{LocalPath: t.TempDir()},
+		Notification: config.NotificationConfig{SlackWebhookURL: slack.URL},
+	}
+	results := pool.Run([]Job{{Target: target, Command: "backup", Params: target.Params("")}})
+
+	if results[0].Err == nil || results[0].Phase != PhaseSanity || len(results[0].Suspicious) != 1 {
+		t.Fatalf("Expected the backup to fail the sanity check, got %+v", results[0])
+	}
+	m, err := manifest.Read(results[0].Artifact)
+	if err != nil {
+		t.Fatalf("Failed to read manifest: %v", err)
+	}
+	if len(m.Suspicious) != 1 || !strings.Contains(m.Suspicious[0], "100% below") {
+		t.Errorf("Expected the manifest to be marked suspicious, got %q", m.Suspicious)
+	}
+	if len(notified) != 1 || !strings.HasPrefix(notified[0], "Suspicious backup of orders") {
+		t.Errorf("Expected a notification, got %q", notified)
+	}
+
+	records, _ := store.Query(history.Filter{Status: history.StatusFailure})
+	if len(records) != 1 || records[0].Phase != PhaseSanity || records[0].Kind != manifest.KindFull || len(records[0].Suspicious) != 1 {
+		t.Errorf("Unexpected history record: %+v", records)
+	}
+
+	// Под политикой warn тот же бэкап проходит
+	pool.Sanity.Policy = "warn"
+	if results := pool.Run([]Job{{Target: target, Command: "backup", Params: target.Params("")}}); results[0].Err != nil || len(results[0].Suspicious) != 1 {
+		t.Errorf("Expected a warning only, got %+v", results[0])
+	}
+}
+
+func TestSuspiciousBackupKeepsPrevious(t *testing.T) {
+	logger, err := logging.NewLogger(&config.Config{})
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+	logger.SetOutput(&bytes.Buffer{})
+
+	store := history.Open(filepath.Join(t.TempDir(), "history.jsonl"))
+	start := time.Now().Add(-time.Hour)
+	for i := range 3 {
+		store.Append(history.Record{ID: "old", Command: "backup", Target: "orders", Kind: manifest.KindFull, Status: history.StatusSuccess,
+			StartedAt: start.Add(time.Duration(i) * time.Minute), FinishedAt: start.Add(time.Duration(i)*time.Minute + time.Second), Bytes: 10 << 20})
+	}
+	pool := &Pool{
+		Logger:  logger,
+		History: store,
+		Sanity:  config.SanityConfig{Policy: "fail", SizeChangePercent: 50},
+		NewManager: func(dbType string, logger *logging.Logger) (BackupManagerInterface, error) {
+			return &fakeManager{perHost: map[string]int{}, hostPeak: map[string]int{}}, nil
+		},
+	}
+	target := config.Target{
+		Name:     "orders",
+		Database: config.DatabaseConfig{Type: "mysql", Host: "db1", DBName: "orders"},
+		Storage:  config.StorageConfig{LocalPath: t.TempDir()},
+	}
+
+	// Последний хороший бэкап лежит по тому же пути, что и новый
+	artifact := filepath.Join(target.Storage.LocalPath, "orders", "orders.sql")
+	os.MkdirAll(filepath.Dir(artifact), 0755)
+	os.WriteFile(artifact, []byte("good dump"), 0644)
+	manifest.Write(&manifest.Manifest{Target: "orders", Artifact: artifact, SizeBytes: 9})
+
+	results := pool.Run([]Job{{Target: target, Command: "backup", Params: target.Params("")}})
+	if results[0].Err == nil || results[0].Phase != PhaseSanity {
+		t.Fatalf("Expected the backup to fail the sanity check, got %+v", results[0])
+	}
+	if data, _ := os.ReadFile(artifact); string(data) != "good dump" {
+		t.Errorf("Expected the previous backup to stay in place, got %q", data)
+	}
+	if m, err := manifest.Read(artifact); err != nil || m.SizeBytes != 9 || len(m.Suspicious) != 0 {
+		t.Errorf("Expected the previous manifest to stay in place, got %+v, %v", m, err)
+	}
+	if _, err := os.Stat(stagingPath(artifact)); !os.IsNotExist(err) {
+		t.Errorf("Expected the staged dump to be removed, got %v", err)
+	}
+}
+
+// countingEngine считает таблицы артефакта, как движки с TableCounter
+type countingEngine struct {
+	fakeManager
+}
+
+func (e *countingEngine) CountTables(params map[string]string) (int, error) {
+	return 12, nil
+}
+
+func TestCountTablesThroughManager(t *testing.T) {
+	logger, err := logging.NewLogger(&config.Config{})
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+	logger.SetOutput(&bytes.Buffer{})
+
+	counting := &BackupManager{DatabaseType: "mysql", Backup: &countingEngine{}, Logger: logger}
+	if tables := countTables(counting, map[string]string{}, logger); tables == nil || *tables != 12 {
+		t.Errorf("Expected 12 tables through BackupManager, got %v", tables)
+	}
+	plain := &BackupManager{DatabaseType: "redis", Backup: &fakeManager{}, Logger: logger}
+	if tables := countTables(plain, map[string]string{}, logger); tables != nil {
+		t.Errorf("Expected no table count for an engine without CountTables, got %d", *tables)
+	}
+}
@@ -0,0 +1,265 @@
+package catalog
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/itocode21/backup-tool/pkg/manifest"
+)
+
+// Catalog is the set of backups found in storage, identified by their
+// manifests and ordered from oldest to newest.
+type Catalog struct {
+	Manifests []*manifest.Manifest
+}
+
+// Scan loads every manifest under root. A missing root is an empty catalog.
+func Scan(root string) (*Catalog, error) {
+	c := &Catalog{}
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == root {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if info.IsDir() || !strings.HasSuffix(path, manifest.Suffix) {
+			return nil
+		}
+		artifact := strings.TrimSuffix(path, manifest.Suffix)
+		m, err := manifest.Read(artifact)
+		if err != nil {
+			return fmt.Errorf("read manifest %s: %w", path, err)
+		}
+		// The manifest may have been moved together with its artifact, so
+		// the location it was found at wins over the recorded one.
+		m.Artifact = artifact
+		c.Manifests = append(c.Manifests, m)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(c.Manifests, func(i, j int) bool {
+		return c.Manifests[i].CreatedAt.Before(c.Manifests[j].CreatedAt)
+	})
+	return c, nil
+}
+
+// Lookup returns the manifest of artifact, or nil if it is not cataloged.
+func (c *Catalog) Lookup(artifact string) *manifest.Manifest {
+	artifact = filepath.Clean(artifact)
+	for _, m := range c.Manifests {
+		if filepath.Clean(m.Artifact) == artifact {
+			return m
+		}
+	}
+	return nil
+}
+
+// Latest returns the newest backup of target, limited to full backups when
+// fullOnly is set.
+func (c *Catalog) Latest(target string, fullOnly bool) *manifest.Manifest {
+	for i := len(c.Manifests) - 1; i >= 0; i-- {
+		m := c.Manifests[i]
+		if m.Target == target && (!fullOnly || m.IsFull()) {
+			return m
+		}
+	}
+	return nil
+}
+
+// Parent returns the kind-specific base for a new backup of target: the
+// latest backup for an incremental, the latest full backup for a
+// differential.
+func (c *Catalog) Parent(target, kind string) (*manifest.Manifest, error) {
+	var parent *manifest.Manifest
+	switch kind {
+	case manifest.KindIncremental:
+		parent = c.Latest(target, false)
+	case manifest.KindDifferential:
+		parent = c.Latest(target, true)
+	default:
+		return nil, fmt.Errorf("unknown backup kind: %s", kind)
+	}
+	if parent == nil {
+		return nil, fmt.Errorf("no full backup of %s to base a %s backup on", target, kind)
+	}
+	return parent, nil
+}
+
+// Chain returns the backups needed to restore artifact, starting with the
+// full backup and ending with artifact itself.
+func (c *Catalog) Chain(artifact string) ([]*manifest.Manifest, error) {
+	m := c.Lookup(artifact)
+	if m == nil {
+		return nil, fmt.Errorf("%s is not in the catalog", artifact)
+	}
+
+	var chain []*manifest.Manifest
+	seen := make(map[*manifest.Manifest]bool)
+	for {
+		if seen[m] {
+			return nil, fmt.Errorf("backup chain of %s contains a cycle", artifact)
+		}
+		seen[m] = true
+		chain = append([]*manifest.Manifest{m}, chain...)
+		if m.IsFull() {
+			return chain, nil
+		}
+		parent := c.Lookup(m.Parent)
+		if parent == nil {
+			return nil, fmt.Errorf("backup chain of %s is broken: %s is missing", artifact, m.Parent)
+		}
+		m = parent
+	}
+}
+
+// Policy decides which backups prune keeps. KeepLast keeps the newest
+// backups of each target, KeepWithin those younger than the duration.
+type Policy struct {
+	KeepLast   int
+	KeepWithin time.Duration
+}
+
+// Prune returns the backups the policy lets go. Every backup a retained one
+// depends on is retained as well, so no remaining chain is broken.
+func (c *Catalog) Prune(policy Policy, now time.Time) ([]*manifest.Manifest, error) {
+	if policy.KeepLast <= 0 && policy.KeepWithin <= 0 {
+		return nil, fmt.Errorf("prune needs a retention policy")
+	}
+
+	keep := make(map[*manifest.Manifest]bool)
+	counts := make(map[string]int)
+	for i := len(c.Manifests) - 1; i >= 0; i-- {
+		m := c.Manifests[i]
+		counts[m.Target]++
+		if counts[m.Target] <= policy.KeepLast || (policy.KeepWithin > 0 && now.Sub(m.CreatedAt) < policy.KeepWithin) {
+			keep[m] = true
+		}
+	}
+
+	for m := range keep {
+		for !m.IsFull() {
+			parent := c.Lookup(m.Parent)
+			if parent == nil || keep[parent] {
+				break
+			}
+			keep[parent] = true
+			m = parent
+		}
+	}
+
+	var expired []*manifest.Manifest
+	for _, m := range c.Manifests {
+		if !keep[m] {
+			expired = append(expired, m)
+		}
+	}
+	return expired, nil
+}
+
+// Remove deletes a backup: its artifact, manifest and checkpoints sidecar.
+// Other files next to the artifact are left alone, since another backup's
+// artifact may share its name as a prefix.
+func Remove(m *manifest.Manifest) error {
+	// The checkpoints sidecar is written by the mariadb engine.
+	for _, path := range []string{m.Artifact, manifest.Path(m.Artifact), m.Artifact + ".checkpoints"} {
+		if err := os.RemoveAll(path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Filter returns a catalog with the backups of target only, including the
+// per-database targets discovered under it.
+func (c *Catalog) Filter(target string) *Catalog {
+	filtered := &Catalog{}
+	for _, m := range c.Manifests {
+		if m.Target == target || strings.HasPrefix(m.Target, target+"/") {
+			filtered.Manifests = append(filtered.Manifests, m)
+		}
+	}
+	return filtered
+}
+
+// Targets returns target and the targets of its backups, such as the
+// per-database targets discovered under it, sorted by name.
+func (c *Catalog) Targets(target string) []string {
+	seen := map[string]bool{target: true}
+	names := []string{target}
+	for _, m := range c.Manifests {
+		if !seen[m.Target] {
+			seen[m.Target] = true
+			names = append(names, m.Target)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// WriteTree prints the catalog as a table, with each backup indented under
+// the one it depends on.
+func (c *Catalog) WriteTree(w io.Writer) error {
+	children := make(map[*manifest.Manifest][]*manifest.Manifest)
+	var roots []*manifest.Manifest
+	for _, m := range c.Manifests {
+		parent := c.Lookup(m.Parent)
+		if m.IsFull() || parent == nil {
+			roots = append(roots, m)
+			continue
+		}
+		children[parent] = append(children[parent], m)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "TARGET\tKIND\tCREATED\tSIZE\tARTIFACT")
+	var walk func(m *manifest.Manifest, depth int)
+	walk = func(m *manifest.Manifest, depth int) {
+		kind := m.Kind
+		if kind == "" {
+			kind = manifest.KindFull
+		}
+		artifact := m.Artifact
+		if depth > 0 {
+			artifact = strings.Repeat("  ", depth-1) + "└─ " + artifact
+		}
+		if !m.IsFull() && c.Lookup(m.Parent) == nil {
+			artifact += " (missing parent " + m.Parent + ")"
+		}
+		if len(m.Suspicious) > 0 {
+			artifact += " (suspicious: " + strings.Join(m.Suspicious, "; ") + ")"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%s\n", m.Target, kind, m.CreatedAt.Format(time.RFC3339), m.SizeBytes, artifact)
+		for _, child := range children[m] {
+			walk(child, depth+1)
+		}
+	}
+	for _, root := range roots {
+		walk(root, 0)
+	}
+	return tw.Flush()
+}
+
+// UniquePath adds a timestamp before the extension of artifact. Backups of
+// chain engines and backups kept in a repository get one, so that later runs
+// never overwrite a chain member or a backup prune is meant to keep.
+func UniquePath(artifact string, t time.Time) string {
+	ext := filepath.Ext(artifact)
+	return strings.TrimSuffix(artifact, ext) + "-" + t.UTC().Format(uniqueTimeFormat) + ext
+}
+
+// UniqueDir is UniquePath for engines whose tools name the artifact after
+// the database: the artifact moves into a timestamped directory instead.
+func UniqueDir(artifact string, t time.Time) string {
+	return filepath.Join(filepath.Dir(artifact), t.UTC().Format(uniqueTimeFormat), filepath.Base(artifact))
+}
+
+const uniqueTimeFormat = "20060102T150405Z"
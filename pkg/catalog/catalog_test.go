@@ -0,0 +1,168 @@
+package catalog
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/itocode21/backup-tool/pkg/manifest"
+)
+
+var epoch = time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// writeBackup creates an artifact with its manifest, created day days after epoch.
+func writeBackup(t *testing.T, dir, name, kind, parent string, day int) string {
+	t.Helper()
+	artifact := filepath.Join(dir, name)
+	if err := os.WriteFile(artifact, []byte("data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	err := manifest.Write(&manifest.Manifest{
+		Target:    "db",
+		Artifact:  artifact,
+		Kind:      kind,
+		Parent:    parent,
+		CreatedAt: epoch.AddDate(0, 0, day),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return artifact
+}
+
+func TestChain(t *testing.T) {
+	dir := t.TempDir()
+	full := writeBackup(t, dir, "full", manifest.KindFull, "", 0)
+	inc1 := writeBackup(t, dir, "inc1", manifest.KindIncremental, full, 1)
+	inc2 := writeBackup(t, dir, "inc2", manifest.KindIncremental, inc1, 2)
+	diff := writeBackup(t, dir, "diff", manifest.KindDifferential, full, 3)
+	orphan := writeBackup(t, dir, "orphan", manifest.KindIncremental, filepath.Join(dir, "gone"), 4)
+
+	c, err := Scan(dir)
+	if err != nil {
+		t.Fatalf("Scan returned error: %v", err)
+	}
+
+	tests := []struct {
+		artifact string
+		want     []string
+		wantErr  bool
+	}{
+		{full, []string{full}, false},
+		{inc2, []string{full, inc1, inc2}, false},
+		{diff, []string{full, diff}, false},
+		{orphan, nil, true},
+	}
+	for _, tt := range tests {
+		chain, err := c.Chain(tt.artifact)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Chain(%s): expected error %v, got %v", tt.artifact, tt.wantErr, err)
+			continue
+		}
+		var got []string
+		for _, m := range chain {
+			got = append(got, m.Artifact)
+		}
+		if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("Chain(%s) = %v, want %v", tt.artifact, got, tt.want)
+		}
+	}
+
+	parent, err := c.Parent("db", manifest.KindDifferential)
+	if err != nil || parent.Artifact != full {
+		t.Errorf("Expected differential parent %s, got %+v, %v", full, parent, err)
+	}
+	parent, err = c.Parent("db", manifest.KindIncremental)
+	if err != nil || parent.Artifact != orphan {
+		t.Errorf("Expected incremental parent %s, got %+v, %v", orphan, parent, err)
+	}
+}
+
+func TestPruneKeepsChains(t *testing.T) {
+	dir := t.TempDir()
+	old := writeBackup(t, dir, "old", manifest.KindFull, "", 0)
+	full := writeBackup(t, dir, "full", manifest.KindFull, "", 10)
+	inc1 := writeBackup(t, dir, "inc1", manifest.KindIncremental, full, 11)
+	inc2 := writeBackup(t, dir, "inc2", manifest.KindIncremental, inc1, 12)
+
+	c, err := Scan(dir)
+	if err != nil {
+		t.Fatalf("Scan returned error: %v", err)
+	}
+
+	// Храним только последний бэкап, но он зависит от всей цепочки
+	expired, err := c.Prune(Policy{KeepLast: 1}, epoch.AddDate(0, 0, 13))
+	if err != nil {
+		t.Fatalf("Prune returned error: %v", err)
+	}
+	if len(expired) != 1 || expired[0].Artifact != old {
+		t.Fatalf("Expected only %s to expire, got %v", old, expired)
+	}
+
+	expired, err = c.Prune(Policy{KeepWithin: 48 * time.Hour}, epoch.AddDate(0, 0, 13))
+	if err != nil || len(expired) != 1 {
+		t.Fatalf("Expected one expired backup, got %v, %v", expired, err)
+	}
+
+	if _, err := c.Prune(Policy{}, epoch); err == nil {
+		t.Error("Expected prune without a policy to fail")
+	}
+
+	// Артефакт другого бэкапа, имя которого начинается с имени удаляемого
+	other := old + ".sql"
+	os.WriteFile(other, []byte("data"), 0644)
+	os.WriteFile(old+".checkpoints", []byte("to_lsn = 1"), 0644)
+	if err := Remove(expired[0]); err != nil {
+		t.Fatalf("Remove returned error: %v", err)
+	}
+	for _, path := range []string{old, manifest.Path(old), old + ".checkpoints"} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("Expected %s to be removed", path)
+		}
+	}
+	for _, path := range []string{inc2, other} {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("Expected %s to be kept: %v", path, err)
+		}
+	}
+}
+
+func TestWriteTree(t *testing.T) {
+	dir := t.TempDir()
+	full := writeBackup(t, dir, "full", manifest.KindFull, "", 0)
+	writeBackup(t, dir, "inc1", manifest.KindIncremental, full, 1)
+
+	c, err := Scan(dir)
+	if err != nil {
+		t.Fatalf("Scan returned error: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := c.WriteTree(&buf); err != nil {
+		t.Fatalf("WriteTree returned error: %v", err)
+	}
+	if !strings.Contains(buf.String(), "└─ "+filepath.Join(dir, "inc1")) {
+		t.Errorf("Expected the incremental nested under its full backup:\n%s", buf.String())
+	}
+}
+
+func TestScanMissingRoot(t *testing.T) {
+	c, err := Scan(filepath.Join(t.TempDir(), "missing"))
+	if err != nil || len(c.Manifests) != 0 {
+		t.Errorf("Expected an empty catalog, got %v, %v", c, err)
+	}
+}
+
+func TestTargets(t *testing.T) {
+	c := &Catalog{Manifests: []*manifest.Manifest{
+		{Target: "srv/orders"},
+		{Target: "srv/billing"},
+		{Target: "srv/orders"},
+	}}
+	got := strings.Join(c.Targets("srv"), ",")
+	if got != "srv,srv/billing,srv/orders" {
+		t.Errorf("Expected the configured and discovered targets, got %s", got)
+	}
+}
@@ -3,7 +3,10 @@ package config
 import (
 	"fmt"
 	"log"
+	"os"
+	"regexp"
 	"strings"
+	"time"
 
 	"github.com/spf13/viper"
 )
@@ -15,29 +18,267 @@ type DatabaseConfig struct {
 	Username string `mapstructure:"username"`
 	Password string `mapstructure:"password"`
 	DBName   string `mapstructure:"dbname"`
+
+	// AllDatabases backs up every non-system database on the server instead
+	// of DBName. Include and Exclude are regular expressions applied to the
+	// discovered names; an empty Include keeps everything.
+	AllDatabases bool     `mapstructure:"all_databases"`
+	Include      []string `mapstructure:"include"`
+	Exclude      []string `mapstructure:"exclude"`
+
+	// Tables and ExcludeTables limit a backup to some tables, or collections
+	// for MongoDB.
+	Tables        []string `mapstructure:"tables"`
+	ExcludeTables []string `mapstructure:"exclude_tables"`
+
+	PostgreSQL PostgreSQLConfig `mapstructure:"postgresql"`
+	MySQL      MySQLConfig      `mapstructure:"mysql"`
+	TLS        TLSConfig        `mapstructure:"tls"`
+	MongoDB    MongoDBConfig    `mapstructure:"mongodb"`
+	SQLite     SQLiteConfig     `mapstructure:"sqlite"`
+	Redis      RedisConfig      `mapstructure:"redis"`
+	MariaDB    MariaDBConfig    `mapstructure:"mariadb"`
+}
+
+// MariaDBConfig configures physical backups of a whole server. Tool is
+// mariadb-backup (default) or xtrabackup; DataDir is where a restore copies
+// the prepared files and must be empty with the server stopped.
+type MariaDBConfig struct {
+	Tool     string `mapstructure:"tool"`
+	DataDir  string `mapstructure:"data_dir"`
+	Parallel int    `mapstructure:"parallel"`
+}
+
+// RedisConfig selects how the RDB snapshot is taken: "rdb" (default) streams
+// it with redis-cli --rdb, "bgsave" triggers BGSAVE and copies the server's
+// file, which must be readable locally. DataDir and RDBFilename tell a
+// restore where the target instance loads its dump from.
+type RedisConfig struct {
+	Mode        string `mapstructure:"mode"`
+	DataDir     string `mapstructure:"data_dir"`
+	RDBFilename string `mapstructure:"rdb_filename"`
+}
+
+// SQLiteConfig points at the database file; DBName only names the artifact.
+type SQLiteConfig struct {
+	Path string `mapstructure:"path"`
+}
+
+// MongoDBConfig holds MongoDB-specific connection settings. URI replaces
+// host and port and may use mongodb+srv; username and password are injected
+// into it. Sharded stops the balancer around the dump, which requires
+// connecting to a mongos.
+type MongoDBConfig struct {
+	URI            string `mapstructure:"uri"`
+	AuthDB         string `mapstructure:"auth_db"`
+	ReadPreference string `mapstructure:"read_preference"`
+	Sharded        bool   `mapstructure:"sharded"`
+}
+
+// TLSConfig describes an encrypted connection. Mode follows libpq naming
+// (disable, require, verify-ca, verify-full) and is translated for each
+// engine's tools. SkipVerify keeps encryption but trusts any certificate.
+type TLSConfig struct {
+	Mode       string `mapstructure:"mode"`
+	CAFile     string `mapstructure:"ca_file"`
+	CertFile   string `mapstructure:"cert_file"`
+	KeyFile    string `mapstructure:"key_file"`
+	ServerName string `mapstructure:"server_name"`
+	SkipVerify bool   `mapstructure:"skip_verify"`
+}
+
+// MySQLConfig controls mysqldump. The boolean options default to true when
+// left out, giving a consistent dump with routines, triggers and events.
+// Tool is mysqldump (default), mysqlpump, mydumper or auto; Threads sets
+// their parallelism.
+type MySQLConfig struct {
+	SingleTransaction *bool  `mapstructure:"single_transaction"`
+	Routines          *bool  `mapstructure:"routines"`
+	Triggers          *bool  `mapstructure:"triggers"`
+	Events            *bool  `mapstructure:"events"`
+	HexBlob           *bool  `mapstructure:"hex_blob"`
+	SetGTIDPurged     string `mapstructure:"set_gtid_purged"`
+	Tool              string `mapstructure:"tool"`
+	Threads           int    `mapstructure:"threads"`
+}
+
+// PostgreSQLConfig controls pg_dump and pg_restore. Format is one of plain
+// (default), custom, directory or tar; Jobs sets -j for directory dumps and
+// for pg_restore.
+type PostgreSQLConfig struct {
+	Format  string `mapstructure:"format"`
+	Jobs    int    `mapstructure:"jobs"`
+	Clean   bool   `mapstructure:"clean"`
+	NoOwner bool   `mapstructure:"no_owner"`
 }
 
 type StorageConfig struct {
-	LocalPath string `mapstructure:"local_path"`
-	CloudType string `mapstructure:"cloud_type"`
-	Bucket    string `mapstructure:"bucket"`
+	LocalPath  string           `mapstructure:"local_path"`
+	CloudType  string           `mapstructure:"cloud_type"`
+	Bucket     string           `mapstructure:"bucket"`
+	Repository RepositoryConfig `mapstructure:"repository"`
 }
 
+// RepositoryConfig moves finished artifacts into a deduplicating chunk
+// repository, by default <local_path>/repository. Only manifests stay in
+// local_path; restores fetch artifacts back from the repository.
+type RepositoryConfig struct {
+	Enabled      bool   `mapstructure:"enabled"`
+	Path         string `mapstructure:"path"`
+	AvgChunkSize int    `mapstructure:"avg_chunk_size"`
+}
+
+// LoggingConfig controls the log output. Level is the minimum level
+// written. When File grows beyond MaxSizeMB it is rotated; rotated files
+// are gzipped with Compress and removed beyond MaxBackups or after MaxAge.
 type LoggingConfig struct {
-	Level  string `mapstructure:"level"`
-	File   string `mapstructure:"file"`
-	Format string `mapstructure:"format"`
+	Level      string        `mapstructure:"level"`
+	File       string        `mapstructure:"file"`
+	Format     string        `mapstructure:"format"`
+	MaxSizeMB  int           `mapstructure:"max_size_mb"`
+	MaxAge     time.Duration `mapstructure:"max_age"`
+	MaxBackups int           `mapstructure:"max_backups"`
+	Compress   bool          `mapstructure:"compress"`
 }
 
 type NotificationConfig struct {
 	SlackWebhookURL string `mapstructure:"slack_webhook_url"`
 }
 
+// TargetConfig describes one entry of the `databases:` list. Empty fields
+// fall back to the top-level `database:` block, and the optional storage and
+// notification blocks override the shared ones field by field.
+type TargetConfig struct {
+	Name           string            `mapstructure:"name"`
+	Labels         map[string]string `mapstructure:"labels"`
+	DatabaseConfig `mapstructure:",squash"`
+
+	Storage      *StorageConfig      `mapstructure:"storage"`
+	Notification *NotificationConfig `mapstructure:"notification"`
+	Hooks        *HooksConfig        `mapstructure:"hooks"`
+}
+
+// HooksConfig lists the hooks run around a job. Post hooks run after a
+// successful job, OnFailure after a failed one.
+type HooksConfig struct {
+	PreBackup   []Hook `mapstructure:"pre_backup"`
+	PostBackup  []Hook `mapstructure:"post_backup"`
+	OnFailure   []Hook `mapstructure:"on_failure"`
+	PreRestore  []Hook `mapstructure:"pre_restore"`
+	PostRestore []Hook `mapstructure:"post_restore"`
+}
+
+// Hook runs either a shell command or a SQL statement against the target.
+// A failed hook only logs a warning unless AbortOnFailure is set, which
+// fails the job and, for pre hooks, skips it.
+type Hook struct {
+	Command        string        `mapstructure:"command"`
+	SQL            string        `mapstructure:"sql"`
+	Timeout        time.Duration `mapstructure:"timeout"`
+	AbortOnFailure bool          `mapstructure:"abort_on_failure"`
+}
+
+// ParallelConfig bounds how many targets are processed at once, overall and
+// per database host. Zero values mean one worker and no per-host limit.
+type ParallelConfig struct {
+	Workers int `mapstructure:"workers"`
+	PerHost int `mapstructure:"per_host"`
+}
+
+// DaemonConfig configures `--command daemon`, which backs up the selected
+// targets every Interval, applies the retention policy after each round and
+// serves Prometheus metrics and, if enabled, the HTTP API on Listen.
+type DaemonConfig struct {
+	Interval   time.Duration `mapstructure:"interval"`
+	Listen     string        `mapstructure:"listen"`
+	KeepLast   int           `mapstructure:"keep_last"`
+	KeepWithin time.Duration `mapstructure:"keep_within"`
+	API        APIConfig     `mapstructure:"api"`
+}
+
+// APIConfig enables the HTTP API of the daemon. Every request except the
+// health check must carry Token as a bearer token.
+type APIConfig struct {
+	Enabled bool   `mapstructure:"enabled"`
+	Token   string `mapstructure:"token"`
+}
+
+// HistoryConfig sets where finished runs are recorded.
+type HistoryConfig struct {
+	Path string `mapstructure:"path"`
+}
+
+// ProgressConfig sets how often progress of dumps and uploads is logged
+// when output is not a terminal.
+type ProgressConfig struct {
+	Interval time.Duration `mapstructure:"interval"`
+}
+
+// ThrottleLimits caps how hard backups push the network and local disks.
+// Rates are in KiB per second and zero means unlimited. Nice and the
+// ionice class (idle, best-effort or realtime) apply to dump processes.
+type ThrottleLimits struct {
+	UploadKBPerSecond int    `mapstructure:"upload_kb_per_second"`
+	WriteKBPerSecond  int    `mapstructure:"write_kb_per_second"`
+	Nice              int    `mapstructure:"nice"`
+	IONiceClass       string `mapstructure:"ionice_class"`
+	IONiceLevel       int    `mapstructure:"ionice_level"`
+}
+
+// ThrottleProfile replaces the default limits from From until To, given
+// as local HH:MM. A window may wrap around midnight, e.g. 22:00-06:00.
+type ThrottleProfile struct {
+	Name           string `mapstructure:"name"`
+	From           string `mapstructure:"from"`
+	To             string `mapstructure:"to"`
+	ThrottleLimits `mapstructure:",squash"`
+}
+
+// ThrottleConfig holds the default limits and the profiles that override
+// them at certain times of day; the first matching profile wins.
+type ThrottleConfig struct {
+	ThrottleLimits `mapstructure:",squash"`
+	Profiles       []ThrottleProfile `mapstructure:"profiles"`
+}
+
+// SanityConfig flags backups that look wrong compared to the last Baseline
+// successful backups of the same target and kind. A check is skipped while
+// its threshold is zero. Policy is warn or fail; empty disables the checks.
+type SanityConfig struct {
+	Policy                string `mapstructure:"policy"`
+	Baseline              int    `mapstructure:"baseline"`
+	MinSizeBytes          int64  `mapstructure:"min_size_bytes"`
+	SizeChangePercent     int    `mapstructure:"size_change_percent"`
+	TableChangePercent    int    `mapstructure:"table_change_percent"`
+	DurationChangePercent int    `mapstructure:"duration_change_percent"`
+}
+
+// LockConfig sets how jobs on the same target exclude each other: a lock
+// file per target for a single host, or a lease file with a TTL on a POSIX
+// filesystem that every host mounts, such as NFS, with hard link support.
+// Path defaults to locks in the local storage for file locks and must be
+// set for leases; Wait is how long a job waits for a busy lock.
+type LockConfig struct {
+	Type string        `mapstructure:"type"`
+	Path string        `mapstructure:"path"`
+	TTL  time.Duration `mapstructure:"ttl"`
+	Wait time.Duration `mapstructure:"wait"`
+}
+
 type Config struct {
 	Database     DatabaseConfig     `mapstructure:"database"`
+	Databases    []TargetConfig     `mapstructure:"databases"`
 	Storage      StorageConfig      `mapstructure:"storage"`
 	Logging      LoggingConfig      `mapstructure:"logging"`
 	Notification NotificationConfig `mapstructure:"notification"`
+	Parallel     ParallelConfig     `mapstructure:"parallel"`
+	Hooks        HooksConfig        `mapstructure:"hooks"`
+	Daemon       DaemonConfig       `mapstructure:"daemon"`
+	History      HistoryConfig      `mapstructure:"history"`
+	Progress     ProgressConfig     `mapstructure:"progress"`
+	Throttle     ThrottleConfig     `mapstructure:"throttle"`
+	Sanity       SanityConfig       `mapstructure:"sanity"`
+	Lock         LockConfig         `mapstructure:"lock"`
 }
 
 func LoadConfig(path string) (*Config, error) {
@@ -62,21 +303,36 @@ func LoadConfig(path string) (*Config, error) {
 
 	log.Printf("Loaded config: %+v", cfg)
 
-	if cfg.Database.Host == "" {
-		return nil, fmt.Errorf("database host is required")
-	}
-	if cfg.Database.DBName == "" {
-		return nil, fmt.Errorf("database name is required")
-	}
+	if len(cfg.Databases) == 0 {
+		if err := validateDatabase(cfg.Database); err != nil {
+			return nil, err
+		}
+	} else {
+		names := make(map[string]bool)
+		for _, target := range cfg.Targets() {
+			if target.Name == "" {
+				return nil, fmt.Errorf("target name is required")
+			}
+			if names[target.Name] {
+				return nil, fmt.Errorf("duplicate target name: %s", target.Name)
+			}
+			names[target.Name] = true
 
-	validDatabaseTypes := map[string]bool{
-		"mysql":      true,
-		"postgresql": true,
-		"mongodb":    true,
+			if err := validateDatabase(target.Database); err != nil {
+				return nil, fmt.Errorf("target %s: %w", target.Name, err)
+			}
+			if err := validateStorage(target.Storage); err != nil {
+				return nil, fmt.Errorf("target %s: %w", target.Name, err)
+			}
+		}
 	}
-	if !validDatabaseTypes[cfg.Database.Type] {
-		return nil, fmt.Errorf("invalid database type: %s", cfg.Database.Type)
+
+	for _, target := range cfg.Targets() {
+		if err := validateHooks(target.Hooks); err != nil {
+			return nil, fmt.Errorf("target %s: %w", target.Name, err)
+		}
 	}
+
 	validLoggingLevels := map[string]bool{
 		"info":  true,
 		"debug": true,
@@ -86,10 +342,281 @@ func LoadConfig(path string) (*Config, error) {
 	if !validLoggingLevels[cfg.Logging.Level] {
 		return nil, fmt.Errorf("invalid logging level: %s", cfg.Logging.Level)
 	}
+	if cfg.Logging.Format != "" && cfg.Logging.Format != "text" && cfg.Logging.Format != "json" {
+		return nil, fmt.Errorf("invalid logging format: %s", cfg.Logging.Format)
+	}
+	if cfg.Logging.MaxSizeMB < 0 || cfg.Logging.MaxAge < 0 || cfg.Logging.MaxBackups < 0 {
+		return nil, fmt.Errorf("logging rotation limits must not be negative")
+	}
+	if cfg.Daemon.Interval < 0 || cfg.Daemon.KeepLast < 0 || cfg.Daemon.KeepWithin < 0 {
+		return nil, fmt.Errorf("daemon interval and retention must not be negative")
+	}
+	if cfg.Progress.Interval < 0 {
+		return nil, fmt.Errorf("progress interval must not be negative")
+	}
+	if cfg.Daemon.API.Enabled && (cfg.Daemon.API.Token == "" || cfg.Daemon.Listen == "") {
+		return nil, fmt.Errorf("daemon api requires daemon.listen and daemon.api.token")
+	}
 
-	if cfg.Storage.CloudType != "s3" && cfg.Storage.CloudType != "gcs" {
-		return nil, fmt.Errorf("invalid cloud type: %s", cfg.Storage.CloudType)
+	if err := validateStorage(cfg.Storage); err != nil {
+		return nil, err
+	}
+	if err := validateThrottle(cfg.Throttle); err != nil {
+		return nil, err
+	}
+	if err := validateSanity(cfg.Sanity); err != nil {
+		return nil, err
+	}
+	if err := validateLock(cfg.Lock); err != nil {
+		return nil, err
+	}
+
+	if cfg.Parallel.Workers < 0 || cfg.Parallel.PerHost < 0 {
+		return nil, fmt.Errorf("parallel limits must not be negative")
 	}
 
 	return &cfg, nil
 }
+
+func validateDatabase(db DatabaseConfig) error {
+	if db.Type == "sqlite" {
+		if db.SQLite.Path == "" {
+			return fmt.Errorf("sqlite path is required")
+		}
+		if db.AllDatabases {
+			return fmt.Errorf("all_databases is not supported for sqlite")
+		}
+	} else if (db.Type == "redis" || db.Type == "mariadb") && db.AllDatabases {
+		return fmt.Errorf("all_databases is not supported for %s", db.Type)
+	} else if db.Host == "" && !(db.Type == "mongodb" && db.MongoDB.URI != "") {
+		return fmt.Errorf("database host is required")
+	}
+	if db.DBName == "" && !db.AllDatabases {
+		return fmt.Errorf("database name is required")
+	}
+	for _, pattern := range append(append([]string{}, db.Include...), db.Exclude...) {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return fmt.Errorf("invalid database filter %q: %w", pattern, err)
+		}
+	}
+
+	validDatabaseTypes := map[string]bool{
+		"mysql":      true,
+		"postgresql": true,
+		"mongodb":    true,
+		"sqlite":     true,
+		"redis":      true,
+		"mariadb":    true,
+	}
+	if !validDatabaseTypes[db.Type] {
+		return fmt.Errorf("invalid database type: %s", db.Type)
+	}
+
+	validPostgreSQLFormats := map[string]bool{
+		"":          true,
+		"plain":     true,
+		"custom":    true,
+		"directory": true,
+		"tar":       true,
+	}
+	if !validPostgreSQLFormats[db.PostgreSQL.Format] {
+		return fmt.Errorf("invalid postgresql format: %s", db.PostgreSQL.Format)
+	}
+	if db.PostgreSQL.Jobs < 0 {
+		return fmt.Errorf("postgresql jobs must not be negative")
+	}
+
+	validMySQLTools := map[string]bool{
+		"":          true,
+		"mysqldump": true,
+		"mysqlpump": true,
+		"mydumper":  true,
+		"auto":      true,
+	}
+	if !validMySQLTools[db.MySQL.Tool] {
+		return fmt.Errorf("invalid mysql tool: %s", db.MySQL.Tool)
+	}
+	validGTIDPurged := map[string]bool{
+		"":          true,
+		"AUTO":      true,
+		"ON":        true,
+		"OFF":       true,
+		"COMMENTED": true,
+	}
+	if !validGTIDPurged[strings.ToUpper(db.MySQL.SetGTIDPurged)] {
+		return fmt.Errorf("invalid mysql set_gtid_purged: %s", db.MySQL.SetGTIDPurged)
+	}
+	if db.MySQL.Threads < 0 {
+		return fmt.Errorf("mysql threads must not be negative")
+	}
+
+	if err := validateMongoDB(db.MongoDB); err != nil {
+		return err
+	}
+	if db.Redis.Mode != "" && db.Redis.Mode != "rdb" && db.Redis.Mode != "bgsave" {
+		return fmt.Errorf("invalid redis mode: %s", db.Redis.Mode)
+	}
+	if db.MariaDB.Tool != "" && db.MariaDB.Tool != "mariadb-backup" && db.MariaDB.Tool != "xtrabackup" {
+		return fmt.Errorf("invalid mariadb tool: %s", db.MariaDB.Tool)
+	}
+	if db.MariaDB.Parallel < 0 {
+		return fmt.Errorf("mariadb parallel must not be negative")
+	}
+
+	return validateTLS(db.Type, db.TLS)
+}
+
+func validateMongoDB(mongo MongoDBConfig) error {
+	if mongo.URI != "" && !strings.HasPrefix(mongo.URI, "mongodb://") && !strings.HasPrefix(mongo.URI, "mongodb+srv://") {
+		return fmt.Errorf("invalid mongodb uri: must start with mongodb:// or mongodb+srv://")
+	}
+
+	validReadPreferences := map[string]bool{
+		"":                   true,
+		"primary":            true,
+		"primaryPreferred":   true,
+		"secondary":          true,
+		"secondaryPreferred": true,
+		"nearest":            true,
+	}
+	if !validReadPreferences[mongo.ReadPreference] {
+		return fmt.Errorf("invalid mongodb read_preference: %s", mongo.ReadPreference)
+	}
+	return nil
+}
+
+func validateTLS(dbType string, tls TLSConfig) error {
+	validModes := map[string]bool{
+		"":            true,
+		"disable":     true,
+		"require":     true,
+		"verify-ca":   true,
+		"verify-full": true,
+	}
+	if !validModes[tls.Mode] {
+		return fmt.Errorf("invalid tls mode: %s", tls.Mode)
+	}
+	if tls.SkipVerify && strings.HasPrefix(tls.Mode, "verify-") {
+		return fmt.Errorf("tls skip_verify cannot be combined with mode %s", tls.Mode)
+	}
+	if tls.KeyFile != "" && tls.CertFile == "" {
+		return fmt.Errorf("tls key_file requires cert_file")
+	}
+	if tls.CertFile != "" && tls.KeyFile == "" && dbType != "mongodb" {
+		return fmt.Errorf("tls cert_file requires key_file")
+	}
+	if tls.ServerName != "" && dbType != "postgresql" && dbType != "redis" {
+		return fmt.Errorf("tls server_name is only supported for postgresql and redis")
+	}
+	for _, file := range []string{tls.CAFile, tls.CertFile, tls.KeyFile} {
+		if file == "" {
+			continue
+		}
+		if _, err := os.Stat(file); err != nil {
+			return fmt.Errorf("tls file: %w", err)
+		}
+	}
+	return nil
+}
+
+func validateHooks(hooks HooksConfig) error {
+	stages := map[string][]Hook{
+		"pre_backup":   hooks.PreBackup,
+		"post_backup":  hooks.PostBackup,
+		"on_failure":   hooks.OnFailure,
+		"pre_restore":  hooks.PreRestore,
+		"post_restore": hooks.PostRestore,
+	}
+	for stage, list := range stages {
+		for _, hook := range list {
+			if (hook.Command == "") == (hook.SQL == "") {
+				return fmt.Errorf("%s hook needs exactly one of command or sql", stage)
+			}
+			if hook.Timeout < 0 {
+				return fmt.Errorf("%s hook timeout must not be negative", stage)
+			}
+		}
+	}
+	return nil
+}
+
+func validateSanity(sanity SanityConfig) error {
+	if sanity.Policy != "" && sanity.Policy != "warn" && sanity.Policy != "fail" {
+		return fmt.Errorf("invalid sanity policy: %s", sanity.Policy)
+	}
+	if sanity.Baseline < 0 || sanity.MinSizeBytes < 0 || sanity.SizeChangePercent < 0 ||
+		sanity.TableChangePercent < 0 || sanity.DurationChangePercent < 0 {
+		return fmt.Errorf("sanity thresholds must not be negative")
+	}
+	return nil
+}
+
+// validateLock checks the lock type and durations. Leases are files
+// created with hard links on the shared filesystem at path, so path must
+// name one explicitly; object storage is not supported.
+func validateLock(lock LockConfig) error {
+	switch lock.Type {
+	case "", "file", "none":
+	case "lease":
+		if lock.Path == "" {
+			return fmt.Errorf("lock type lease needs lock.path on a filesystem shared by all hosts")
+		}
+	default:
+		return fmt.Errorf("invalid lock type: %s", lock.Type)
+	}
+	if lock.TTL < 0 || lock.Wait < 0 {
+		return fmt.Errorf("lock ttl and wait must not be negative")
+	}
+	return nil
+}
+
+func validateThrottle(throttle ThrottleConfig) error {
+	if err := validateThrottleLimits(throttle.ThrottleLimits); err != nil {
+		return err
+	}
+	for _, profile := range throttle.Profiles {
+		from, err := time.Parse("15:04", profile.From)
+		if err != nil {
+			return fmt.Errorf("throttle profile %q: invalid from time %q", profile.Name, profile.From)
+		}
+		to, err := time.Parse("15:04", profile.To)
+		if err != nil {
+			return fmt.Errorf("throttle profile %q: invalid to time %q", profile.Name, profile.To)
+		}
+		if from.Equal(to) {
+			return fmt.Errorf("throttle profile %q: from and to must differ", profile.Name)
+		}
+		if err := validateThrottleLimits(profile.ThrottleLimits); err != nil {
+			return fmt.Errorf("throttle profile %q: %w", profile.Name, err)
+		}
+	}
+	return nil
+}
+
+func validateThrottleLimits(limits ThrottleLimits) error {
+	if limits.UploadKBPerSecond < 0 || limits.WriteKBPerSecond < 0 {
+		return fmt.Errorf("throttle rates must not be negative")
+	}
+	if limits.Nice < -20 || limits.Nice > 19 {
+		return fmt.Errorf("throttle nice must be between -20 and 19")
+	}
+	switch limits.IONiceClass {
+	case "", "idle", "best-effort", "realtime":
+	default:
+		return fmt.Errorf("invalid throttle ionice_class: %s", limits.IONiceClass)
+	}
+	if limits.IONiceLevel < 0 || limits.IONiceLevel > 7 {
+		return fmt.Errorf("throttle ionice_level must be between 0 and 7")
+	}
+	return nil
+}
+
+func validateStorage(storage StorageConfig) error {
+	if storage.CloudType != "s3" && storage.CloudType != "gcs" {
+		return fmt.Errorf("invalid cloud type: %s", storage.CloudType)
+	}
+	if size := storage.Repository.AvgChunkSize; size != 0 && (size < 64<<10 || size > 64<<20) {
+		return fmt.Errorf("repository avg_chunk_size must be between 64 KiB and 64 MiB")
+	}
+	return nil
+}
@@ -2,8 +2,10 @@ package config
 
 import (
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestLoadConfigFromFile(t *testing.T) {
@@ -151,3 +153,100 @@ func TestLoadConfigInvalidDatabasePort(t *testing.T) {
 		t.Errorf("Expected error for invalid database port, got %v", err)
 	}
 }
+
+func TestValidateTLS(t *testing.T) {
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	os.WriteFile(caFile, []byte("test"), 0644)
+
+	tests := []struct {
+		dbType  string
+		tls     TLSConfig
+		wantErr bool
+	}{
+		{"mysql", TLSConfig{}, false},
+		{"mysql", TLSConfig{Mode: "verify-full", CAFile: caFile}, false},
+		{"mysql", TLSConfig{Mode: "sometimes"}, true},
+		{"mysql", TLSConfig{Mode: "verify-ca", SkipVerify: true}, true},
+		{"mysql", TLSConfig{Mode: "require", CAFile: "missing.pem"}, true},
+		{"mysql", TLSConfig{Mode: "require", CertFile: caFile}, true},
+		{"mongodb", TLSConfig{Mode: "require", CertFile: caFile}, false},
+		{"mysql", TLSConfig{Mode: "verify-full", ServerName: "db.internal"}, true},
+		{"postgresql", TLSConfig{Mode: "verify-full", ServerName: "db.internal"}, false},
+	}
+
+	for _, tt := range tests {
+		err := validateTLS(tt.dbType, tt.tls)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("validateTLS(%s, %+v): expected error %v, got %v", tt.dbType, tt.tls, tt.wantErr, err)
+		}
+	}
+}
+
+func TestValidateHooks(t *testing.T) {
+	tests := []struct {
+		hooks   HooksConfig
+		wantErr bool
+	}{
+		{HooksConfig{}, false},
+		{HooksConfig{PreBackup: []Hook{{Command: "systemctl stop consumer", AbortOnFailure: true}}}, false},
+		{HooksConfig{PostRestore: []Hook{{SQL: "ANALYZE"}}}, false},
+		{HooksConfig{PostBackup: []Hook{{}}}, true},
+		{HooksConfig{OnFailure: []Hook{{Command: "true", SQL: "SELECT 1"}}}, true},
+		{HooksConfig{PreRestore: []Hook{{Command: "true", Timeout: -1}}}, true},
+	}
+
+	for _, tt := range tests {
+		err := validateHooks(tt.hooks)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("validateHooks(%+v): expected error %v, got %v", tt.hooks, tt.wantErr, err)
+		}
+	}
+}
+
+func TestValidateThrottle(t *testing.T) {
+	tests := []struct {
+		throttle ThrottleConfig
+		wantErr  bool
+	}{
+		{ThrottleConfig{}, false},
+		{ThrottleConfig{
+			ThrottleLimits: ThrottleLimits{UploadKBPerSecond: 10240, Nice: 10, IONiceClass: "idle"},
+			Profiles:       []ThrottleProfile{{Name: "night", From: "22:00", To: "06:00"}},
+		}, false},
+		{ThrottleConfig{ThrottleLimits: ThrottleLimits{WriteKBPerSecond: -1}}, true},
+		{ThrottleConfig{ThrottleLimits: ThrottleLimits{Nice: 20}}, true},
+		{ThrottleConfig{ThrottleLimits: ThrottleLimits{IONiceClass: "low"}}, true},
+		{ThrottleConfig{ThrottleLimits: ThrottleLimits{IONiceClass: "best-effort", IONiceLevel: 8}}, true},
+		{ThrottleConfig{Profiles: []ThrottleProfile{{Name: "day", From: "8:00am", To: "20:00"}}}, true},
+		{ThrottleConfig{Profiles: []ThrottleProfile{{Name: "day", From: "08:00", To: "08:00"}}}, true},
+	}
+
+	for _, tt := range tests {
+		err := validateThrottle(tt.throttle)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("validateThrottle(%+v): expected error %v, got %v", tt.throttle, tt.wantErr, err)
+		}
+	}
+}
+
+func TestValidateLock(t *testing.T) {
+	tests := []struct {
+		lock    LockConfig
+		wantErr bool
+	}{
+		{LockConfig{}, false},
+		{LockConfig{Type: "file", Wait: time.Minute}, false},
+		{LockConfig{Type: "lease", Path: "/mnt/backups/locks", TTL: 2 * time.Minute}, false},
+		// Аренда без явного пути легла бы в локальный каталог одного хоста
+		{LockConfig{Type: "lease"}, true},
+		{LockConfig{Type: "s3"}, true},
+		{LockConfig{TTL: -time.Second}, true},
+	}
+
+	for _, tt := range tests {
+		err := validateLock(tt.lock)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("validateLock(%+v): expected error %v, got %v", tt.lock, tt.wantErr, err)
+		}
+	}
+}
@@ -0,0 +1,328 @@
+package config
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Target is a fully resolved backup target: the shared defaults merged with
+// the per-target overrides from the `databases:` list.
+type Target struct {
+	Name         string
+	Labels       map[string]string
+	Database     DatabaseConfig
+	Storage      StorageConfig
+	Notification NotificationConfig
+	Hooks        HooksConfig
+}
+
+// Targets returns the configured targets. A config without a `databases:`
+// list yields a single target built from the `database:` block and named
+// after its database.
+func (c *Config) Targets() []Target {
+	if len(c.Databases) == 0 {
+		name := c.Database.DBName
+		if name == "" {
+			name = c.Database.Host
+		}
+		return []Target{{
+			Name:         name,
+			Database:     c.Database,
+			Storage:      c.Storage,
+			Notification: c.Notification,
+			Hooks:        c.Hooks,
+		}}
+	}
+
+	targets := make([]Target, 0, len(c.Databases))
+	for _, t := range c.Databases {
+		target := Target{
+			Name:         t.Name,
+			Labels:       t.Labels,
+			Database:     mergeDatabase(c.Database, t.DatabaseConfig),
+			Storage:      c.Storage,
+			Notification: c.Notification,
+			Hooks:        c.Hooks,
+		}
+		if t.Hooks != nil {
+			target.Hooks = *t.Hooks
+		}
+		if t.Storage != nil {
+			target.Storage = mergeStorage(c.Storage, *t.Storage)
+		}
+		if t.Notification != nil && t.Notification.SlackWebhookURL != "" {
+			target.Notification.SlackWebhookURL = t.Notification.SlackWebhookURL
+		}
+		targets = append(targets, target)
+	}
+	return targets
+}
+
+// Params builds the parameter map passed to the database engines. An empty
+// backupFile leaves the choice of the artifact path to the engine, which
+// places it under the target's directory in storage.local_path.
+func (t Target) Params(backupFile string) map[string]string {
+	params := map[string]string{
+		"host":        t.Database.Host,
+		"port":        strconv.Itoa(t.Database.Port),
+		"username":    t.Database.Username,
+		"password":    t.Database.Password,
+		"dbname":      t.Database.DBName,
+		"backup-file": backupFile,
+	}
+	if len(t.Database.Tables) > 0 {
+		params["tables"] = strings.Join(t.Database.Tables, ",")
+	}
+	if len(t.Database.ExcludeTables) > 0 {
+		params["exclude-tables"] = strings.Join(t.Database.ExcludeTables, ",")
+	}
+	tls := t.Database.TLS
+	params["tls-mode"] = tls.Mode
+	params["tls-ca"] = tls.CAFile
+	params["tls-cert"] = tls.CertFile
+	params["tls-key"] = tls.KeyFile
+	params["tls-server-name"] = tls.ServerName
+	params["tls-skip-verify"] = strconv.FormatBool(tls.SkipVerify)
+
+	if t.Database.Type == "postgresql" {
+		pg := t.Database.PostgreSQL
+		params["format"] = pg.Format
+		if pg.Jobs > 0 {
+			params["jobs"] = strconv.Itoa(pg.Jobs)
+		}
+		params["clean"] = strconv.FormatBool(pg.Clean)
+		params["no-owner"] = strconv.FormatBool(pg.NoOwner)
+	}
+	if t.Database.Type == "mongodb" {
+		mongo := t.Database.MongoDB
+		params["uri"] = mongo.URI
+		params["auth-db"] = mongo.AuthDB
+		params["read-preference"] = mongo.ReadPreference
+		params["sharded"] = strconv.FormatBool(mongo.Sharded)
+	}
+	if t.Database.Type == "sqlite" {
+		params["path"] = t.Database.SQLite.Path
+	}
+	if t.Database.Type == "redis" {
+		redis := t.Database.Redis
+		params["redis-mode"] = redis.Mode
+		params["data-dir"] = redis.DataDir
+		params["rdb-filename"] = redis.RDBFilename
+	}
+	if t.Database.Type == "mariadb" {
+		maria := t.Database.MariaDB
+		params["physical-tool"] = maria.Tool
+		params["data-dir"] = maria.DataDir
+		if maria.Parallel > 0 {
+			params["threads"] = strconv.Itoa(maria.Parallel)
+		}
+	}
+	if t.Database.Type == "mysql" {
+		my := t.Database.MySQL
+		for key, value := range map[string]*bool{
+			"single-transaction": my.SingleTransaction,
+			"routines":           my.Routines,
+			"triggers":           my.Triggers,
+			"events":             my.Events,
+			"hex-blob":           my.HexBlob,
+		} {
+			if value != nil {
+				params[key] = strconv.FormatBool(*value)
+			}
+		}
+		params["set-gtid-purged"] = my.SetGTIDPurged
+		params["dump-tool"] = my.Tool
+		if my.Threads > 0 {
+			params["threads"] = strconv.Itoa(my.Threads)
+		}
+	}
+	if t.Storage.LocalPath != "" {
+		params["backup-dir"] = filepath.Join(t.Storage.LocalPath, t.Name)
+	}
+	return params
+}
+
+// SelectTargets filters targets by selectors. A selector of the form
+// `key=value` matches a label, anything else matches the target name; both
+// the name and the label value may be glob patterns. No selectors selects
+// every target.
+func SelectTargets(targets []Target, selectors []string) ([]Target, error) {
+	if len(selectors) == 0 {
+		return targets, nil
+	}
+
+	var selected []Target
+	for _, target := range targets {
+		for _, selector := range selectors {
+			ok, err := target.matches(selector)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				selected = append(selected, target)
+				break
+			}
+		}
+	}
+
+	if len(selected) == 0 {
+		return nil, fmt.Errorf("no targets match: %s", strings.Join(selectors, ", "))
+	}
+	return selected, nil
+}
+
+// ParseSelectors splits a comma-separated --target flag value.
+func ParseSelectors(value string) []string {
+	var selectors []string
+	for _, s := range strings.Split(value, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			selectors = append(selectors, s)
+		}
+	}
+	return selectors
+}
+
+func (t Target) matches(selector string) (bool, error) {
+	if key, pattern, ok := strings.Cut(selector, "="); ok {
+		value, exists := t.Labels[key]
+		if !exists {
+			return false, nil
+		}
+		return matchGlob(pattern, value)
+	}
+	return matchGlob(selector, t.Name)
+}
+
+func matchGlob(pattern, value string) (bool, error) {
+	ok, err := path.Match(pattern, value)
+	if err != nil {
+		return false, fmt.Errorf("invalid target pattern %q: %w", pattern, err)
+	}
+	return ok, nil
+}
+
+func mergeDatabase(base, override DatabaseConfig) DatabaseConfig {
+	merged := base
+	if override.Type != "" {
+		merged.Type = override.Type
+	}
+	if override.Host != "" {
+		merged.Host = override.Host
+	}
+	if override.Port != 0 {
+		merged.Port = override.Port
+	}
+	if override.Username != "" {
+		merged.Username = override.Username
+	}
+	if override.Password != "" {
+		merged.Password = override.Password
+	}
+	if override.DBName != "" {
+		merged.DBName = override.DBName
+	}
+	if override.AllDatabases {
+		merged.AllDatabases = true
+	}
+	if override.Include != nil {
+		merged.Include = override.Include
+	}
+	if override.Exclude != nil {
+		merged.Exclude = override.Exclude
+	}
+	if override.Tables != nil {
+		merged.Tables = override.Tables
+	}
+	if override.ExcludeTables != nil {
+		merged.ExcludeTables = override.ExcludeTables
+	}
+	if override.PostgreSQL != (PostgreSQLConfig{}) {
+		merged.PostgreSQL = override.PostgreSQL
+	}
+	if override.MySQL != (MySQLConfig{}) {
+		merged.MySQL = override.MySQL
+	}
+	if override.TLS != (TLSConfig{}) {
+		merged.TLS = override.TLS
+	}
+	if override.MongoDB != (MongoDBConfig{}) {
+		merged.MongoDB = override.MongoDB
+	}
+	if override.SQLite != (SQLiteConfig{}) {
+		merged.SQLite = override.SQLite
+	}
+	if override.Redis != (RedisConfig{}) {
+		merged.Redis = override.Redis
+	}
+	if override.MariaDB != (MariaDBConfig{}) {
+		merged.MariaDB = override.MariaDB
+	}
+	return merged
+}
+
+func mergeStorage(base, override StorageConfig) StorageConfig {
+	merged := base
+	if override.LocalPath != "" {
+		merged.LocalPath = override.LocalPath
+	}
+	if override.CloudType != "" {
+		merged.CloudType = override.CloudType
+	}
+	if override.Bucket != "" {
+		merged.Bucket = override.Bucket
+	}
+	if override.Repository != (RepositoryConfig{}) {
+		merged.Repository = override.Repository
+	}
+	return merged
+}
+
+// ForDatabase returns a copy of a server-wide target narrowed to one
+// discovered database, named <target>/<dbname>.
+func (t Target) ForDatabase(dbname string) Target {
+	narrowed := t
+	narrowed.Name = t.Name + "/" + dbname
+	narrowed.Database.DBName = dbname
+	narrowed.Database.AllDatabases = false
+	return narrowed
+}
+
+// FilterDatabases applies the target's include and exclude expressions to
+// discovered database names. The patterns are validated by LoadConfig.
+func (t Target) FilterDatabases(names []string) []string {
+	include := compileAll(t.Database.Include)
+	exclude := compileAll(t.Database.Exclude)
+
+	var filtered []string
+	for _, name := range names {
+		if len(include) > 0 && !matchAny(include, name) {
+			continue
+		}
+		if matchAny(exclude, name) {
+			continue
+		}
+		filtered = append(filtered, name)
+	}
+	return filtered
+}
+
+func compileAll(patterns []string) []*regexp.Regexp {
+	compiled := make([]*regexp.Regexp, 0, len(patterns))
+	for _, pattern := range patterns {
+		compiled = append(compiled, regexp.MustCompile(pattern))
+	}
+	return compiled
+}
+
+func matchAny(patterns []*regexp.Regexp, name string) bool {
+	for _, re := range patterns {
+		if re.MatchString(name) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,129 @@
+package config
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestTargetsFromDatabasesList(t *testing.T) {
+	cfg, err := LoadConfig("test_config_targets.yaml")
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	targets := cfg.Targets()
+	if len(targets) != 3 {
+		t.Fatalf("Expected 3 targets, got %d", len(targets))
+	}
+
+	// Общие значения берутся из блока database, переопределения — из цели
+	if targets[0].Database.Host != "db.internal" || targets[0].Database.Username != "backup" {
+		t.Errorf("Expected shared connection settings, got %+v", targets[0].Database)
+	}
+	if targets[1].Database.Host != "db-us.internal" {
+		t.Errorf("Expected host override 'db-us.internal', got '%s'", targets[1].Database.Host)
+	}
+	if targets[2].Database.Port != 5432 {
+		t.Errorf("Expected port override 5432, got %d", targets[2].Database.Port)
+	}
+	if targets[2].Storage.LocalPath != "/backups/billing" || targets[2].Storage.Bucket != "test-bucket" {
+		t.Errorf("Expected merged storage settings, got %+v", targets[2].Storage)
+	}
+
+	params := targets[0].Params("")
+	if params["backup-dir"] != filepath.Join("/backups", "orders-eu") {
+		t.Errorf("Expected backup-dir under the target name, got '%s'", params["backup-dir"])
+	}
+}
+
+func TestTargetsFromSingleDatabase(t *testing.T) {
+	cfg, err := LoadConfig("test_config.yaml")
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	targets := cfg.Targets()
+	if len(targets) != 1 || targets[0].Name != "test_db" {
+		t.Fatalf("Expected a single target named 'test_db', got %+v", targets)
+	}
+}
+
+func TestSelectTargets(t *testing.T) {
+	targets := []Target{
+		{Name: "orders-eu", Labels: map[string]string{"team": "sales"}},
+		{Name: "orders-us", Labels: map[string]string{"team": "sales"}},
+		{Name: "billing", Labels: map[string]string{"team": "finance"}},
+	}
+
+	tests := []struct {
+		selectors []string
+		expected  int
+	}{
+		{nil, 3},
+		{[]string{"billing"}, 1},
+		{[]string{"orders-*"}, 2},
+		{[]string{"team=finance"}, 1},
+		{[]string{"team=*"}, 3},
+		{[]string{"billing", "orders-eu"}, 2},
+	}
+
+	for _, tt := range tests {
+		selected, err := SelectTargets(targets, tt.selectors)
+		if err != nil {
+			t.Errorf("SelectTargets(%v) returned error: %v", tt.selectors, err)
+			continue
+		}
+		if len(selected) != tt.expected {
+			t.Errorf("SelectTargets(%v): expected %d targets, got %d", tt.selectors, tt.expected, len(selected))
+		}
+	}
+
+	if _, err := SelectTargets(targets, []string{"missing-*"}); err == nil {
+		t.Error("Expected error when no targets match, got nil")
+	}
+}
+
+func TestFilterDatabases(t *testing.T) {
+	target := Target{
+		Name: "server",
+		Database: DatabaseConfig{
+			AllDatabases: true,
+			Include:      []string{"^orders", "^billing$"},
+			Exclude:      []string{"_tmp$"},
+		},
+	}
+
+	filtered := target.FilterDatabases([]string{"orders", "orders_tmp", "billing", "billing_old", "crm"})
+	if len(filtered) != 2 || filtered[0] != "orders" || filtered[1] != "billing" {
+		t.Errorf("Expected [orders billing], got %v", filtered)
+	}
+
+	narrowed := target.ForDatabase("orders")
+	if narrowed.Name != "server/orders" || narrowed.Database.DBName != "orders" || narrowed.Database.AllDatabases {
+		t.Errorf("Unexpected narrowed target: %+v", narrowed)
+	}
+}
+
+func TestMySQLOptionParams(t *testing.T) {
+	disabled := false
+	target := Target{
+		Name: "shop",
+		Database: DatabaseConfig{
+			Type:   "mysql",
+			DBName: "shop",
+			MySQL:  MySQLConfig{Events: &disabled, Tool: "auto", Threads: 4},
+		},
+	}
+
+	params := target.Params("")
+	if params["events"] != "false" {
+		t.Errorf("Expected events to be disabled, got '%s'", params["events"])
+	}
+	// Незаданные опции не попадают в параметры и по умолчанию включены
+	if _, ok := params["routines"]; ok {
+		t.Errorf("Expected routines to be left to the engine default, got '%s'", params["routines"])
+	}
+	if params["dump-tool"] != "auto" || params["threads"] != "4" {
+		t.Errorf("Unexpected tool params: %s %s", params["dump-tool"], params["threads"])
+	}
+}
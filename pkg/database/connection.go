@@ -1,12 +1,18 @@
 package database
 
 import (
+	"context"
 	"errors"
+	"path/filepath"
 
+	"github.com/itocode21/backup-tool/pkg/database/mariadb"
 	"github.com/itocode21/backup-tool/pkg/database/mongodb"
 	"github.com/itocode21/backup-tool/pkg/database/mysql"
 	"github.com/itocode21/backup-tool/pkg/database/postgresql"
+	"github.com/itocode21/backup-tool/pkg/database/redis"
+	"github.com/itocode21/backup-tool/pkg/database/sqlite"
 	"github.com/itocode21/backup-tool/pkg/logging"
+	"github.com/itocode21/backup-tool/pkg/throttle"
 )
 
 type Backup interface {
@@ -14,6 +20,36 @@ type Backup interface {
 	RestoreBackup(config map[string]string) error
 }
 
+// Discoverer is implemented by engines that can list the databases on a
+// server for server-wide backups. System databases are left out.
+type Discoverer interface {
+	ListDatabases(config map[string]string) ([]string, error)
+}
+
+// Inspector is implemented by engines that can tell whether a restore
+// target already holds data.
+type Inspector interface {
+	HasData(config map[string]string) (bool, error)
+}
+
+// Executor is implemented by engines that can run a statement against the
+// database in config, for SQL hooks. The client is killed when ctx is done.
+type Executor interface {
+	Exec(ctx context.Context, config map[string]string, statement string) error
+}
+
+// WriteLimiter is implemented by engines that copy the dump tool's output
+// into the artifact themselves, so their local writes can be paced.
+type WriteLimiter interface {
+	LimitWrites(l *throttle.Limiter)
+}
+
+// TableCounter is implemented by engines that can count the tables
+// (collections for MongoDB) in the artifact of a finished backup.
+type TableCounter interface {
+	CountTables(config map[string]string) (int, error)
+}
+
 func NewBackup(dbType string, logger *logging.Logger) (Backup, error) {
 	switch dbType {
 	case "mysql":
@@ -22,9 +58,54 @@ func NewBackup(dbType string, logger *logging.Logger) (Backup, error) {
 		return &postgresql.PostgreSQLBackup{Logger: logger}, nil
 	case "mongodb":
 		return &mongodb.MongoDBBackup{Logger: logger}, nil
+	case "sqlite":
+		return &sqlite.SQLiteBackup{Logger: logger}, nil
+	case "redis":
+		return &redis.RedisBackup{Logger: logger}, nil
+	case "mariadb":
+		return &mariadb.MariaDBBackup{Logger: logger}, nil
 	default:
 		return nil, ErrUnsupportedDBType
 	}
 }
 
-var ErrUnsupportedDBType = errors.New("unsupported database type")
+var (
+	ErrUnsupportedDBType = errors.New("unsupported database type")
+	ErrTargetNotEmpty    = errors.New("restore target is not empty, use --force to overwrite it")
+)
+
+// ArtifactPath returns where the engine of dbType stores the backup described
+// by config: the explicit backup-file, or the engine's default location.
+func ArtifactPath(dbType string, config map[string]string) (string, error) {
+	switch dbType {
+	case "mysql":
+		if config["backup-file"] != "" {
+			return config["backup-file"], nil
+		}
+		return mysql.DefaultBackupFile(config), nil
+	case "postgresql":
+		if config["backup-file"] != "" {
+			return config["backup-file"], nil
+		}
+		return postgresql.DefaultBackupFile(config), nil
+	case "mongodb":
+		return filepath.Join(mongodb.BackupDir(config), config["dbname"]), nil
+	case "sqlite":
+		if config["backup-file"] != "" {
+			return config["backup-file"], nil
+		}
+		return sqlite.DefaultBackupFile(config), nil
+	case "redis":
+		if config["backup-file"] != "" {
+			return config["backup-file"], nil
+		}
+		return redis.DefaultBackupFile(config), nil
+	case "mariadb":
+		if config["backup-file"] != "" {
+			return config["backup-file"], nil
+		}
+		return mariadb.DefaultBackupFile(config), nil
+	default:
+		return "", ErrUnsupportedDBType
+	}
+}
@@ -0,0 +1,83 @@
+package mariadb
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// checkpointsFile is the name under which the backup tools record the LSN
+// range of a backup in --extra-lsndir.
+const checkpointsFile = "xtrabackup_checkpoints"
+
+// Checkpoints is the parsed content of an xtrabackup_checkpoints file.
+type Checkpoints struct {
+	BackupType string
+	FromLSN    uint64
+	ToLSN      uint64
+}
+
+// Incremental reports whether the backup was taken relative to another one.
+func (c Checkpoints) Incremental() bool {
+	return c.BackupType == "incremental"
+}
+
+// CheckpointsPath returns the sidecar path for an artifact.
+func CheckpointsPath(artifact string) string {
+	return artifact + ".checkpoints"
+}
+
+// ReadCheckpoints reads the checkpoints sidecar of an artifact.
+func ReadCheckpoints(artifact string) (Checkpoints, error) {
+	file, err := os.Open(CheckpointsPath(artifact))
+	if err != nil {
+		return Checkpoints{}, err
+	}
+	defer file.Close()
+
+	var checkpoints Checkpoints
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), "=")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		switch strings.TrimSpace(key) {
+		case "backup_type":
+			checkpoints.BackupType = value
+		case "from_lsn":
+			checkpoints.FromLSN, err = strconv.ParseUint(value, 10, 64)
+		case "to_lsn":
+			checkpoints.ToLSN, err = strconv.ParseUint(value, 10, 64)
+		}
+		if err != nil {
+			return Checkpoints{}, fmt.Errorf("invalid %s: %w", CheckpointsPath(artifact), err)
+		}
+	}
+	return checkpoints, scanner.Err()
+}
+
+// checkChain verifies that chain starts with a full backup and that every
+// incremental continues at the LSN where the previous backup ended.
+func checkChain(chain []string) error {
+	var previous Checkpoints
+	for i, artifact := range chain {
+		checkpoints, err := ReadCheckpoints(artifact)
+		if err != nil {
+			return err
+		}
+		switch {
+		case i == 0 && checkpoints.Incremental():
+			return fmt.Errorf("%s is an incremental backup, restore needs its full backup first", artifact)
+		case i > 0 && !checkpoints.Incremental():
+			return fmt.Errorf("%s is not an incremental backup", artifact)
+		case i > 0 && checkpoints.FromLSN != previous.ToLSN:
+			return fmt.Errorf("%s starts at LSN %d but the previous backup ends at LSN %d", artifact, checkpoints.FromLSN, previous.ToLSN)
+		}
+		previous = checkpoints
+	}
+	return nil
+}
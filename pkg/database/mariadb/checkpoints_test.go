@@ -0,0 +1,74 @@
+package mariadb
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeCheckpoints(t *testing.T, artifact, backupType string, from, to uint64) {
+	t.Helper()
+	content := fmt.Sprintf("backup_type = %s\nfrom_lsn = %d\nto_lsn = %d\nlast_lsn = %d\n", backupType, from, to, to)
+	if err := os.WriteFile(CheckpointsPath(artifact), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReadCheckpoints(t *testing.T) {
+	artifact := filepath.Join(t.TempDir(), "db-inc.xbstream")
+	writeCheckpoints(t, artifact, "incremental", 1000, 2500)
+
+	checkpoints, err := ReadCheckpoints(artifact)
+	if err != nil {
+		t.Fatalf("ReadCheckpoints returned error: %v", err)
+	}
+	if !checkpoints.Incremental() || checkpoints.FromLSN != 1000 || checkpoints.ToLSN != 2500 {
+		t.Errorf("Unexpected checkpoints: %+v", checkpoints)
+	}
+}
+
+func TestCheckChain(t *testing.T) {
+	dir := t.TempDir()
+	full := filepath.Join(dir, "db.xbstream")
+	first := filepath.Join(dir, "db-inc-1000.xbstream")
+	second := filepath.Join(dir, "db-inc-2500.xbstream")
+	gap := filepath.Join(dir, "db-inc-9000.xbstream")
+	writeCheckpoints(t, full, "full-backuped", 0, 1000)
+	writeCheckpoints(t, first, "incremental", 1000, 2500)
+	writeCheckpoints(t, second, "incremental", 2500, 4000)
+	writeCheckpoints(t, gap, "incremental", 9000, 9500)
+
+	tests := []struct {
+		chain   []string
+		wantErr bool
+	}{
+		{[]string{full}, false},
+		{[]string{full, first, second}, false},
+		{[]string{first}, true},
+		{[]string{full, second}, true},
+		{[]string{full, first, gap}, true},
+		{[]string{full, full}, true},
+		{[]string{filepath.Join(dir, "missing.xbstream")}, true},
+	}
+
+	for _, tt := range tests {
+		if err := checkChain(tt.chain); (err != nil) != tt.wantErr {
+			t.Errorf("checkChain(%v): expected error %v, got %v", tt.chain, tt.wantErr, err)
+		}
+	}
+}
+
+func TestDefaultBackupFile(t *testing.T) {
+	dir := t.TempDir()
+	base := filepath.Join(dir, "db.xbstream")
+	writeCheckpoints(t, base, "full-backuped", 0, 1000)
+
+	if got := DefaultBackupFile(map[string]string{"backup-dir": dir, "dbname": "db"}); got != base {
+		t.Errorf("Expected full backup at %s, got %s", base, got)
+	}
+	want := filepath.Join(dir, "db-inc-1000.xbstream")
+	if got := DefaultBackupFile(map[string]string{"backup-dir": dir, "dbname": "db", "incremental-base": base}); got != want {
+		t.Errorf("Expected incremental backup at %s, got %s", want, got)
+	}
+}
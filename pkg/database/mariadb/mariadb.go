@@ -0,0 +1,347 @@
+package mariadb
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/itocode21/backup-tool/pkg/database/params"
+	"github.com/itocode21/backup-tool/pkg/database/partial"
+	"github.com/itocode21/backup-tool/pkg/database/priority"
+	"github.com/itocode21/backup-tool/pkg/database/toolerr"
+	"github.com/itocode21/backup-tool/pkg/logging"
+	"github.com/itocode21/backup-tool/pkg/throttle"
+)
+
+// Physical backup tools selectable with the physical-tool parameter. Both
+// speak the same options; they differ in the name of the stream extractor.
+const (
+	ToolMariaBackup = "mariadb-backup"
+	ToolXtraBackup  = "xtrabackup"
+)
+
+var streamExtractors = map[string]string{
+	ToolMariaBackup: "mbstream",
+	ToolXtraBackup:  "xbstream",
+}
+
+// MariaDBBackup takes physical backups of a whole server instance with
+// mariadb-backup or Percona XtraBackup. Artifacts are xbstream streams with
+// a <artifact>.checkpoints sidecar that records their LSN range.
+type MariaDBBackup struct {
+	Logger *logging.Logger
+
+	writes *throttle.Limiter
+}
+
+// LimitWrites paces how fast backup streams are written to the artifact.
+func (m *MariaDBBackup) LimitWrites(l *throttle.Limiter) {
+	m.writes = l
+}
+
+func (m *MariaDBBackup) PerformFullBackup(config map[string]string) error {
+	m.Logger.Info("Starting MariaDB physical backup...")
+
+	requiredParams := []string{"host", "port", "username", "dbname"}
+	for _, param := range requiredParams {
+		if config[param] == "" {
+			return errors.New("missing required parameter: " + param)
+		}
+	}
+	if config["tables"] != "" || config["exclude-tables"] != "" {
+		return errors.New("table selection is not supported for physical backups")
+	}
+	tool, err := backupTool(config)
+	if err != nil {
+		return err
+	}
+
+	backupFilePath := config["backup-file"]
+	if backupFilePath == "" {
+		backupFilePath = DefaultBackupFile(config)
+	}
+	if err := os.MkdirAll(filepath.Dir(backupFilePath), os.ModePerm); err != nil {
+		m.Logger.Error("Failed to create backup directory: " + err.Error())
+		return err
+	}
+
+	workDir, err := os.MkdirTemp(config["temp-dir"], "mariadb-backup-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(workDir)
+	lsnDir := filepath.Join(workDir, "lsn")
+
+	args := append(connectionArgs(tool, config),
+		"--backup",
+		"--stream=xbstream",
+		"--target-dir="+filepath.Join(workDir, "target"),
+		"--extra-lsndir="+lsnDir,
+	)
+	if config["threads"] != "" {
+		args = append(args, "--parallel="+config["threads"])
+	}
+	if base := config["incremental-base"]; base != "" {
+		checkpoints, err := ReadCheckpoints(base)
+		if err != nil {
+			m.Logger.Error("Failed to read checkpoints of incremental base: " + err.Error())
+			return err
+		}
+		m.Logger.Info("Taking incremental backup from LSN " + strconv.FormatUint(checkpoints.ToLSN, 10))
+		args = append(args, "--incremental-lsn="+strconv.FormatUint(checkpoints.ToLSN, 10))
+	}
+
+	tempFile := partial.Path(backupFilePath)
+	defer os.Remove(tempFile)
+	output, err := os.Create(tempFile)
+	if err != nil {
+		m.Logger.Error("Failed to create backup file: " + err.Error())
+		return err
+	}
+	defer output.Close()
+
+	cmd := priority.Command(config, tool, args...)
+	cmd.Stdout = throttle.Writer(output, m.writes)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	m.Logger.Debug("Executing " + tool + " command with arguments: " + redact(cmd.Args))
+	if err := cmd.Run(); err != nil {
+		m.Logger.Error("MariaDB backup failed: " + err.Error() + ". Details: " + stderr.String())
+		return toolerr.Wrap(err, stderr.String())
+	}
+	if err := output.Close(); err != nil {
+		return err
+	}
+
+	if err := copyFile(filepath.Join(lsnDir, checkpointsFile), CheckpointsPath(backupFilePath)); err != nil {
+		m.Logger.Error("Failed to save backup checkpoints: " + err.Error())
+		return err
+	}
+	if err := partial.Commit(tempFile, backupFilePath); err != nil {
+		m.Logger.Error("Failed to move backup into place: " + err.Error())
+		return err
+	}
+
+	m.Logger.Info("MariaDB backup completed successfully. File saved to: " + backupFilePath)
+	return nil
+}
+
+// RestoreBackup extracts the full backup in backup-file and the incremental
+// backups listed in incrementals, prepares them in order and copies the
+// result into data-dir. The server must be stopped and data-dir empty.
+func (m *MariaDBBackup) RestoreBackup(config map[string]string) error {
+	m.Logger.Info("Starting MariaDB physical restore...")
+
+	if config["data-dir"] == "" {
+		return errors.New("missing required parameter: data-dir")
+	}
+	if config["tables"] != "" || config["exclude-tables"] != "" {
+		return errors.New("selective restore is not supported for physical backups")
+	}
+	tool, err := backupTool(config)
+	if err != nil {
+		return err
+	}
+
+	backupFilePath := config["backup-file"]
+	if backupFilePath == "" {
+		backupFilePath = DefaultBackupFile(config)
+	}
+	chain := append([]string{backupFilePath}, params.List(config, "incrementals")...)
+	if err := checkChain(chain); err != nil {
+		m.Logger.Error("MariaDB restore failed: " + err.Error())
+		return err
+	}
+
+	workDir, err := os.MkdirTemp(config["temp-dir"], "mariadb-restore-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(workDir)
+
+	dirs := make([]string, len(chain))
+	for i, artifact := range chain {
+		dirs[i] = filepath.Join(workDir, strconv.Itoa(i))
+		if err := m.extract(tool, artifact, dirs[i]); err != nil {
+			return err
+		}
+	}
+
+	// XtraBackup must not roll back uncommitted transactions until the last
+	// incremental is applied; mariadb-backup handles this on its own.
+	for i, dir := range dirs {
+		args := []string{"--prepare", "--target-dir=" + dirs[0]}
+		if i > 0 {
+			args = append(args, "--incremental-dir="+dir)
+		}
+		if tool == ToolXtraBackup && i < len(dirs)-1 {
+			args = append(args, "--apply-log-only")
+		}
+		m.Logger.Info("Preparing " + chain[i])
+		if err := m.run(tool, args, "MariaDB prepare failed"); err != nil {
+			return err
+		}
+	}
+
+	args := []string{"--copy-back", "--target-dir=" + dirs[0], "--datadir=" + config["data-dir"]}
+	if config["force"] == "true" {
+		args = append(args, "--force-non-empty-directories")
+	}
+	if err := m.run(tool, args, "MariaDB copy-back failed"); err != nil {
+		return err
+	}
+
+	m.Logger.Info("MariaDB restore completed successfully. Fix data-dir ownership and start the server.")
+	return nil
+}
+
+// HasData reports whether data-dir already holds files.
+func (m *MariaDBBackup) HasData(config map[string]string) (bool, error) {
+	if config["data-dir"] == "" {
+		return false, nil
+	}
+	entries, err := os.ReadDir(config["data-dir"])
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return len(entries) > 0, nil
+}
+
+// extract unpacks an xbstream artifact into dir.
+func (m *MariaDBBackup) extract(tool, artifact, dir string) error {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	input, err := os.Open(artifact)
+	if err != nil {
+		m.Logger.Error("Failed to open backup file: " + err.Error())
+		return err
+	}
+	defer input.Close()
+
+	extractor := streamExtractors[tool]
+	cmd := exec.Command(extractor, "-x", "-C", dir)
+	cmd.Stdin = input
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	m.Logger.Debug("Executing " + extractor + " command with arguments: " + strings.Join(cmd.Args, " "))
+	if err := cmd.Run(); err != nil {
+		m.Logger.Error("Failed to extract " + artifact + ": " + err.Error() + ". Details: " + stderr.String())
+		return toolerr.Wrap(err, stderr.String())
+	}
+	return nil
+}
+
+func (m *MariaDBBackup) run(tool string, args []string, failure string) error {
+	cmd := exec.Command(tool, args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	m.Logger.Debug("Executing " + tool + " command with arguments: " + strings.Join(cmd.Args, " "))
+	if err := cmd.Run(); err != nil {
+		m.Logger.Error(failure + ": " + err.Error() + ". Details: " + stderr.String())
+		return toolerr.Wrap(err, stderr.String())
+	}
+	return nil
+}
+
+func backupTool(config map[string]string) (string, error) {
+	tool := config["physical-tool"]
+	if tool == "" {
+		return ToolMariaBackup, nil
+	}
+	if _, ok := streamExtractors[tool]; !ok {
+		return "", errors.New("unsupported physical backup tool: " + tool)
+	}
+	return tool, nil
+}
+
+// connectionArgs returns the connection and TLS options. mariadb-backup
+// takes MariaDB client TLS flags while xtrabackup uses MySQL's --ssl-mode.
+func connectionArgs(tool string, config map[string]string) []string {
+	args := []string{
+		"--host=" + config["host"],
+		"--port=" + config["port"],
+		"--user=" + config["username"],
+	}
+	if config["password"] != "" {
+		args = append(args, "--password="+config["password"])
+	}
+
+	mode := config["tls-mode"]
+	if config["tls-skip-verify"] == "true" && mode != "disable" {
+		mode = "require"
+	}
+	if tool == ToolXtraBackup {
+		modes := map[string]string{"disable": "DISABLED", "require": "REQUIRED", "verify-ca": "VERIFY_CA", "verify-full": "VERIFY_IDENTITY"}
+		if mode != "" {
+			args = append(args, "--ssl-mode="+modes[mode])
+		}
+	} else if mode != "" && mode != "disable" {
+		args = append(args, "--ssl")
+		if strings.HasPrefix(mode, "verify-") {
+			args = append(args, "--ssl-verify-server-cert")
+		}
+	}
+	if mode == "disable" {
+		return args
+	}
+	for _, file := range [][2]string{{"tls-ca", "--ssl-ca="}, {"tls-cert", "--ssl-cert="}, {"tls-key", "--ssl-key="}} {
+		if config[file[0]] != "" {
+			args = append(args, file[1]+config[file[0]])
+		}
+	}
+	return args
+}
+
+// redact hides the password in logged command lines.
+func redact(args []string) string {
+	redacted := make([]string, len(args))
+	for i, arg := range args {
+		if strings.HasPrefix(arg, "--password=") {
+			arg = "--password=***"
+		}
+		redacted[i] = arg
+	}
+	return strings.Join(redacted, " ")
+}
+
+// DefaultBackupFile returns the artifact path used when no backup-file is
+// given: <backup-dir>/<dbname>.xbstream for full backups and
+// <backup-dir>/<dbname>-inc-<from LSN>.xbstream (-diff- for differential
+// ones) for backups taken relative to another.
+func DefaultBackupFile(config map[string]string) string {
+	backupDir := config["backup-dir"]
+	if backupDir == "" {
+		backupDir = filepath.Join("backups", "mariadb")
+	}
+	name := config["dbname"]
+	if base := config["incremental-base"]; base != "" {
+		if config["backup-kind"] == "differential" {
+			name += "-diff"
+		} else {
+			name += "-inc"
+		}
+		if checkpoints, err := ReadCheckpoints(base); err == nil {
+			name += "-" + strconv.FormatUint(checkpoints.ToLSN, 10)
+		}
+	}
+	return filepath.Join(backupDir, name+".xbstream")
+}
+
+func copyFile(src, dst string) error {
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(dst, data, 0644)
+}
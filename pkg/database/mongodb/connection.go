@@ -0,0 +1,157 @@
+package mongodb
+
+import (
+	"errors"
+	"net/url"
+	"os"
+	"strconv"
+)
+
+// uriEnv carries the connection string to mongosh so that the injected
+// password never shows up in the process list.
+const uriEnv = "BACKUP_TOOL_MONGO_URI"
+
+// checkRequired validates the connection parameters: a uri, or host and
+// port, plus the database name.
+func checkRequired(config map[string]string) error {
+	requiredParams := []string{"host", "port", "dbname"}
+	if config["uri"] != "" {
+		requiredParams = []string{"dbname"}
+	}
+	for _, param := range requiredParams {
+		if config[param] == "" {
+			return errors.New("missing required parameter: " + param)
+		}
+	}
+	return nil
+}
+
+// toolArgs returns the connection options for mongodump (dump set) and
+// mongorestore. A uri is handed over in a --config file readable only by
+// us, since the tools would otherwise need the password on the command line.
+// Only mongodump accepts --readPreference.
+func toolArgs(config map[string]string, dump bool) ([]string, error) {
+	if config["uri"] == "" {
+		args := []string{
+			"--host", config["host"],
+			"--port", config["port"],
+		}
+		args = append(args, authArgs(config)...)
+		if dump && config["read-preference"] != "" {
+			args = append(args, "--readPreference", config["read-preference"])
+		}
+		tls, err := tlsArgs(config, false)
+		if err != nil {
+			return nil, err
+		}
+		return append(args, tls...), nil
+	}
+
+	uri, err := connectionURI(config)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.CreateTemp(config["temp-dir"], "mongo-tools-*.yaml")
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	if err := file.Chmod(0600); err != nil {
+		return nil, err
+	}
+	if _, err := file.WriteString("uri: " + strconv.Quote(uri) + "\n"); err != nil {
+		return nil, err
+	}
+	return []string{"--config", file.Name()}, nil
+}
+
+// shellArgs returns the connection options and extra environment for
+// mongosh, and a prelude to put in front of the evaluated script. With a uri
+// the shell starts without a connection and the script connects using the
+// uri from the environment.
+func shellArgs(config map[string]string) ([]string, []string, string, error) {
+	if config["uri"] == "" {
+		args := []string{
+			"--host", config["host"],
+			"--port", config["port"],
+		}
+		args = append(args, authArgs(config)...)
+		tls, err := tlsArgs(config, true)
+		if err != nil {
+			return nil, nil, "", err
+		}
+		return append(args, tls...), nil, "", nil
+	}
+
+	uri, err := connectionURI(config)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	env := append(os.Environ(), uriEnv+"="+uri)
+	return []string{"--nodb"}, env, "db = connect(process.env." + uriEnv + ");\n", nil
+}
+
+// connectionURI completes the configured uri with the credentials, auth
+// database, read preference and TLS settings from the other parameters.
+// Values already present in the uri win.
+func connectionURI(config map[string]string) (string, error) {
+	u, err := url.Parse(config["uri"])
+	if err != nil {
+		return "", errors.New("invalid mongodb uri: " + err.Error())
+	}
+
+	if config["password"] != "" {
+		if _, hasPassword := u.User.Password(); !hasPassword {
+			username := config["username"]
+			if u.User != nil && u.User.Username() != "" {
+				username = u.User.Username()
+			}
+			u.User = url.UserPassword(username, config["password"])
+		}
+	}
+
+	query := u.Query()
+	setDefault := func(key, value string) {
+		if value != "" && query.Get(key) == "" {
+			query.Set(key, value)
+		}
+	}
+	setDefault("authSource", config["auth-db"])
+	setDefault("readPreference", config["read-preference"])
+
+	mode := config["tls-mode"]
+	if config["tls-skip-verify"] == "true" && mode != "disable" {
+		mode = "require"
+	}
+	if mode != "" && mode != "disable" {
+		setDefault("tls", "true")
+		setDefault("tlsCAFile", config["tls-ca"])
+		if config["tls-cert"] != "" {
+			pemFile, err := certificateKeyFile(config)
+			if err != nil {
+				return "", err
+			}
+			setDefault("tlsCertificateKeyFile", pemFile)
+		}
+		switch mode {
+		case "require":
+			setDefault("tlsAllowInvalidCertificates", "true")
+		case "verify-ca":
+			setDefault("tlsAllowInvalidHostnames", "true")
+		}
+	}
+
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
+
+func authArgs(config map[string]string) []string {
+	if config["username"] == "" || config["password"] == "" {
+		return nil
+	}
+	authDB := config["auth-db"]
+	if authDB == "" {
+		authDB = "admin"
+	}
+	return []string{"--username", config["username"], "--password", config["password"], "--authenticationDatabase", authDB}
+}
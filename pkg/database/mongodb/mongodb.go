@@ -2,13 +2,19 @@ package mongodb
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
 	"strings"
 
+	"github.com/itocode21/backup-tool/pkg/database/params"
+	"github.com/itocode21/backup-tool/pkg/database/partial"
+	"github.com/itocode21/backup-tool/pkg/database/priority"
+	"github.com/itocode21/backup-tool/pkg/database/toolerr"
 	"github.com/itocode21/backup-tool/pkg/logging"
 )
 
@@ -19,15 +25,11 @@ type MongoDBBackup struct {
 func (m *MongoDBBackup) PerformFullBackup(config map[string]string) error {
 	m.Logger.Info("Starting full MongoDB backup...")
 
-	requiredParams := []string{"host", "port", "dbname", "backup-file"}
-	for _, param := range requiredParams {
-		if config[param] == "" {
-			return errors.New("missing required parameter: " + param)
-		}
+	if err := checkRequired(config); err != nil {
+		return err
 	}
 
-	backupFilePath := config["backup-file"]
-	backupDir := filepath.Dir(backupFilePath)
+	backupDir := BackupDir(config)
 
 	err := os.MkdirAll(backupDir, os.ModePerm)
 	if err != nil {
@@ -35,28 +37,64 @@ func (m *MongoDBBackup) PerformFullBackup(config map[string]string) error {
 		return err
 	}
 
-	args := []string{
-		"--host", config["host"],
-		"--port", config["port"],
-		"--db", config["dbname"],
-		"--out", backupDir, 
+	args, err := toolArgs(config, true)
+	if err != nil {
+		m.Logger.Error("Failed to prepare connection options: " + err.Error())
+		return err
 	}
-	if config["username"] != "" && config["password"] != "" {
-		args = append(args, "--username", config["username"], "--password", config["password"])
-		if config["auth-db"] != "" {
-			args = append(args, "--authenticationDatabase", config["auth-db"])
-		} else {
-			args = append(args, "--authenticationDatabase", "admin")
+	// mongodump writes into a partial directory, whose <dbname> subdirectory
+	// is renamed to the artifact once every run has finished, so a failed
+	// dump never leaves a partial directory at the real path.
+	artifact := filepath.Join(backupDir, config["dbname"])
+	staging := partial.Path(artifact)
+	os.RemoveAll(staging)
+	defer os.RemoveAll(staging)
+	args = append(args, "--db", config["dbname"], "--out", staging)
+
+	if config["sharded"] == "true" {
+		restart, err := m.stopBalancer(config)
+		if err != nil {
+			return err
+		}
+		if restart {
+			defer m.startBalancer(config)
 		}
 	}
 
-	cmd := exec.Command("mongodump", args...)
-	var stderr bytes.Buffer
-	cmd.Stderr = &stderr
-	m.Logger.Debug("Executing mongodump command with arguments: " + strings.Join(cmd.Args, " "))
-	err = cmd.Run()
-	if err != nil {
-		m.Logger.Error("MongoDB backup failed: " + err.Error() + ". Details: " + stderr.String())
+	// mongodump accepts a single --collection, so selected collections are
+	// dumped one run at a time into the same output directory. Exclusions
+	// only apply when dumping the whole database.
+	var runs [][]string
+	if collections := params.List(config, "tables"); len(collections) > 0 {
+		for _, collection := range collections {
+			runs = append(runs, append(append([]string{}, args...), "--collection", collection))
+		}
+	} else {
+		for _, collection := range params.List(config, "exclude-tables") {
+			args = append(args, "--excludeCollection", collection)
+		}
+		runs = append(runs, args)
+	}
+
+	for _, runArgs := range runs {
+		cmd := priority.Command(config, "mongodump", runArgs...)
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		m.Logger.Debug("Executing mongodump command with arguments: " + strings.Join(cmd.Args, " "))
+		err = cmd.Run()
+		if err != nil {
+			m.Logger.Error("MongoDB backup failed: " + err.Error() + ". Details: " + stderr.String())
+			return toolerr.Wrap(err, stderr.String())
+		}
+	}
+
+	dumped := filepath.Join(staging, config["dbname"])
+	if _, err := os.Stat(dumped); err != nil {
+		m.Logger.Error("MongoDB backup is incomplete: mongodump wrote no files for " + config["dbname"])
+		return errors.New("mongodump wrote no files for " + config["dbname"])
+	}
+	if err := partial.Commit(dumped, artifact); err != nil {
+		m.Logger.Error("Failed to move backup into place: " + err.Error())
 		return err
 	}
 
@@ -67,22 +105,43 @@ func (m *MongoDBBackup) PerformFullBackup(config map[string]string) error {
 func (m *MongoDBBackup) RestoreBackup(config map[string]string) error {
 	fmt.Println("debug: start mongodb restore...")
 	m.Logger.Info("Starting MongoDB restore...")
-	requiredParams := []string{"host", "port", "dbname", "backup-path"}
-	for _, param := range requiredParams {
-		if config[param] == "" {
-			m.Logger.Error("Missing required parameter: " + param)
-			return errors.New("missing required parameter: " + param)
-		}
+	if err := checkRequired(config); err != nil {
+		m.Logger.Error(err.Error())
+		return err
+	}
+
+	source := config["dbname"]
+	if config["source-dbname"] != "" {
+		source = config["source-dbname"]
 	}
 
-	args := []string{
-		"--host", config["host"],
-		"--port", config["port"],
-		filepath.Join(config["backup-path"], config["dbname"]),
+	args, err := toolArgs(config, false)
+	if err != nil {
+		m.Logger.Error("Failed to prepare connection options: " + err.Error())
+		return err
 	}
+	args = append(args, "--dir", BackupDir(config))
 
-	if config["username"] != "" && config["password"] != "" {
-		args = append(args, "--username", config["username"], "--password", config["password"])
+	include, exclude := params.List(config, "tables"), params.List(config, "exclude-tables")
+	if len(include) == 0 {
+		include = []string{"*"}
+	}
+	for _, collection := range include {
+		args = append(args, "--nsInclude", source+"."+collection)
+	}
+	for _, collection := range exclude {
+		args = append(args, "--nsExclude", source+"."+collection)
+	}
+
+	if source != config["dbname"] {
+		args = append(args, "--nsFrom", source+".*", "--nsTo", config["dbname"]+".*")
+	}
+	for _, pair := range params.List(config, "ns-map") {
+		from, to, ok := strings.Cut(pair, ":")
+		if !ok {
+			return errors.New("invalid ns-map entry, expected from:to: " + pair)
+		}
+		args = append(args, "--nsFrom", from, "--nsTo", to)
 	}
 
 	m.Logger.Debug("Executing mongorestore command with arguments: " + strings.Join(args, " "))
@@ -90,12 +149,135 @@ func (m *MongoDBBackup) RestoreBackup(config map[string]string) error {
 	var stderr bytes.Buffer
 	cmd.Stderr = &stderr
 
-	err := cmd.Run()
+	err = cmd.Run()
 	if err != nil {
 		m.Logger.Error("MongoDB restore failed: " + err.Error() + ". Details: " + stderr.String())
-		return err
+		return toolerr.Wrap(err, stderr.String())
 	}
 
 	m.Logger.Info("MongoDB restore completed successfully.")
 	return nil
 }
+
+// BackupDir returns the directory mongodump writes into. mongodump creates a
+// <dbname> subdirectory there, so backup-file only contributes its parent.
+func BackupDir(config map[string]string) string {
+	if config["backup-path"] != "" {
+		return config["backup-path"]
+	}
+	if config["backup-file"] != "" {
+		return filepath.Dir(config["backup-file"])
+	}
+	if config["backup-dir"] != "" {
+		return config["backup-dir"]
+	}
+	return filepath.Join("backups", "mongodb")
+}
+
+var systemDatabases = map[string]bool{
+	"admin":  true,
+	"config": true,
+	"local":  true,
+}
+
+func (m *MongoDBBackup) ListDatabases(config map[string]string) ([]string, error) {
+	output, err := m.eval(config, "db.adminCommand({listDatabases: 1, nameOnly: true}).databases.forEach(d => print(d.name))")
+	if err != nil {
+		m.Logger.Error("Failed to list MongoDB databases: " + err.Error())
+		return nil, err
+	}
+
+	var databases []string
+	for _, name := range strings.Fields(output) {
+		if !systemDatabases[name] {
+			databases = append(databases, name)
+		}
+	}
+	return databases, nil
+}
+
+// HasData reports whether the database in config has any collections.
+func (m *MongoDBBackup) HasData(config map[string]string) (bool, error) {
+	output, err := m.eval(config, "print(db.getSiblingDB("+strconv.Quote(config["dbname"])+").getCollectionNames().length)")
+	if err != nil {
+		return false, err
+	}
+	return strings.TrimSpace(output) != "0", nil
+}
+
+// CountTables returns how many collections the dump directory of the
+// database holds, by its BSON files.
+func (m *MongoDBBackup) CountTables(config map[string]string) (int, error) {
+	count := 0
+	err := filepath.WalkDir(filepath.Join(BackupDir(config), config["dbname"]), func(path string, entry os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !entry.IsDir() && (strings.HasSuffix(path, ".bson") || strings.HasSuffix(path, ".bson.gz")) {
+			count++
+		}
+		return nil
+	})
+	return count, err
+}
+
+// Exec runs a mongosh script with db set to the database in config.
+func (m *MongoDBBackup) Exec(ctx context.Context, config map[string]string, statement string) error {
+	_, err := m.evalContext(ctx, config, "db = db.getSiblingDB("+strconv.Quote(config["dbname"])+");\n"+statement)
+	return err
+}
+
+// eval runs a script with mongosh and returns what it printed.
+func (m *MongoDBBackup) eval(config map[string]string, script string) (string, error) {
+	return m.evalContext(context.Background(), config, script)
+}
+
+// evalContext is eval with mongosh killed when ctx is done.
+func (m *MongoDBBackup) evalContext(ctx context.Context, config map[string]string, script string) (string, error) {
+	args, env, prelude, err := shellArgs(config)
+	if err != nil {
+		return "", err
+	}
+	args = append(args, "--quiet", "--eval", prelude+script)
+
+	cmd := exec.CommandContext(ctx, "mongosh", args...)
+	cmd.Env = env
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	m.Logger.Debug("Executing mongosh command with arguments: " + strings.Join(cmd.Args, " "))
+	if err := cmd.Run(); err != nil {
+		return "", errors.New(err.Error() + ". Details: " + stderr.String())
+	}
+	return stdout.String(), nil
+}
+
+// stopBalancer stops the balancer of a sharded cluster so that chunks do not
+// move during the dump. It reports whether the balancer was running and must
+// be started again afterwards.
+func (m *MongoDBBackup) stopBalancer(config map[string]string) (bool, error) {
+	state, err := m.eval(config, "print(sh.getBalancerState())")
+	if err != nil {
+		m.Logger.Error("Failed to read balancer state: " + err.Error())
+		return false, err
+	}
+	if strings.TrimSpace(state) != "true" {
+		m.Logger.Info("Balancer already stopped")
+		return false, nil
+	}
+
+	m.Logger.Info("Stopping balancer for the duration of the dump...")
+	if _, err := m.eval(config, "sh.stopBalancer()"); err != nil {
+		m.Logger.Error("Failed to stop balancer: " + err.Error())
+		return false, err
+	}
+	return true, nil
+}
+
+func (m *MongoDBBackup) startBalancer(config map[string]string) {
+	m.Logger.Info("Starting balancer...")
+	if _, err := m.eval(config, "sh.startBalancer()"); err != nil {
+		m.Logger.Error("Failed to start balancer, start it manually with sh.startBalancer(): " + err.Error())
+	}
+}
@@ -0,0 +1,76 @@
+package mongodb
+
+import (
+	"os"
+)
+
+// tlsArgs returns the TLS options for the Mongo clients. The database tools
+// spell them --ssl*, mongosh --tls*. Both expect the client certificate and
+// key in a single PEM file, so separate files are joined in the job's temp
+// directory.
+func tlsArgs(config map[string]string, shell bool) ([]string, error) {
+	mode := config["tls-mode"]
+	if config["tls-skip-verify"] == "true" && mode != "disable" {
+		mode = "require"
+	}
+	if mode == "" || mode == "disable" {
+		return nil, nil
+	}
+
+	prefix := "--ssl"
+	if shell {
+		prefix = "--tls"
+	}
+
+	args := []string{prefix}
+	if config["tls-ca"] != "" {
+		args = append(args, prefix+"CAFile", config["tls-ca"])
+	}
+	if config["tls-cert"] != "" {
+		pemFile, err := certificateKeyFile(config)
+		if err != nil {
+			return nil, err
+		}
+		if shell {
+			args = append(args, "--tlsCertificateKeyFile", pemFile)
+		} else {
+			args = append(args, "--sslPEMKeyFile", pemFile)
+		}
+	}
+
+	switch mode {
+	case "require":
+		args = append(args, prefix+"AllowInvalidCertificates")
+	case "verify-ca":
+		args = append(args, prefix+"AllowInvalidHostnames")
+	}
+	return args, nil
+}
+
+func certificateKeyFile(config map[string]string) (string, error) {
+	if config["tls-key"] == "" || config["tls-key"] == config["tls-cert"] {
+		return config["tls-cert"], nil
+	}
+
+	cert, err := os.ReadFile(config["tls-cert"])
+	if err != nil {
+		return "", err
+	}
+	key, err := os.ReadFile(config["tls-key"])
+	if err != nil {
+		return "", err
+	}
+
+	file, err := os.CreateTemp(config["temp-dir"], "mongo-client-*.pem")
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	if err := file.Chmod(0600); err != nil {
+		return "", err
+	}
+	if _, err := file.Write(append(append(cert, '\n'), key...)); err != nil {
+		return "", err
+	}
+	return file.Name(), nil
+}
@@ -0,0 +1,156 @@
+package mysql
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"path"
+	"strings"
+)
+
+// Section headers written by mysqldump before each object's statements.
+var tableHeaders = []string{
+	"-- Table structure for table `",
+	"-- Dumping data for table `",
+	"-- Temporary view structure for view `",
+	"-- Temporary table structure for view `",
+	"-- Final view structure for view `",
+}
+
+// FilterDump copies a mysqldump script from r to w, keeping only the
+// statements that belong to selected tables. Statements outside any table
+// section (session settings and their restoring trailer, routines, events)
+// are always kept. Table names may be glob patterns; an empty include list
+// selects every table. Scripts without mysqldump's section headers, such as
+// mysqlpump output, are rejected before anything is written.
+func FilterDump(r io.Reader, w io.Writer, include, exclude []string) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024*1024)
+	writer := bufio.NewWriter(w)
+
+	// Lines before the first section header are held back, so a script
+	// that turns out to have none never reaches the server unfiltered.
+	var prelude bytes.Buffer
+	sections := false
+	keep := true
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "-- ") {
+			if table, ok := sectionTable(line); ok {
+				keep = tableSelected(table, include, exclude)
+				sections = true
+			} else if strings.HasPrefix(line, "-- Dumping routines") || strings.HasPrefix(line, "-- Dumping events") {
+				keep = true
+				sections = true
+			} else if strings.HasPrefix(line, "-- Dump completed") {
+				keep = true
+			}
+		} else if strings.Contains(line, "=@OLD_") {
+			// The trailer restores the session variables saved in the
+			// header; it follows the last table section directly.
+			keep = true
+		}
+		if !sections {
+			prelude.WriteString(line + "\n")
+			if prelude.Len() > maxPrelude {
+				return errNoSections
+			}
+			continue
+		}
+		if prelude.Len() > 0 {
+			if _, err := prelude.WriteTo(writer); err != nil {
+				return err
+			}
+		}
+		if !keep {
+			continue
+		}
+		if _, err := writer.WriteString(line + "\n"); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	if !sections {
+		return errNoSections
+	}
+	return writer.Flush()
+}
+
+// maxPrelude bounds the session settings mysqldump writes before the first
+// table section.
+const maxPrelude = 1 << 20
+
+var errNoSections = errors.New("dump has no mysqldump table sections; selective restores need a mysqldump script")
+
+func sectionTable(line string) (string, bool) {
+	for _, header := range tableHeaders {
+		if rest, ok := strings.CutPrefix(line, header); ok {
+			if end := strings.Index(rest, "`"); end >= 0 {
+				return rest[:end], true
+			}
+		}
+	}
+	return "", false
+}
+
+func tableSelected(table string, include, exclude []string) bool {
+	if len(include) > 0 && !matchesAny(include, table) {
+		return false
+	}
+	return !matchesAny(exclude, table)
+}
+
+func matchesAny(patterns []string, table string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, table); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Statements through which mysqldump output names a database.
+var databasePrefixes = []string{
+	"USE `",
+	"CREATE DATABASE ",
+	"-- Current Database: `",
+}
+
+// RewriteDump copies a mysqldump script from r to w, replacing database
+// names in USE, CREATE DATABASE and "Current Database" lines according to
+// renames, so a dump taken with --databases can be restored under a new name.
+func RewriteDump(r io.Reader, w io.Writer, renames map[string]string) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024*1024)
+	writer := bufio.NewWriter(w)
+
+	for scanner.Scan() {
+		line := scanner.Text()
+		for _, prefix := range databasePrefixes {
+			if strings.HasPrefix(line, prefix) {
+				line = renameDatabase(line, renames)
+				break
+			}
+		}
+		if _, err := writer.WriteString(line + "\n"); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return writer.Flush()
+}
+
+func renameDatabase(line string, renames map[string]string) string {
+	for from, to := range renames {
+		quoted := "`" + from + "`"
+		if strings.Contains(line, quoted) {
+			return strings.Replace(line, quoted, "`"+to+"`", 1)
+		}
+	}
+	return line
+}
@@ -0,0 +1,125 @@
+package mysql
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+const sampleDump = "-- MySQL dump\n" +
+	"SET NAMES utf8mb4;\n" +
+	"--\n" +
+	"-- Table structure for table `orders`\n" +
+	"--\n" +
+	"CREATE TABLE `orders` (id int);\n" +
+	"--\n" +
+	"-- Dumping data for table `orders`\n" +
+	"--\n" +
+	"INSERT INTO `orders` VALUES (1);\n" +
+	"--\n" +
+	"-- Table structure for table `users`\n" +
+	"--\n" +
+	"CREATE TABLE `users` (id int);\n" +
+	"INSERT INTO `users` VALUES (1);\n" +
+	"--\n" +
+	"-- Dumping routines for database 'shop'\n" +
+	"--\n" +
+	"SET SQL_MODE=@OLD_SQL_MODE;\n"
+
+func TestFilterDump(t *testing.T) {
+	var out bytes.Buffer
+	if err := FilterDump(strings.NewReader(sampleDump), &out, []string{"ord*"}, nil); err != nil {
+		t.Fatalf("FilterDump returned error: %v", err)
+	}
+
+	result := out.String()
+	for _, expected := range []string{"SET NAMES", "CREATE TABLE `orders`", "INSERT INTO `orders`", "SET SQL_MODE"} {
+		if !strings.Contains(result, expected) {
+			t.Errorf("Expected filtered dump to contain %q", expected)
+		}
+	}
+	if strings.Contains(result, "`users`") {
+		t.Error("Expected table users to be filtered out")
+	}
+}
+
+func TestFilterDumpExclude(t *testing.T) {
+	var out bytes.Buffer
+	if err := FilterDump(strings.NewReader(sampleDump), &out, nil, []string{"orders"}); err != nil {
+		t.Fatalf("FilterDump returned error: %v", err)
+	}
+	if strings.Contains(out.String(), "`orders`") || !strings.Contains(out.String(), "`users`") {
+		t.Errorf("Unexpected filtered dump:\n%s", out.String())
+	}
+}
+
+func TestFilterDumpKeepsTrailer(t *testing.T) {
+	// Последняя таблица исключена, а восстановление сессии идёт сразу за ней
+	dump := "/*!40103 SET @OLD_TIME_ZONE=@@TIME_ZONE */;\n" +
+		"--\n" +
+		"-- Table structure for table `users`\n" +
+		"--\n" +
+		"CREATE TABLE `users` (id int);\n" +
+		"/*!40103 SET TIME_ZONE=@OLD_TIME_ZONE */;\n" +
+		"/*!40101 SET SQL_MODE=@OLD_SQL_MODE */;\n" +
+		"-- Dump completed on 2024-05-01 10:00:00\n"
+
+	var out bytes.Buffer
+	if err := FilterDump(strings.NewReader(dump), &out, nil, []string{"users"}); err != nil {
+		t.Fatalf("FilterDump returned error: %v", err)
+	}
+	result := out.String()
+	for _, expected := range []string{"SET TIME_ZONE=@OLD_TIME_ZONE", "SET SQL_MODE=@OLD_SQL_MODE", "-- Dump completed"} {
+		if !strings.Contains(result, expected) {
+			t.Errorf("Expected filtered dump to contain %q, got:\n%s", expected, result)
+		}
+	}
+	if strings.Contains(result, "`users`") {
+		t.Error("Expected table users to be filtered out")
+	}
+}
+
+func TestFilterDumpRejectsMysqlpump(t *testing.T) {
+	// У mysqlpump нет секций по таблицам, отфильтровать такой дамп нельзя
+	dump := "-- Dump created by MySQL pump utility, version: 8.0.36, Linux (x86_64)\n" +
+		"SET @OLD_UNIQUE_CHECKS=@@UNIQUE_CHECKS, UNIQUE_CHECKS=0;\n" +
+		"CREATE DATABASE /*!32312 IF NOT EXISTS*/ `shop` /*!40100 DEFAULT CHARACTER SET utf8mb4 */;\n" +
+		"CREATE TABLE `shop`.`orders` (\n" +
+		"`id` int NOT NULL\n" +
+		") ENGINE=InnoDB;\n" +
+		"INSERT INTO `shop`.`orders` VALUES (1);\n" +
+		"CREATE TABLE `shop`.`users` (\n" +
+		"`id` int NOT NULL\n" +
+		") ENGINE=InnoDB;\n" +
+		"INSERT INTO `shop`.`users` VALUES (1);\n" +
+		"SET UNIQUE_CHECKS=@OLD_UNIQUE_CHECKS;\n" +
+		"-- Dump completed\n"
+
+	var out bytes.Buffer
+	if err := FilterDump(strings.NewReader(dump), &out, []string{"orders"}, nil); err == nil {
+		t.Fatal("Expected a mysqlpump dump to be rejected")
+	}
+	if out.Len() != 0 {
+		t.Errorf("Expected nothing to be written for a rejected dump, got:\n%s", out.String())
+	}
+}
+
+func TestRewriteDump(t *testing.T) {
+	dump := "-- Current Database: `shop`\n" +
+		"CREATE DATABASE /*!32312 IF NOT EXISTS*/ `shop` /*!40100 DEFAULT CHARACTER SET utf8mb4 */;\n" +
+		"USE `shop`;\n" +
+		"INSERT INTO `orders` VALUES (1,'shop');\n"
+
+	var out bytes.Buffer
+	if err := RewriteDump(strings.NewReader(dump), &out, map[string]string{"shop": "shop_restored"}); err != nil {
+		t.Fatalf("RewriteDump returned error: %v", err)
+	}
+
+	result := out.String()
+	if strings.Count(result, "`shop_restored`") != 3 {
+		t.Errorf("Expected 3 renamed references, got:\n%s", result)
+	}
+	if !strings.Contains(result, "VALUES (1,'shop')") {
+		t.Error("Expected data rows to be left untouched")
+	}
+}
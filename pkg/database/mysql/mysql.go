@@ -2,26 +2,40 @@ package mysql
 
 import (
 	"bytes"
+	"context"
 	"errors"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
 
+	"github.com/itocode21/backup-tool/pkg/database/params"
+	"github.com/itocode21/backup-tool/pkg/database/partial"
+	"github.com/itocode21/backup-tool/pkg/database/priority"
+	"github.com/itocode21/backup-tool/pkg/database/script"
+	"github.com/itocode21/backup-tool/pkg/database/toolerr"
 	"github.com/itocode21/backup-tool/pkg/logging"
+	"github.com/itocode21/backup-tool/pkg/throttle"
 )
 
 type MySQLBackup struct {
 	Logger *logging.Logger
+
+	writes *throttle.Limiter
+}
+
+// LimitWrites paces how fast dumps are written to the artifact.
+func (m *MySQLBackup) LimitWrites(l *throttle.Limiter) {
+	m.writes = l
 }
 
 func (m *MySQLBackup) PerformFullBackup(config map[string]string) error {
 	m.Logger.Info("Starting full MySQL backup...")
 
-	defaultBackupPath := filepath.Join("backups", "mysql", config["dbname"]+".sql")
 	backupFilePath := config["backup-file"]
 	if backupFilePath == "" {
-		backupFilePath = defaultBackupPath
+		backupFilePath = DefaultBackupFile(config)
 	}
 
 	backupDir := filepath.Dir(backupFilePath)
@@ -31,54 +45,103 @@ func (m *MySQLBackup) PerformFullBackup(config map[string]string) error {
 		return err
 	}
 
-	outputFile, err := os.Create(backupFilePath)
+	// The dump is written under a partial name and only renamed to the
+	// artifact once it is complete, so a failed run never leaves a cut-off
+	// dump at the real path.
+	tempFile := partial.Path(backupFilePath)
+	os.RemoveAll(tempFile)
+	defer os.RemoveAll(tempFile)
+
+	tool := dumpTool(config)
+	if tool == ToolMydumper {
+		err = m.backupMydumper(config, tempFile)
+	} else {
+		err = m.dumpScript(config, tool, tempFile)
+	}
+	if err != nil {
+		return err
+	}
+	if err := verifyDump(tempFile, tool); err != nil {
+		m.Logger.Error("MySQL backup is incomplete: " + err.Error())
+		return err
+	}
+	if err := partial.Commit(tempFile, backupFilePath); err != nil {
+		m.Logger.Error("Failed to move backup into place: " + err.Error())
+		return err
+	}
+
+	m.Logger.Info("MySQL backup completed successfully. Saved to: " + backupFilePath)
+	return nil
+}
+
+// dumpScript writes a mysqldump or mysqlpump script of the database to path.
+func (m *MySQLBackup) dumpScript(config map[string]string, tool, path string) error {
+	outputFile, err := os.Create(path)
 	if err != nil {
 		m.Logger.Error("Failed to create backup file: " + err.Error())
 		return err
 	}
 	defer outputFile.Close()
 
-	cmd := exec.Command("mysqldump",
-		"--user="+config["username"],
-		"--password="+config["password"],
-		"--host="+config["host"],
-		"--port="+config["port"],
-		config["dbname"],
-	)
-	cmd.Stdout = outputFile
+	args := connectionArgs(config)
+	args = append(args, m.dumpOptions(config, tool)...)
+	if exclude := params.List(config, "exclude-tables"); len(exclude) > 0 && tool == ToolMysqlpump {
+		args = append(args, "--exclude-tables="+strings.Join(exclude, ","))
+	} else {
+		for _, table := range exclude {
+			args = append(args, "--ignore-table="+config["dbname"]+"."+table)
+		}
+	}
+	args = append(args, config["dbname"])
+	args = append(args, params.List(config, "tables")...)
+
+	cmd := priority.Command(config, tool, args...)
+	cmd.Stdout = throttle.Writer(outputFile, m.writes)
 	var stderr bytes.Buffer
 	cmd.Stderr = &stderr
 
-	m.Logger.Debug("Executing mysqldump command with arguments: " + strings.Join(cmd.Args, " "))
+	m.Logger.Debug("Executing " + tool + " command with arguments: " + strings.Join(cmd.Args, " "))
 	err = cmd.Run()
 	if err != nil {
 		m.Logger.Error("MySQL backup failed: " + err.Error() + ". Details: " + stderr.String())
-		return err
+		return toolerr.Wrap(err, stderr.String())
 	}
-
-	m.Logger.Info("MySQL backup completed successfully. File saved to: " + backupFilePath)
-	return nil
+	return outputFile.Close()
 }
 
 func (m *MySQLBackup) RestoreBackup(config map[string]string) error {
 	m.Logger.Info("Starting MySQL restore...")
 
-	requiredParams := []string{"host", "port", "username", "password", "dbname", "backup-file"}
+	requiredParams := []string{"host", "port", "username", "password", "dbname"}
 	for _, param := range requiredParams {
 		if config[param] == "" {
 			return errors.New("missing required parameter: " + param)
 		}
 	}
 
-	cmd := exec.Command("mysql",
-		"--user="+config["username"],
-		"--password="+config["password"],
-		"--host="+config["host"],
-		"--port="+config["port"],
-		config["dbname"],
-	)
+	backupFilePath := config["backup-file"]
+	if backupFilePath == "" {
+		backupFilePath = DefaultBackupFile(config)
+	}
+
+	if info, err := os.Stat(backupFilePath); err == nil && info.IsDir() {
+		if err := m.restoreMydumper(config, backupFilePath); err != nil {
+			return err
+		}
+		m.Logger.Info("MySQL restore completed successfully.")
+		return nil
+	}
+
+	renames := databaseRenames(config)
+	if len(renames) > 0 {
+		if _, err := m.query(config, "CREATE DATABASE IF NOT EXISTS `"+config["dbname"]+"`"); err != nil {
+			return err
+		}
+	}
+
+	cmd := exec.Command("mysql", append(connectionArgs(config), config["dbname"])...)
 
-	backupFile, err := os.Open(config["backup-file"])
+	backupFile, err := os.Open(backupFilePath)
 	if err != nil {
 		m.Logger.Error("Failed to open backup file: " + err.Error())
 		return err
@@ -87,6 +150,24 @@ func (m *MySQLBackup) RestoreBackup(config map[string]string) error {
 
 	cmd.Stdin = backupFile
 
+	include, exclude := params.List(config, "tables"), params.List(config, "exclude-tables")
+	if len(include) > 0 || len(exclude) > 0 {
+		m.Logger.Info("Restoring selected tables only")
+		pipe := pipeThrough(cmd.Stdin, func(r io.Reader, w io.Writer) error {
+			return FilterDump(r, w, include, exclude)
+		})
+		defer pipe.Close()
+		cmd.Stdin = pipe
+	}
+	if len(renames) > 0 {
+		m.Logger.Info("Rewriting database names in dump")
+		pipe := pipeThrough(cmd.Stdin, func(r io.Reader, w io.Writer) error {
+			return RewriteDump(r, w, renames)
+		})
+		defer pipe.Close()
+		cmd.Stdin = pipe
+	}
+
 	var stderr bytes.Buffer
 	cmd.Stderr = &stderr
 
@@ -94,9 +175,137 @@ func (m *MySQLBackup) RestoreBackup(config map[string]string) error {
 	err = cmd.Run()
 	if err != nil {
 		m.Logger.Error("MySQL restore failed: " + err.Error() + ". Details: " + stderr.String())
-		return err
+		return toolerr.Wrap(err, stderr.String())
 	}
 
 	m.Logger.Info("MySQL restore completed successfully.")
 	return nil
 }
+
+// DefaultBackupFile returns the artifact path used when no backup-file is
+// given: <backup-dir>/<dbname>.sql, or backups/mysql/<dbname>.sql. mydumper
+// writes a directory, which gets no extension.
+func DefaultBackupFile(config map[string]string) string {
+	if dumpTool(config) == ToolMydumper {
+		return filepath.Join(dumpDir(config), config["dbname"])
+	}
+	return filepath.Join(dumpDir(config), config["dbname"]+".sql")
+}
+
+// dumpDir returns the directory default artifacts are written to.
+func dumpDir(config map[string]string) string {
+	if dir := config["backup-dir"]; dir != "" {
+		return dir
+	}
+	return filepath.Join("backups", "mysql")
+}
+
+var systemDatabases = map[string]bool{
+	"information_schema": true,
+	"performance_schema": true,
+	"mysql":              true,
+	"sys":                true,
+}
+
+func (m *MySQLBackup) ListDatabases(config map[string]string) ([]string, error) {
+	output, err := m.query(config, "SHOW DATABASES")
+	if err != nil {
+		m.Logger.Error("Failed to list MySQL databases: " + err.Error())
+		return nil, err
+	}
+
+	var databases []string
+	for _, name := range strings.Fields(output) {
+		if !systemDatabases[name] {
+			databases = append(databases, name)
+		}
+	}
+	return databases, nil
+}
+
+// HasData reports whether the database in config contains any tables. A
+// missing database counts as empty.
+func (m *MySQLBackup) HasData(config map[string]string) (bool, error) {
+	output, err := m.query(config, "SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = '"+
+		strings.ReplaceAll(config["dbname"], "'", "''")+"'")
+	if err != nil {
+		return false, err
+	}
+	return strings.TrimSpace(output) != "0", nil
+}
+
+// CountTables returns how many tables the artifact at backup-file holds:
+// the schema files of a mydumper directory or the CREATE TABLE statements
+// of a mysqldump or mysqlpump script.
+func (m *MySQLBackup) CountTables(config map[string]string) (int, error) {
+	path := config["backup-file"]
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, err
+	}
+	if info.IsDir() {
+		schemas, err := filepath.Glob(filepath.Join(path, "*-schema.sql"))
+		return len(schemas), err
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+	return script.CountCreateTables(file)
+}
+
+// query runs a single statement with the mysql client and returns its
+// tab-separated output without headers. An optional database becomes the
+// default one for the statement.
+func (m *MySQLBackup) query(config map[string]string, statement string, database ...string) (string, error) {
+	return m.queryContext(context.Background(), config, statement, database...)
+}
+
+// queryContext is query with the mysql client killed when ctx is done.
+func (m *MySQLBackup) queryContext(ctx context.Context, config map[string]string, statement string, database ...string) (string, error) {
+	args := append(connectionArgs(config), "--batch", "--skip-column-names", "--execute="+statement)
+	args = append(args, database...)
+	cmd := exec.CommandContext(ctx, "mysql", args...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	m.Logger.Debug("Executing mysql command with arguments: " + strings.Join(cmd.Args, " "))
+	if err := cmd.Run(); err != nil {
+		return "", errors.New(err.Error() + ". Details: " + stderr.String())
+	}
+	return stdout.String(), nil
+}
+
+// Exec runs a statement against the database in config.
+func (m *MySQLBackup) Exec(ctx context.Context, config map[string]string, statement string) error {
+	_, err := m.queryContext(ctx, config, statement, config["dbname"])
+	return err
+}
+
+// databaseRenames collects the database renames for a restore: the move
+// from source-dbname to dbname plus any from:to pairs in ns-map.
+func databaseRenames(config map[string]string) map[string]string {
+	renames := make(map[string]string)
+	if source := config["source-dbname"]; source != "" && source != config["dbname"] {
+		renames[source] = config["dbname"]
+	}
+	for _, pair := range params.List(config, "ns-map") {
+		if from, to, ok := strings.Cut(pair, ":"); ok {
+			renames[from] = to
+		}
+	}
+	return renames
+}
+
+// pipeThrough returns a reader that yields src transformed by filter, which
+// runs in its own goroutine.
+func pipeThrough(src io.Reader, filter func(io.Reader, io.Writer) error) *io.PipeReader {
+	reader, writer := io.Pipe()
+	go func() {
+		writer.CloseWithError(filter(src, writer))
+	}()
+	return reader
+}
@@ -0,0 +1,268 @@
+package mysql
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/itocode21/backup-tool/pkg/database/params"
+	"github.com/itocode21/backup-tool/pkg/database/priority"
+	"github.com/itocode21/backup-tool/pkg/database/toolerr"
+	"github.com/itocode21/backup-tool/pkg/manifest"
+)
+
+// Dump tools selectable with the dump-tool parameter. ToolAuto sticks to
+// the layout of the newest existing dump and otherwise picks the fastest
+// tool found on PATH.
+const (
+	ToolMysqldump = "mysqldump"
+	ToolMysqlpump = "mysqlpump"
+	ToolMydumper  = "mydumper"
+	ToolAuto      = "auto"
+)
+
+// dumpTool resolves the dump-tool parameter to an executable name.
+func dumpTool(config map[string]string) string {
+	switch tool := config["dump-tool"]; tool {
+	case "":
+		return ToolMysqldump
+	case ToolAuto:
+		candidates := []string{ToolMydumper, ToolMysqlpump}
+		switch storedTool(config) {
+		case ToolMydumper:
+			return ToolMydumper
+		case ToolMysqldump:
+			candidates = []string{ToolMysqlpump}
+		}
+		for _, candidate := range candidates {
+			if _, err := exec.LookPath(candidate); err == nil {
+				return candidate
+			}
+		}
+		return ToolMysqldump
+	default:
+		return tool
+	}
+}
+
+// storedTool reports how the newest existing dump of the database was
+// written: ToolMydumper for a directory, ToolMysqldump for a script (also
+// written by mysqlpump), or "" if there is none. A dump kept only in the
+// repository is found by its manifest. This keeps auto from looking for a
+// backup at the other path once another tool is installed.
+func storedTool(config map[string]string) string {
+	dir := filepath.Join(dumpDir(config), config["dbname"])
+	var tool string
+	var newest time.Time
+	for candidate, artifact := range map[string]string{ToolMydumper: dir, ToolMysqldump: dir + ".sql"} {
+		for _, path := range []string{artifact, manifest.Path(artifact)} {
+			if info, err := os.Stat(path); err == nil && info.ModTime().After(newest) {
+				tool, newest = candidate, info.ModTime()
+			}
+		}
+	}
+	return tool
+}
+
+// enabled reads a boolean option that defaults to true when unset.
+func enabled(config map[string]string, key string) bool {
+	return config[key] != "false"
+}
+
+// dumpOptions returns the consistency options for mysqldump and mysqlpump.
+// Tables that do not support transactions turn --single-transaction into a
+// --lock-tables fallback, since a snapshot would not cover them.
+func (m *MySQLBackup) dumpOptions(config map[string]string, tool string) []string {
+	var args []string
+
+	if enabled(config, "single-transaction") {
+		tables, err := m.nonTransactionalTables(config)
+		switch {
+		case err != nil:
+			m.Logger.Warn("Failed to check table engines, assuming InnoDB: " + err.Error())
+			args = append(args, "--single-transaction")
+		case len(tables) > 0 && tool == ToolMysqldump:
+			m.Logger.Warn("Non-transactional tables found, falling back to --lock-tables: " + strings.Join(tables, ", "))
+			args = append(args, "--lock-tables")
+		case len(tables) > 0:
+			m.Logger.Warn("Non-transactional tables will not be consistent with the snapshot: " + strings.Join(tables, ", "))
+			args = append(args, "--single-transaction")
+		default:
+			args = append(args, "--single-transaction")
+		}
+	}
+
+	if tool == ToolMysqlpump {
+		// mysqlpump dumps routines, triggers and events unless told not to.
+		for _, option := range []string{"routines", "triggers", "events"} {
+			if !enabled(config, option) {
+				args = append(args, "--skip-"+option)
+			}
+		}
+	} else {
+		for _, option := range []string{"routines", "triggers", "events"} {
+			if enabled(config, option) {
+				args = append(args, "--"+option)
+			} else {
+				args = append(args, "--skip-"+option)
+			}
+		}
+	}
+	if enabled(config, "hex-blob") {
+		args = append(args, "--hex-blob")
+	}
+
+	if value := m.gtidPurged(config); value != "" {
+		args = append(args, "--set-gtid-purged="+value)
+	}
+	if tool == ToolMysqlpump && config["threads"] != "" {
+		args = append(args, "--default-parallelism="+config["threads"])
+	}
+	return args
+}
+
+// gtidPurged decides the --set-gtid-purged value. The option only exists
+// for MySQL servers with GTIDs, so it is omitted when @@gtid_mode cannot be
+// read (MariaDB). Partial dumps default to OFF because restoring their
+// GTID_PURGED would mark transactions for the skipped tables as applied.
+func (m *MySQLBackup) gtidPurged(config map[string]string) string {
+	output, err := m.query(config, "SELECT @@GLOBAL.gtid_mode")
+	if err != nil {
+		m.Logger.Debug("GTIDs not supported by server, skipping --set-gtid-purged")
+		return ""
+	}
+	if value := config["set-gtid-purged"]; value != "" {
+		return strings.ToUpper(value)
+	}
+	if strings.TrimSpace(output) != "OFF" && len(params.List(config, "tables")) > 0 {
+		return "OFF"
+	}
+	return ""
+}
+
+// nonTransactionalTables lists base tables of the database that do not use
+// InnoDB.
+func (m *MySQLBackup) nonTransactionalTables(config map[string]string) ([]string, error) {
+	output, err := m.query(config, "SELECT table_name FROM information_schema.tables WHERE table_schema = '"+
+		strings.ReplaceAll(config["dbname"], "'", "''")+"' AND table_type = 'BASE TABLE' AND engine <> 'InnoDB'")
+	if err != nil {
+		return nil, err
+	}
+	tables := strings.Fields(output)
+	if include := params.List(config, "tables"); len(include) > 0 {
+		tables = intersect(tables, include)
+	}
+	return tables, nil
+}
+
+func intersect(tables, selected []string) []string {
+	keep := make(map[string]bool, len(selected))
+	for _, t := range selected {
+		keep[t] = true
+	}
+	var result []string
+	for _, t := range tables {
+		if keep[t] {
+			result = append(result, t)
+		}
+	}
+	return result
+}
+
+// backupMydumper dumps one file per table into the backupDir directory
+// using mydumper's parallel threads.
+func (m *MySQLBackup) backupMydumper(config map[string]string, backupDir string) error {
+	args := append(mydumperConnectionArgs(config),
+		"--database", config["dbname"],
+		"--outputdir", backupDir,
+	)
+	if config["threads"] != "" {
+		args = append(args, "--threads", config["threads"])
+	}
+	if enabled(config, "routines") {
+		args = append(args, "--routines")
+	}
+	if enabled(config, "triggers") {
+		args = append(args, "--triggers")
+	}
+	if enabled(config, "events") {
+		args = append(args, "--events")
+	}
+	if tables := params.List(config, "tables"); len(tables) > 0 {
+		qualified := make([]string, 0, len(tables))
+		for _, table := range tables {
+			qualified = append(qualified, config["dbname"]+"."+table)
+		}
+		args = append(args, "--tables-list", strings.Join(qualified, ","))
+	}
+	if exclude := params.List(config, "exclude-tables"); len(exclude) > 0 {
+		omitFile, err := writeOmitFile(config, exclude)
+		if err != nil {
+			m.Logger.Error("Failed to write mydumper omit list: " + err.Error())
+			return err
+		}
+		args = append(args, "--omit-from-file", omitFile)
+	}
+
+	return m.run(config, ToolMydumper, args, "MySQL backup failed")
+}
+
+// restoreMydumper loads a mydumper directory with myloader, renaming the
+// database when the restore targets a different one.
+func (m *MySQLBackup) restoreMydumper(config map[string]string, backupDir string) error {
+	if len(params.List(config, "tables")) > 0 || len(params.List(config, "exclude-tables")) > 0 {
+		return errors.New("selective restore is not supported for mydumper backups")
+	}
+
+	args := append(mydumperConnectionArgs(config),
+		"--directory", backupDir,
+		"--database", config["dbname"],
+	)
+	if source := config["source-dbname"]; source != "" {
+		args = append(args, "--source-db", source)
+	}
+	if config["threads"] != "" {
+		args = append(args, "--threads", config["threads"])
+	}
+	if config["force"] == "true" {
+		args = append(args, "--overwrite-tables")
+	}
+
+	return m.run(config, "myloader", args, "MySQL restore failed")
+}
+
+// writeOmitFile writes the db.table list mydumper reads from
+// --omit-from-file into the job's temp directory.
+func writeOmitFile(config map[string]string, tables []string) (string, error) {
+	var content strings.Builder
+	for _, table := range tables {
+		content.WriteString(config["dbname"] + "." + table + "\n")
+	}
+
+	file, err := os.CreateTemp(config["temp-dir"], "mydumper-omit-")
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	if _, err := file.WriteString(content.String()); err != nil {
+		return "", err
+	}
+	return file.Name(), nil
+}
+
+func (m *MySQLBackup) run(config map[string]string, name string, args []string, failure string) error {
+	cmd := priority.Command(config, name, args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	m.Logger.Debug("Executing " + name + " command with arguments: " + strings.Join(cmd.Args, " "))
+	if err := cmd.Run(); err != nil {
+		m.Logger.Error(failure + ": " + err.Error() + ". Details: " + stderr.String())
+		return toolerr.Wrap(err, stderr.String())
+	}
+	return nil
+}
@@ -0,0 +1,35 @@
+package mysql
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/itocode21/backup-tool/pkg/manifest"
+)
+
+func TestAutoToolFollowsStoredDump(t *testing.T) {
+	// Ни одной утилиты на PATH: без бэкапов auto выбирает mysqldump
+	t.Setenv("PATH", t.TempDir())
+	dir := t.TempDir()
+	config := map[string]string{"backup-dir": dir, "dbname": "orders", "dump-tool": ToolAuto}
+	if path := DefaultBackupFile(config); path != filepath.Join(dir, "orders.sql") {
+		t.Errorf("Expected the mysqldump path without stored dumps, got %s", path)
+	}
+
+	// Каталог mydumper находится, даже если mydumper уже не установлен
+	os.Mkdir(filepath.Join(dir, "orders"), 0755)
+	if path := DefaultBackupFile(config); path != filepath.Join(dir, "orders") {
+		t.Errorf("Expected the stored mydumper directory, got %s", path)
+	}
+
+	// Более новый скрипт, от которого остался только манифест в репозитории
+	script := manifest.Path(filepath.Join(dir, "orders.sql"))
+	os.WriteFile(script, []byte("{}"), 0644)
+	later := time.Now().Add(time.Minute)
+	os.Chtimes(script, later, later)
+	if path := DefaultBackupFile(config); path != filepath.Join(dir, "orders.sql") {
+		t.Errorf("Expected the newer script, got %s", path)
+	}
+}
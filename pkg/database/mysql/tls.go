@@ -0,0 +1,60 @@
+package mysql
+
+// sslModes maps the tool-neutral tls-mode values to --ssl-mode.
+var sslModes = map[string]string{
+	"disable":     "DISABLED",
+	"require":     "REQUIRED",
+	"verify-ca":   "VERIFY_CA",
+	"verify-full": "VERIFY_IDENTITY",
+}
+
+// connectionArgs returns the connection and TLS options shared by the
+// mysql, mysqldump and mysqlpump clients.
+func connectionArgs(config map[string]string) []string {
+	args := []string{
+		"--user=" + config["username"],
+		"--password=" + config["password"],
+		"--host=" + config["host"],
+		"--port=" + config["port"],
+	}
+	for _, option := range tlsOptions(config) {
+		args = append(args, "--"+option[0]+"="+option[1])
+	}
+	return args
+}
+
+// mydumperConnectionArgs returns the same options in the spelling used by
+// mydumper and myloader.
+func mydumperConnectionArgs(config map[string]string) []string {
+	args := []string{
+		"--host", config["host"],
+		"--port", config["port"],
+		"--user", config["username"],
+		"--password", config["password"],
+	}
+	names := map[string]string{"ssl-mode": "--ssl-mode", "ssl-ca": "--ca", "ssl-cert": "--cert", "ssl-key": "--key"}
+	for _, option := range tlsOptions(config) {
+		args = append(args, names[option[0]], option[1])
+	}
+	return args
+}
+
+// tlsOptions lists the --ssl-* option names and values for config.
+// Skipping verification only keeps the connection encrypted.
+func tlsOptions(config map[string]string) [][2]string {
+	mode := config["tls-mode"]
+	if config["tls-skip-verify"] == "true" && mode != "disable" {
+		mode = "require"
+	}
+
+	var options [][2]string
+	if mode != "" {
+		options = append(options, [2]string{"ssl-mode", sslModes[mode]})
+	}
+	for _, file := range [][2]string{{"tls-ca", "ssl-ca"}, {"tls-cert", "ssl-cert"}, {"tls-key", "ssl-key"}} {
+		if config[file[0]] != "" {
+			options = append(options, [2]string{file[1], config[file[0]]})
+		}
+	}
+	return options
+}
@@ -0,0 +1,41 @@
+package mysql
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+
+	"github.com/itocode21/backup-tool/pkg/database/partial"
+)
+
+// verifyDump checks that a dump written by tool ran to the end before it is
+// moved into place: mysqldump closes its script with a "Dump completed"
+// comment and mydumper writes its metadata file last.
+func verifyDump(path, tool string) error {
+	switch tool {
+	case ToolMydumper:
+		if _, err := os.Stat(filepath.Join(path, "metadata")); err != nil {
+			return errors.New("mydumper output has no metadata file")
+		}
+		return nil
+	case ToolMysqldump:
+		tail, err := partial.Tail(path, 1024)
+		if err != nil {
+			return err
+		}
+		if !bytes.Contains(tail, []byte("-- Dump completed")) {
+			return errors.New("mysqldump output ends before the dump completed comment")
+		}
+		return nil
+	default:
+		info, err := os.Stat(path)
+		if err != nil {
+			return err
+		}
+		if info.Size() == 0 {
+			return errors.New(tool + " output is empty")
+		}
+		return nil
+	}
+}
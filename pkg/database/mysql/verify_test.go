@@ -0,0 +1,41 @@
+package mysql
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestVerifyDump(t *testing.T) {
+	dir := t.TempDir()
+
+	complete := filepath.Join(dir, "complete.sql")
+	truncated := filepath.Join(dir, "truncated.sql")
+	empty := filepath.Join(dir, "empty.sql")
+	mydumper := filepath.Join(dir, "mydumper")
+	unfinished := filepath.Join(dir, "unfinished")
+
+	os.WriteFile(complete, []byte(sampleDump+"-- Dump completed on 2024-05-01 10:00:00\n"), 0644)
+	os.WriteFile(truncated, []byte(sampleDump), 0644)
+	os.WriteFile(empty, nil, 0644)
+	os.Mkdir(mydumper, 0755)
+	os.WriteFile(filepath.Join(mydumper, "metadata"), []byte("Finished dump at: 2024-05-01 10:00:00\n"), 0644)
+	os.Mkdir(unfinished, 0755)
+
+	tests := []struct {
+		path, tool string
+		ok         bool
+	}{
+		{complete, ToolMysqldump, true},
+		{truncated, ToolMysqldump, false},
+		{truncated, ToolMysqlpump, true},
+		{empty, ToolMysqlpump, false},
+		{mydumper, ToolMydumper, true},
+		{unfinished, ToolMydumper, false},
+	}
+	for _, tt := range tests {
+		if err := verifyDump(tt.path, tt.tool); (err == nil) != tt.ok {
+			t.Errorf("verifyDump(%s, %s): expected ok=%v, got %v", tt.path, tt.tool, tt.ok, err)
+		}
+	}
+}
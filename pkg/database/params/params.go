@@ -0,0 +1,15 @@
+package params
+
+import "strings"
+
+// List returns the comma-separated values stored under key, with blanks
+// removed.
+func List(config map[string]string, key string) []string {
+	var values []string
+	for _, v := range strings.Split(config[key], ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
@@ -0,0 +1,96 @@
+package partial
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Path returns the name an artifact is written under until it is complete,
+// so that a file at the artifact's own path is never a cut-off dump.
+func Path(artifact string) string {
+	return artifact + ".partial"
+}
+
+// Commit flushes the finished write at src to disk and renames it to
+// artifact, replacing an older artifact. src is usually Path(artifact);
+// directory artifacts are flushed file by file.
+func Commit(src, artifact string) error {
+	if err := syncTree(src); err != nil {
+		return err
+	}
+
+	// A directory cannot be renamed over a non-empty one, so an older
+	// directory artifact is moved aside first. It is put back if the new
+	// one cannot take its place, and removed only once it has.
+	var old string
+	if info, err := os.Stat(artifact); err == nil && info.IsDir() {
+		old = artifact + ".old"
+		if err := os.RemoveAll(old); err != nil {
+			return err
+		}
+		if err := rename(artifact, old); err != nil {
+			return err
+		}
+	}
+	if err := rename(src, artifact); err != nil {
+		if old != "" {
+			if restoreErr := rename(old, artifact); restoreErr != nil {
+				return fmt.Errorf("%w; previous artifact left at %s: %v", err, old, restoreErr)
+			}
+		}
+		return err
+	}
+	if old != "" {
+		if err := os.RemoveAll(old); err != nil {
+			return err
+		}
+	}
+	return syncPath(filepath.Dir(artifact))
+}
+
+// rename is os.Rename, replaceable in tests.
+var rename = os.Rename
+
+// syncTree flushes path and, for a directory, everything below it.
+func syncTree(path string) error {
+	return filepath.Walk(path, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		return syncPath(path)
+	})
+}
+
+func syncPath(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return file.Sync()
+}
+
+// Tail returns up to the last n bytes of the file at path, where dump tools
+// write the trailer that marks a finished dump.
+func Tail(path string, n int64) ([]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	offset := info.Size() - n
+	if offset < 0 {
+		offset = 0
+	}
+	tail := make([]byte, info.Size()-offset)
+	if _, err := file.ReadAt(tail, offset); err != nil {
+		return nil, err
+	}
+	return tail, nil
+}
@@ -0,0 +1,87 @@
+package partial
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCommitFile(t *testing.T) {
+	artifact := filepath.Join(t.TempDir(), "db.sql")
+	os.WriteFile(artifact, []byte("old"), 0644)
+	os.WriteFile(Path(artifact), []byte("new"), 0644)
+
+	if err := Commit(Path(artifact), artifact); err != nil {
+		t.Fatalf("Commit returned error: %v", err)
+	}
+	if data, _ := os.ReadFile(artifact); string(data) != "new" {
+		t.Errorf("Expected the new dump at the artifact path, got %q", data)
+	}
+	if _, err := os.Stat(Path(artifact)); !os.IsNotExist(err) {
+		t.Errorf("Expected the partial file to be gone, got %v", err)
+	}
+}
+
+func TestCommitReplacesDirectory(t *testing.T) {
+	artifact := filepath.Join(t.TempDir(), "db")
+	os.MkdirAll(artifact, 0755)
+	os.WriteFile(filepath.Join(artifact, "stale.bson"), []byte("old"), 0644)
+	os.MkdirAll(Path(artifact), 0755)
+	os.WriteFile(filepath.Join(Path(artifact), "orders.bson"), []byte("new"), 0644)
+
+	if err := Commit(Path(artifact), artifact); err != nil {
+		t.Fatalf("Commit returned error: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(artifact, "orders.bson")); err != nil {
+		t.Errorf("Expected the new dump in the artifact directory: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(artifact, "stale.bson")); !os.IsNotExist(err) {
+		t.Errorf("Expected files of the old dump to be gone, got %v", err)
+	}
+	if _, err := os.Stat(artifact + ".old"); !os.IsNotExist(err) {
+		t.Errorf("Expected the old directory to be removed, got %v", err)
+	}
+}
+
+func TestTail(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "db.sql")
+	os.WriteFile(path, []byte("CREATE TABLE t;\n-- Dump completed\n"), 0644)
+
+	tail, err := Tail(path, 18)
+	if err != nil {
+		t.Fatalf("Tail returned error: %v", err)
+	}
+	if string(tail) != "-- Dump completed\n" {
+		t.Errorf("Unexpected tail %q", tail)
+	}
+	if tail, _ := Tail(path, 1024); len(tail) != 34 {
+		t.Errorf("Expected the whole file for a short dump, got %d bytes", len(tail))
+	}
+}
+
+func TestCommitKeepsDirectoryOnFailure(t *testing.T) {
+	artifact := filepath.Join(t.TempDir(), "db")
+	os.MkdirAll(artifact, 0755)
+	os.WriteFile(filepath.Join(artifact, "orders.bson"), []byte("old"), 0644)
+	os.MkdirAll(Path(artifact), 0755)
+
+	// Переименование нового каталога на место артефакта не удаётся
+	rename = func(from, to string) error {
+		if from == Path(artifact) {
+			return errors.New("rename failed")
+		}
+		return os.Rename(from, to)
+	}
+	defer func() { rename = os.Rename }()
+
+	if err := Commit(Path(artifact), artifact); err == nil {
+		t.Fatal("Expected Commit to fail")
+	}
+	if data, err := os.ReadFile(filepath.Join(artifact, "orders.bson")); err != nil || string(data) != "old" {
+		t.Errorf("Expected the previous backup to stay at the artifact path, got %q, %v", data, err)
+	}
+	if _, err := os.Stat(artifact + ".old"); !os.IsNotExist(err) {
+		t.Errorf("Expected no directory left aside, got %v", err)
+	}
+}
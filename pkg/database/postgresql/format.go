@@ -0,0 +1,196 @@
+package postgresql
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/itocode21/backup-tool/pkg/database/params"
+	"github.com/itocode21/backup-tool/pkg/database/partial"
+	"github.com/itocode21/backup-tool/pkg/database/script"
+	"github.com/itocode21/backup-tool/pkg/database/toolerr"
+	"github.com/itocode21/backup-tool/pkg/manifest"
+)
+
+// pg_dump output formats, keyed by the name used in config and manifests.
+const (
+	FormatPlain     = "plain"
+	FormatCustom    = "custom"
+	FormatDirectory = "directory"
+	FormatTar       = "tar"
+)
+
+var formatExtensions = map[string]string{
+	FormatPlain:     ".sql",
+	FormatCustom:    ".dump",
+	FormatDirectory: "",
+	FormatTar:       ".tar",
+}
+
+// ValidFormat reports whether format is a pg_dump format this engine supports.
+func ValidFormat(format string) bool {
+	_, ok := formatExtensions[format]
+	return ok
+}
+
+// dumpFormat returns the configured format, defaulting to plain SQL.
+func dumpFormat(config map[string]string) string {
+	if config["format"] == "" {
+		return FormatPlain
+	}
+	return config["format"]
+}
+
+// artifactFormat works out the format of an existing artifact: from its
+// manifest when there is one, otherwise from what is on disk.
+func artifactFormat(path string) (string, error) {
+	if m, err := manifest.Read(path); err == nil && m.Format != "" {
+		return m.Format, nil
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	if info.IsDir() {
+		return FormatDirectory, nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	header := make([]byte, 512)
+	n, _ := io.ReadFull(file, header)
+	header = header[:n]
+	switch {
+	case bytes.HasPrefix(header, []byte("PGDMP")):
+		return FormatCustom, nil
+	case len(header) >= 262 && string(header[257:262]) == "ustar":
+		return FormatTar, nil
+	default:
+		return FormatPlain, nil
+	}
+}
+
+// verifyDump checks that pg_dump finished writing path in format before it
+// is moved into place: plain scripts end with a "dump complete" comment,
+// archives start with their format's header and directories hold a table of
+// contents.
+func verifyDump(path, format string) error {
+	found, err := artifactFormat(path)
+	if err != nil {
+		return err
+	}
+	if found != format {
+		return errors.New("pg_dump output is not a " + format + " dump")
+	}
+	switch format {
+	case FormatPlain:
+		tail, err := partial.Tail(path, 1024)
+		if err != nil {
+			return err
+		}
+		if !bytes.Contains(tail, []byte("-- PostgreSQL database dump complete")) {
+			return errors.New("pg_dump output ends before the dump complete comment")
+		}
+	case FormatDirectory:
+		if _, err := os.Stat(filepath.Join(path, "toc.dat")); err != nil {
+			return errors.New("pg_dump output has no toc.dat")
+		}
+	}
+	return nil
+}
+
+// CountTables returns how many tables the artifact at backup-file holds,
+// from its CREATE TABLE statements or the table of contents of an archive.
+func (p *PostgreSQLBackup) CountTables(config map[string]string) (int, error) {
+	path := config["backup-file"]
+	format, err := artifactFormat(path)
+	if err != nil {
+		return 0, err
+	}
+	if format == FormatPlain {
+		file, err := os.Open(path)
+		if err != nil {
+			return 0, err
+		}
+		defer file.Close()
+		return script.CountCreateTables(file)
+	}
+
+	cmd := exec.Command("pg_restore", "-l", path)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return 0, toolerr.Wrap(err, stderr.String())
+	}
+	count := 0
+	for _, line := range strings.Split(stdout.String(), "\n") {
+		// e.g. "215; 1259 16386 TABLE public orders postgres", next to
+		// "TABLE DATA" entries for the contents.
+		fields := strings.Fields(line)
+		if len(fields) > 4 && fields[3] == "TABLE" && fields[4] != "DATA" {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// restoreArchive restores a custom, directory or tar archive with
+// pg_restore, optionally limited to the listed tables.
+func (p *PostgreSQLBackup) restoreArchive(config map[string]string, backupFilePath, format string, tables []string) error {
+	args := connectionArgs(config, config["dbname"])
+	if jobs := config["jobs"]; jobs != "" && format != FormatTar {
+		args = append(args, "-j", jobs)
+	}
+	if config["clean"] == "true" || config["force"] == "true" {
+		args = append(args, "--clean", "--if-exists")
+	}
+	if config["no-owner"] == "true" {
+		args = append(args, "--no-owner")
+	}
+	for _, table := range tables {
+		args = append(args, "-t", table)
+	}
+	args = append(args, backupFilePath)
+
+	cmd := exec.Command("pg_restore", args...)
+	cmd.Env = environment(config)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	p.Logger.Debug("Executing pg_restore command with arguments: " + strings.Join(cmd.Args, " "))
+	if err := cmd.Run(); err != nil {
+		p.Logger.Error("PostgreSQL restore failed: " + err.Error() + ". Details: " + stderr.String())
+		return toolerr.Wrap(err, stderr.String())
+	}
+
+	if len(tables) > 0 {
+		p.Logger.Info("Restored tables: " + strings.Join(tables, ", "))
+	}
+	return nil
+}
+
+// selectedTables validates table filters for a restore. Only archives can
+// be filtered, and pg_restore has no exclusion switch for tables.
+func selectedTables(config map[string]string, format string) ([]string, error) {
+	include, exclude := params.List(config, "tables"), params.List(config, "exclude-tables")
+	if len(include) == 0 && len(exclude) == 0 {
+		return nil, nil
+	}
+	if format == FormatPlain {
+		return nil, errors.New("selective restore requires a custom, directory or tar dump")
+	}
+	if len(exclude) > 0 {
+		return nil, errors.New("exclude-tables is only supported for backups; list the tables to restore instead")
+	}
+	return include, nil
+}
@@ -0,0 +1,89 @@
+package postgresql
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/itocode21/backup-tool/pkg/manifest"
+)
+
+func TestArtifactFormat(t *testing.T) {
+	dir := t.TempDir()
+
+	plain := filepath.Join(dir, "db.sql")
+	custom := filepath.Join(dir, "db.dump")
+	directory := filepath.Join(dir, "db")
+	labelled := filepath.Join(dir, "labelled.bin")
+
+	os.WriteFile(plain, []byte("CREATE TABLE t (id int);\n"), 0644)
+	os.WriteFile(custom, []byte("PGDMP\x01\x0e"), 0644)
+	os.Mkdir(directory, 0755)
+	os.WriteFile(labelled, []byte("CREATE TABLE t (id int);\n"), 0644)
+	if err := manifest.Write(&manifest.Manifest{Artifact: labelled, Format: FormatTar}); err != nil {
+		t.Fatalf("Failed to write manifest: %v", err)
+	}
+
+	tests := map[string]string{
+		plain:     FormatPlain,
+		custom:    FormatCustom,
+		directory: FormatDirectory,
+		labelled:  FormatTar,
+	}
+	for path, expected := range tests {
+		format, err := artifactFormat(path)
+		if err != nil {
+			t.Errorf("artifactFormat(%s) returned error: %v", path, err)
+			continue
+		}
+		if format != expected {
+			t.Errorf("artifactFormat(%s): expected %s, got %s", path, expected, format)
+		}
+	}
+}
+
+func TestDefaultBackupFileFollowsFormat(t *testing.T) {
+	config := map[string]string{"dbname": "orders", "backup-dir": "backups", "format": FormatCustom}
+	if path := DefaultBackupFile(config); path != filepath.Join("backups", "orders.dump") {
+		t.Errorf("Unexpected default path for custom format: %s", path)
+	}
+
+	config["format"] = FormatDirectory
+	if path := DefaultBackupFile(config); path != filepath.Join("backups", "orders") {
+		t.Errorf("Unexpected default path for directory format: %s", path)
+	}
+}
+
+func TestVerifyDump(t *testing.T) {
+	dir := t.TempDir()
+
+	complete := filepath.Join(dir, "complete.sql")
+	truncated := filepath.Join(dir, "truncated.sql")
+	custom := filepath.Join(dir, "db.dump")
+	directory := filepath.Join(dir, "db")
+	unfinished := filepath.Join(dir, "unfinished")
+
+	os.WriteFile(complete, []byte("CREATE TABLE t (id int);\n--\n-- PostgreSQL database dump complete\n--\n"), 0644)
+	os.WriteFile(truncated, []byte("CREATE TABLE t (id int);\nCOPY t (id) FROM stdin;\n1\n"), 0644)
+	os.WriteFile(custom, []byte("PGDMP\x01\x0e"), 0644)
+	os.Mkdir(directory, 0755)
+	os.WriteFile(filepath.Join(directory, "toc.dat"), []byte("PGDMP"), 0644)
+	os.Mkdir(unfinished, 0755)
+
+	tests := []struct {
+		path, format string
+		ok           bool
+	}{
+		{complete, FormatPlain, true},
+		{truncated, FormatPlain, false},
+		{custom, FormatCustom, true},
+		{complete, FormatCustom, false},
+		{directory, FormatDirectory, true},
+		{unfinished, FormatDirectory, false},
+	}
+	for _, tt := range tests {
+		if err := verifyDump(tt.path, tt.format); (err == nil) != tt.ok {
+			t.Errorf("verifyDump(%s, %s): expected ok=%v, got %v", tt.path, tt.format, tt.ok, err)
+		}
+	}
+}
@@ -2,17 +2,31 @@ package postgresql
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
 
+	"github.com/itocode21/backup-tool/pkg/database/params"
+	"github.com/itocode21/backup-tool/pkg/database/partial"
+	"github.com/itocode21/backup-tool/pkg/database/priority"
+	"github.com/itocode21/backup-tool/pkg/database/toolerr"
 	"github.com/itocode21/backup-tool/pkg/logging"
+	"github.com/itocode21/backup-tool/pkg/throttle"
 )
 
 type PostgreSQLBackup struct {
 	Logger *logging.Logger
+
+	writes *throttle.Limiter
+}
+
+// LimitWrites paces how fast plain dumps are written to the artifact.
+// pg_dump writes archives itself, so those are not paced.
+func (p *PostgreSQLBackup) LimitWrites(l *throttle.Limiter) {
+	p.writes = l
 }
 
 func (p *PostgreSQLBackup) PerformFullBackup(config map[string]string) error {
@@ -25,10 +39,14 @@ func (p *PostgreSQLBackup) PerformFullBackup(config map[string]string) error {
 		}
 	}
 
-	defaultBackupFile := filepath.Join("backups", "postgresql", config["dbname"]+".sql")
+	format := dumpFormat(config)
+	if !ValidFormat(format) {
+		return errors.New("unsupported pg_dump format: " + format)
+	}
+
 	backupFilePath := config["backup-file"]
 	if backupFilePath == "" {
-		backupFilePath = defaultBackupFile
+		backupFilePath = DefaultBackupFile(config)
 	}
 
 	backupDir := filepath.Dir(backupFilePath)
@@ -38,15 +56,43 @@ func (p *PostgreSQLBackup) PerformFullBackup(config map[string]string) error {
 		return err
 	}
 
-	cmd := exec.Command("pg_dump",
-		"-U", config["username"],
-		"-h", config["host"],
-		"-p", config["port"],
-		"-d", config["dbname"],
-		"-f", backupFilePath,
-	)
-	os.Setenv("PGPASSWORD", config["password"])
-	defer os.Unsetenv("PGPASSWORD")
+	// pg_dump writes under a partial name, which is renamed to the artifact
+	// once the dump is complete, so a failed run never leaves a cut-off dump
+	// at the real path.
+	tempFile := partial.Path(backupFilePath)
+	os.RemoveAll(tempFile)
+	defer os.RemoveAll(tempFile)
+
+	args := append(connectionArgs(config, config["dbname"]), "-F", format[:1])
+	if format != FormatPlain {
+		args = append(args, "-f", tempFile)
+	}
+	if jobs := config["jobs"]; jobs != "" && format == FormatDirectory {
+		args = append(args, "-j", jobs)
+	}
+	for _, table := range params.List(config, "tables") {
+		args = append(args, "-t", table)
+	}
+	for _, table := range params.List(config, "exclude-tables") {
+		args = append(args, "-T", table)
+	}
+
+	cmd := priority.Command(config, "pg_dump", args...)
+	cmd.Env = environment(config)
+
+	// Plain dumps go through stdout so their writes can be paced. Archives
+	// are written by pg_dump itself: it seeks back to record data offsets,
+	// which pg_restore -j and -t need, and cannot do that on a pipe.
+	var output *os.File
+	if format == FormatPlain {
+		output, err = os.Create(tempFile)
+		if err != nil {
+			p.Logger.Error("Failed to create backup file: " + err.Error())
+			return err
+		}
+		defer output.Close()
+		cmd.Stdout = throttle.Writer(output, p.writes)
+	}
 
 	var stderr bytes.Buffer
 	cmd.Stderr = &stderr
@@ -54,6 +100,19 @@ func (p *PostgreSQLBackup) PerformFullBackup(config map[string]string) error {
 	err = cmd.Run()
 	if err != nil {
 		p.Logger.Error("PostgreSQL backup failed: " + err.Error() + ". Details: " + stderr.String())
+		return toolerr.Wrap(err, stderr.String())
+	}
+	if output != nil {
+		if err := output.Close(); err != nil {
+			return err
+		}
+	}
+	if err := verifyDump(tempFile, format); err != nil {
+		p.Logger.Error("PostgreSQL backup is incomplete: " + err.Error())
+		return err
+	}
+	if err := partial.Commit(tempFile, backupFilePath); err != nil {
+		p.Logger.Error("Failed to move backup into place: " + err.Error())
 		return err
 	}
 
@@ -64,20 +123,54 @@ func (p *PostgreSQLBackup) PerformFullBackup(config map[string]string) error {
 func (p *PostgreSQLBackup) RestoreBackup(config map[string]string) error {
 	p.Logger.Info("Starting PostgreSQL restore...")
 
-	requiredParams := []string{"host", "port", "username", "password", "dbname", "backup-file"}
+	requiredParams := []string{"host", "port", "username", "password", "dbname"}
 	for _, param := range requiredParams {
 		if config[param] == "" {
 			return errors.New("missing required parameter: " + param)
 		}
 	}
 
-	cmd := exec.Command("psql",
-		"-U", config["username"],
-		"-h", config["host"],
-		"-p", config["port"],
-		"-d", config["dbname"],
-		"-f", config["backup-file"],
-	)
+	backupFilePath := config["backup-file"]
+	if backupFilePath == "" {
+		backupFilePath = DefaultBackupFile(config)
+	}
+
+	if source := config["source-dbname"]; source != "" && source != config["dbname"] {
+		if err := p.ensureDatabase(config); err != nil {
+			return err
+		}
+	}
+
+	format, err := artifactFormat(backupFilePath)
+	if err != nil {
+		p.Logger.Error("Failed to open backup file: " + err.Error())
+		return err
+	}
+	tables, err := selectedTables(config, format)
+	if err != nil {
+		return err
+	}
+
+	if format == FormatPlain {
+		err = p.restoreScript(config, backupFilePath)
+	} else {
+		err = p.restoreArchive(config, backupFilePath, format, tables)
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := p.renameSchemas(config); err != nil {
+		return err
+	}
+
+	p.Logger.Info("PostgreSQL restore completed successfully.")
+	return nil
+}
+
+func (p *PostgreSQLBackup) restoreScript(config map[string]string, backupFilePath string) error {
+	cmd := exec.Command("psql", append(connectionArgs(config, config["dbname"]), "-f", backupFilePath)...)
+	cmd.Env = environment(config)
 
 	var stderr bytes.Buffer
 	cmd.Stderr = &stderr
@@ -86,9 +179,128 @@ func (p *PostgreSQLBackup) RestoreBackup(config map[string]string) error {
 	err := cmd.Run()
 	if err != nil {
 		p.Logger.Error("PostgreSQL restore failed: " + err.Error() + ". Details: " + stderr.String())
+		return toolerr.Wrap(err, stderr.String())
+	}
+	return nil
+}
+
+// DefaultBackupFile returns the artifact path used when no backup-file is
+// given: <backup-dir>/<dbname> with the extension of the dump format, or the
+// same name under backups/postgresql.
+func DefaultBackupFile(config map[string]string) string {
+	backupDir := config["backup-dir"]
+	if backupDir == "" {
+		backupDir = filepath.Join("backups", "postgresql")
+	}
+	return filepath.Join(backupDir, config["dbname"]+formatExtensions[dumpFormat(config)])
+}
+
+var systemDatabases = map[string]bool{
+	"postgres":  true,
+	"template0": true,
+	"template1": true,
+}
+
+func (p *PostgreSQLBackup) ListDatabases(config map[string]string) ([]string, error) {
+	output, err := p.query(config, "postgres", "SELECT datname FROM pg_database WHERE datallowconn AND NOT datistemplate")
+	if err != nil {
+		p.Logger.Error("Failed to list PostgreSQL databases: " + err.Error())
+		return nil, err
+	}
+
+	var databases []string
+	for _, name := range strings.Split(output, "\n") {
+		if name = strings.TrimSpace(name); name != "" && !systemDatabases[name] {
+			databases = append(databases, name)
+		}
+	}
+	return databases, nil
+}
+
+// HasData reports whether the database in config contains any relations
+// outside the system schemas. A missing database counts as empty.
+func (p *PostgreSQLBackup) HasData(config map[string]string) (bool, error) {
+	exists, err := p.databaseExists(config)
+	if err != nil || !exists {
+		return false, err
+	}
+
+	output, err := p.query(config, config["dbname"], `SELECT count(*) FROM pg_class c
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		WHERE n.nspname NOT IN ('pg_catalog', 'information_schema') AND n.nspname NOT LIKE 'pg_toast%'`)
+	if err != nil {
+		return false, err
+	}
+	return strings.TrimSpace(output) != "0", nil
+}
+
+func (p *PostgreSQLBackup) databaseExists(config map[string]string) (bool, error) {
+	output, err := p.query(config, "postgres", "SELECT 1 FROM pg_database WHERE datname = "+quoteLiteral(config["dbname"]))
+	if err != nil {
+		return false, err
+	}
+	return strings.TrimSpace(output) == "1", nil
+}
+
+// ensureDatabase creates the restore target database when it is missing.
+func (p *PostgreSQLBackup) ensureDatabase(config map[string]string) error {
+	exists, err := p.databaseExists(config)
+	if err != nil || exists {
 		return err
 	}
+	p.Logger.Info("Creating database " + config["dbname"])
+	_, err = p.query(config, "postgres", "CREATE DATABASE "+quoteIdent(config["dbname"]))
+	return err
+}
 
-	p.Logger.Info("PostgreSQL restore completed successfully.")
+// renameSchemas applies the from:to schema renames listed in ns-map after a
+// restore.
+func (p *PostgreSQLBackup) renameSchemas(config map[string]string) error {
+	for _, pair := range params.List(config, "ns-map") {
+		from, to, ok := strings.Cut(pair, ":")
+		if !ok {
+			return errors.New("invalid ns-map entry, expected from:to: " + pair)
+		}
+		p.Logger.Info("Renaming schema " + from + " to " + to)
+		if _, err := p.query(config, config["dbname"], "ALTER SCHEMA "+quoteIdent(from)+" RENAME TO "+quoteIdent(to)); err != nil {
+			p.Logger.Error("Failed to rename schema: " + err.Error())
+			return err
+		}
+	}
 	return nil
 }
+
+// Exec runs a statement against the database in config.
+func (p *PostgreSQLBackup) Exec(ctx context.Context, config map[string]string, statement string) error {
+	_, err := p.queryContext(ctx, config, config["dbname"], statement)
+	return err
+}
+
+// query runs a single statement with psql against dbname and returns its
+// unaligned output without headers.
+func (p *PostgreSQLBackup) query(config map[string]string, dbname, statement string) (string, error) {
+	return p.queryContext(context.Background(), config, dbname, statement)
+}
+
+// queryContext is query with psql killed when ctx is done.
+func (p *PostgreSQLBackup) queryContext(ctx context.Context, config map[string]string, dbname, statement string) (string, error) {
+	cmd := exec.CommandContext(ctx, "psql", append(connectionArgs(config, dbname), "-At", "-c", statement)...)
+	cmd.Env = environment(config)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	p.Logger.Debug("Executing psql command with arguments: " + strings.Join(cmd.Args, " "))
+	if err := cmd.Run(); err != nil {
+		return "", errors.New(err.Error() + ". Details: " + stderr.String())
+	}
+	return stdout.String(), nil
+}
+
+func quoteIdent(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+func quoteLiteral(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+}
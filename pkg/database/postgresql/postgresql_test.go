@@ -0,0 +1,65 @@
+package postgresql
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/itocode21/backup-tool/pkg/config"
+	"github.com/itocode21/backup-tool/pkg/logging"
+)
+
+// fakePgDump подменяет pg_dump: пишет архив в файл из -f или plain-дамп в stdout
+// и сохраняет свои аргументы в args.log
+func fakePgDump(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	script := `#!/bin/sh
+echo "$@" > ` + filepath.Join(dir, "args.log") + `
+while [ $# -gt 0 ]; do
+	if [ "$1" = "-f" ]; then printf 'PGDMP' > "$2"; exit 0; fi
+	shift
+done
+echo "-- PostgreSQL database dump complete"
+`
+	if err := os.WriteFile(filepath.Join(dir, "pg_dump"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	return filepath.Join(dir, "args.log")
+}
+
+func TestArchivesAreWrittenByPgDump(t *testing.T) {
+	argsLog := fakePgDump(t)
+	logger, err := logging.NewLogger(&config.Config{})
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+	logger.SetOutput(&bytes.Buffer{})
+	engine := &PostgreSQLBackup{Logger: logger}
+
+	dir := t.TempDir()
+	for _, tt := range []struct {
+		format string
+		toFile bool
+	}{
+		{FormatCustom, true},
+		{FormatPlain, false},
+	} {
+		backupFile := filepath.Join(dir, "orders-"+tt.format)
+		err := engine.PerformFullBackup(map[string]string{
+			"host": "db", "port": "5432", "username": "u", "password": "p", "dbname": "orders",
+			"format": tt.format, "backup-file": backupFile,
+		})
+		if err != nil {
+			t.Fatalf("%s backup failed: %v", tt.format, err)
+		}
+		// Архив должен писать сам pg_dump: через pipe он не может дописать смещения данных
+		args, _ := os.ReadFile(argsLog)
+		if got := strings.Contains(string(args), " -f "); got != tt.toFile {
+			t.Errorf("%s: expected -f to be %v, got args %q", tt.format, tt.toFile, args)
+		}
+	}
+}
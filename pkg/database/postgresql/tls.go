@@ -0,0 +1,45 @@
+package postgresql
+
+import (
+	"net"
+	"os"
+)
+
+// connectionArgs returns the -U/-h/-p/-d options for the libpq clients.
+// When tls-server-name is set and host is an IP address, -h carries the
+// name to verify and PGHOSTADDR (see environment) the address to dial.
+func connectionArgs(config map[string]string, dbname string) []string {
+	host := config["host"]
+	if serverName := config["tls-server-name"]; serverName != "" && net.ParseIP(host) != nil {
+		host = serverName
+	}
+	return []string{
+		"-U", config["username"],
+		"-h", host,
+		"-p", config["port"],
+		"-d", dbname,
+	}
+}
+
+// environment returns the process environment for the libpq clients with
+// the password and the PGSSL* settings for config.
+func environment(config map[string]string) []string {
+	env := append(os.Environ(), "PGPASSWORD="+config["password"])
+
+	mode := config["tls-mode"]
+	if config["tls-skip-verify"] == "true" && mode != "disable" {
+		mode = "require"
+	}
+	if mode != "" {
+		env = append(env, "PGSSLMODE="+mode)
+	}
+	for _, file := range [][2]string{{"tls-ca", "PGSSLROOTCERT"}, {"tls-cert", "PGSSLCERT"}, {"tls-key", "PGSSLKEY"}} {
+		if config[file[0]] != "" {
+			env = append(env, file[1]+"="+config[file[0]])
+		}
+	}
+	if config["tls-server-name"] != "" && net.ParseIP(config["host"]) != nil {
+		env = append(env, "PGHOSTADDR="+config["host"])
+	}
+	return env
+}
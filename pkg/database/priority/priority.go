@@ -0,0 +1,39 @@
+package priority
+
+import (
+	"context"
+	"os/exec"
+)
+
+// Parameters that lower the CPU and I/O priority of dump tools. The pool
+// sets them from the throttle limits in force when a backup starts.
+const (
+	Nice        = "nice"
+	IONiceClass = "ionice-class"
+	IONiceLevel = "ionice-level"
+)
+
+var ioniceClasses = map[string]string{"realtime": "1", "best-effort": "2", "idle": "3"}
+
+// Command returns exec.Command(name, args...) run through ionice and nice
+// as config asks. Without those parameters the tool runs directly.
+func Command(config map[string]string, name string, args ...string) *exec.Cmd {
+	return CommandContext(context.Background(), config, name, args...)
+}
+
+// CommandContext is like Command but kills the tool when ctx is done.
+func CommandContext(ctx context.Context, config map[string]string, name string, args ...string) *exec.Cmd {
+	if class := ioniceClasses[config[IONiceClass]]; class != "" {
+		prefix := []string{"-c", class}
+		if class != ioniceClasses["idle"] && config[IONiceLevel] != "" {
+			prefix = append(prefix, "-n", config[IONiceLevel])
+		}
+		args = append(append(prefix, name), args...)
+		name = "ionice"
+	}
+	if nice := config[Nice]; nice != "" && nice != "0" {
+		args = append([]string{"-n", nice, name}, args...)
+		name = "nice"
+	}
+	return exec.CommandContext(ctx, name, args...)
+}
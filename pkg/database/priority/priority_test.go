@@ -0,0 +1,27 @@
+package priority
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestCommand(t *testing.T) {
+	tests := []struct {
+		config map[string]string
+		want   []string
+	}{
+		{map[string]string{}, []string{"pg_dump", "-f", "out"}},
+		{map[string]string{Nice: "10"}, []string{"nice", "-n", "10", "pg_dump", "-f", "out"}},
+		{map[string]string{IONiceClass: "idle", IONiceLevel: "4"}, []string{"ionice", "-c", "3", "pg_dump", "-f", "out"}},
+		{
+			map[string]string{Nice: "19", IONiceClass: "best-effort", IONiceLevel: "7"},
+			[]string{"nice", "-n", "19", "ionice", "-c", "2", "-n", "7", "pg_dump", "-f", "out"},
+		},
+	}
+
+	for _, tt := range tests {
+		if got := Command(tt.config, "pg_dump", "-f", "out").Args; !slices.Equal(got, tt.want) {
+			t.Errorf("Command(%v) = %v, want %v", tt.config, got, tt.want)
+		}
+	}
+}
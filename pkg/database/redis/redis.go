@@ -0,0 +1,320 @@
+package redis
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/itocode21/backup-tool/pkg/database/partial"
+	"github.com/itocode21/backup-tool/pkg/database/priority"
+	"github.com/itocode21/backup-tool/pkg/logging"
+)
+
+// Backup modes. ModeRDB streams a snapshot over the replication protocol and
+// works against remote servers; ModeBGSave asks the server to write its own
+// RDB file and copies it, so the tool must run where that file is readable.
+const (
+	ModeRDB    = "rdb"
+	ModeBGSave = "bgsave"
+)
+
+// bgsaveTimeout bounds how long to wait for LASTSAVE to move after BGSAVE.
+var bgsaveTimeout = time.Hour
+
+type RedisBackup struct {
+	Logger *logging.Logger
+}
+
+func (r *RedisBackup) PerformFullBackup(config map[string]string) error {
+	r.Logger.Info("Starting full Redis backup...")
+
+	requiredParams := []string{"host", "port", "dbname"}
+	for _, param := range requiredParams {
+		if config[param] == "" {
+			return errors.New("missing required parameter: " + param)
+		}
+	}
+
+	backupFilePath := config["backup-file"]
+	if backupFilePath == "" {
+		backupFilePath = DefaultBackupFile(config)
+	}
+
+	backupDir := filepath.Dir(backupFilePath)
+	err := os.MkdirAll(backupDir, os.ModePerm)
+	if err != nil {
+		r.Logger.Error("Failed to create backup directory: " + err.Error())
+		return err
+	}
+
+	tempFile := partial.Path(backupFilePath)
+	defer os.Remove(tempFile)
+
+	switch mode := config["redis-mode"]; mode {
+	case "", ModeRDB:
+		_, err = r.cli(config, "--rdb", tempFile)
+	case ModeBGSave:
+		err = r.bgsave(config, tempFile)
+	default:
+		err = errors.New("unsupported redis mode: " + mode)
+	}
+	if err != nil {
+		r.Logger.Error("Redis backup failed: " + err.Error())
+		return err
+	}
+
+	if err := checkRDB(tempFile); err != nil {
+		r.Logger.Error("Redis backup failed: " + err.Error())
+		return err
+	}
+	if err := partial.Commit(tempFile, backupFilePath); err != nil {
+		r.Logger.Error("Failed to move backup into place: " + err.Error())
+		return err
+	}
+
+	r.Logger.Info("Redis backup completed successfully. File saved to: " + backupFilePath)
+	return nil
+}
+
+// RestoreBackup stages the RDB file as the target instance's dump file.
+// Redis only loads RDB files at startup, so the instance must be stopped
+// now and started afterwards with appendonly disabled.
+func (r *RedisBackup) RestoreBackup(config map[string]string) error {
+	r.Logger.Info("Starting Redis restore...")
+
+	if config["data-dir"] == "" {
+		return errors.New("missing required parameter: data-dir")
+	}
+	if config["tables"] != "" || config["exclude-tables"] != "" {
+		return errors.New("selective restore is not supported for Redis")
+	}
+
+	backupFilePath := config["backup-file"]
+	if backupFilePath == "" {
+		backupFilePath = DefaultBackupFile(config)
+	}
+	if err := checkRDB(backupFilePath); err != nil {
+		r.Logger.Error("Redis restore failed: " + err.Error())
+		return err
+	}
+
+	if err := r.checkStopped(config); err != nil {
+		r.Logger.Error("Redis restore failed: " + err.Error())
+		return err
+	}
+
+	target := stagedPath(config)
+	staged := target + ".restore"
+	defer os.Remove(staged)
+	if err := copyFile(backupFilePath, staged); err != nil {
+		r.Logger.Error("Failed to stage RDB file: " + err.Error())
+		return err
+	}
+	if err := os.Rename(staged, target); err != nil {
+		r.Logger.Error("Redis restore failed: " + err.Error())
+		return err
+	}
+
+	r.Logger.Info("RDB file staged at " + target + ". Start the instance with appendonly disabled to load it.")
+	return nil
+}
+
+// HasData reports whether a dump file is already present where the restore
+// would stage one.
+func (r *RedisBackup) HasData(config map[string]string) (bool, error) {
+	if config["data-dir"] == "" {
+		return false, nil
+	}
+	info, err := os.Stat(stagedPath(config))
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return info.Size() > 0, nil
+}
+
+// bgsave triggers BGSAVE, waits until LASTSAVE reports a newer save and
+// copies the server's RDB file to dst.
+func (r *RedisBackup) bgsave(config map[string]string, dst string) error {
+	before, err := r.cli(config, "LASTSAVE")
+	if err != nil {
+		return err
+	}
+	if _, err := r.cli(config, "BGSAVE"); err != nil {
+		return err
+	}
+
+	r.Logger.Info("Waiting for BGSAVE to complete...")
+	deadline := time.Now().Add(bgsaveTimeout)
+	for {
+		time.Sleep(time.Second)
+		after, err := r.cli(config, "LASTSAVE")
+		if err != nil {
+			return err
+		}
+		if strings.TrimSpace(after) != strings.TrimSpace(before) {
+			break
+		}
+		if time.Now().After(deadline) {
+			return errors.New("timed out waiting for BGSAVE")
+		}
+	}
+
+	dir, err := r.configValue(config, "dir")
+	if err != nil {
+		return err
+	}
+	filename, err := r.configValue(config, "dbfilename")
+	if err != nil {
+		return err
+	}
+	return copyFile(filepath.Join(dir, filename), dst)
+}
+
+func (r *RedisBackup) configValue(config map[string]string, name string) (string, error) {
+	output, err := r.cli(config, "CONFIG", "GET", name)
+	if err != nil {
+		return "", err
+	}
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	if len(lines) != 2 {
+		return "", errors.New("unexpected CONFIG GET " + name + " reply: " + output)
+	}
+	return strings.TrimSpace(lines[1]), nil
+}
+
+// cli runs redis-cli with the connection options from config. The password
+// goes through REDISCLI_AUTH so it does not appear in the process list.
+func (r *RedisBackup) cli(config map[string]string, command ...string) (string, error) {
+	args := []string{"-h", config["host"], "-p", config["port"]}
+	if config["username"] != "" {
+		args = append(args, "--user", config["username"])
+	}
+	args = append(args, tlsArgs(config)...)
+	args = append(args, command...)
+
+	cmd := priority.Command(config, "redis-cli", args...)
+	cmd.Env = os.Environ()
+	if config["password"] != "" {
+		cmd.Env = append(cmd.Env, "REDISCLI_AUTH="+config["password"])
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	r.Logger.Debug("Executing redis-cli command with arguments: " + strings.Join(cmd.Args, " "))
+	if err := cmd.Run(); err != nil {
+		return "", errors.New(err.Error() + ". Details: " + stderr.String() + stdout.String())
+	}
+	// redis-cli exits 0 on server errors and prints them to stdout.
+	for _, prefix := range replyErrors {
+		if strings.HasPrefix(stdout.String(), prefix) {
+			return "", errors.New(strings.TrimSpace(stdout.String()))
+		}
+	}
+	return stdout.String(), nil
+}
+
+// replyErrors are the error replies redis-cli prints without failing.
+var replyErrors = []string{"ERR", "NOAUTH", "WRONGPASS", "NOPERM"}
+
+// checkStopped makes sure nothing listens at the instance's address, since
+// a running server would overwrite a staged RDB file when it shuts down.
+// Only a refused connection counts as stopped; any other failure, such as
+// a missing redis-cli, bad credentials or a timeout, leaves it unknown.
+func (r *RedisBackup) checkStopped(config map[string]string) error {
+	address := config["host"] + ":" + config["port"]
+	_, err := r.cli(config, "PING")
+	switch {
+	case err == nil:
+		return errors.New("redis instance at " + address + " is running; stop it before restoring")
+	case strings.Contains(err.Error(), "Connection refused"):
+		return nil
+	default:
+		return errors.New("cannot tell whether redis instance at " + address + " is stopped: " + err.Error())
+	}
+}
+
+func tlsArgs(config map[string]string) []string {
+	mode := config["tls-mode"]
+	if mode == "" || mode == "disable" {
+		if config["tls-skip-verify"] != "true" {
+			return nil
+		}
+	}
+	args := []string{"--tls"}
+	if config["tls-ca"] != "" {
+		args = append(args, "--cacert", config["tls-ca"])
+	}
+	if config["tls-cert"] != "" {
+		args = append(args, "--cert", config["tls-cert"], "--key", config["tls-key"])
+	}
+	if config["tls-server-name"] != "" {
+		args = append(args, "--sni", config["tls-server-name"])
+	}
+	if mode == "require" || config["tls-skip-verify"] == "true" {
+		args = append(args, "--insecure")
+	}
+	return args
+}
+
+// checkRDB verifies that path starts with the RDB magic string.
+func checkRDB(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	magic := make([]byte, 5)
+	if _, err := io.ReadFull(file, magic); err != nil || string(magic) != "REDIS" {
+		return errors.New("not an RDB file: " + path)
+	}
+	return nil
+}
+
+func stagedPath(config map[string]string) string {
+	filename := config["rdb-filename"]
+	if filename == "" {
+		filename = "dump.rdb"
+	}
+	return filepath.Join(config["data-dir"], filename)
+}
+
+// DefaultBackupFile returns the artifact path used when no backup-file is
+// given: <backup-dir>/<dbname>.rdb, or backups/redis/<dbname>.rdb.
+func DefaultBackupFile(config map[string]string) string {
+	backupDir := config["backup-dir"]
+	if backupDir == "" {
+		backupDir = filepath.Join("backups", "redis")
+	}
+	return filepath.Join(backupDir, config["dbname"]+".rdb")
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	file, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(file, in); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
@@ -0,0 +1,146 @@
+package redis
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/itocode21/backup-tool/pkg/config"
+	"github.com/itocode21/backup-tool/pkg/logging"
+)
+
+func TestRestoreStagesRDB(t *testing.T) {
+	dir := t.TempDir()
+	backupFile := filepath.Join(dir, "cache.rdb")
+	if err := os.WriteFile(backupFile, []byte("REDIS0011snapshot"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	dataDir := filepath.Join(dir, "data")
+	if err := os.Mkdir(dataDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	logger, err := logging.NewLogger(&config.Config{})
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+	logger.SetOutput(&bytes.Buffer{})
+	engine := &RedisBackup{Logger: logger}
+	fakeCLI(t, "echo 'Could not connect to Redis at 127.0.0.1:1: Connection refused' >&2; exit 1")
+	params := map[string]string{
+		"host":        "127.0.0.1",
+		"port":        "1",
+		"backup-file": backupFile,
+		"data-dir":    dataDir,
+	}
+
+	if hasData, err := engine.HasData(params); err != nil || hasData {
+		t.Fatalf("Expected empty data dir, got %v, %v", hasData, err)
+	}
+	if err := engine.RestoreBackup(params); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dataDir, "dump.rdb"))
+	if err != nil || string(data) != "REDIS0011snapshot" {
+		t.Fatalf("Expected staged dump.rdb, got %q, %v", data, err)
+	}
+	if hasData, err := engine.HasData(params); err != nil || !hasData {
+		t.Errorf("Expected staged dump to count as data, got %v, %v", hasData, err)
+	}
+}
+
+// fakeCLI подменяет redis-cli в PATH скриптом с телом body
+func fakeCLI(t *testing.T, body string) {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "redis-cli"), []byte("#!/bin/sh\n"+body+"\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+func TestRestoreNeedsStoppedInstance(t *testing.T) {
+	dir := t.TempDir()
+	backupFile := filepath.Join(dir, "cache.rdb")
+	if err := os.WriteFile(backupFile, []byte("REDIS0011snapshot"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	logger, err := logging.NewLogger(&config.Config{})
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+	logger.SetOutput(&bytes.Buffer{})
+	engine := &RedisBackup{Logger: logger}
+	params := map[string]string{
+		"host":        "127.0.0.1",
+		"port":        "6379",
+		"backup-file": backupFile,
+		"data-dir":    dir,
+	}
+
+	// Запущенный инстанс, неверный пароль и таймаут — не повод подкладывать RDB
+	for name, body := range map[string]string{
+		"running":   "echo PONG",
+		"wrongpass": "echo 'WRONGPASS invalid username-password pair or user is disabled.'",
+		"noperm":    "echo \"NOPERM this user has no permissions to run the 'ping' command\"",
+		"timeout":   "echo 'Could not connect to Redis at 127.0.0.1:6379: Connection timed out' >&2; exit 1",
+	} {
+		fakeCLI(t, body)
+		if err := engine.RestoreBackup(params); err == nil {
+			t.Errorf("%s: expected restore to refuse", name)
+		}
+		if _, err := os.Stat(filepath.Join(dir, "dump.rdb")); !os.IsNotExist(err) {
+			t.Errorf("%s: expected no staged dump.rdb, got %v", name, err)
+		}
+	}
+}
+
+func TestRestoreRejectsNonRDB(t *testing.T) {
+	dir := t.TempDir()
+	backupFile := filepath.Join(dir, "cache.rdb")
+	if err := os.WriteFile(backupFile, []byte("-- not a snapshot"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	logger, err := logging.NewLogger(&config.Config{})
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+	logger.SetOutput(&bytes.Buffer{})
+	engine := &RedisBackup{Logger: logger}
+	params := map[string]string{
+		"port":        "1",
+		"backup-file": backupFile,
+		"data-dir":    dir,
+	}
+
+	if err := engine.RestoreBackup(params); err == nil {
+		t.Error("Expected restore of a non-RDB file to fail")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "dump.rdb")); !os.IsNotExist(err) {
+		t.Error("Expected nothing to be staged")
+	}
+}
+
+func TestTLSArgs(t *testing.T) {
+	tests := []struct {
+		config map[string]string
+		want   []string
+	}{
+		{map[string]string{}, nil},
+		{map[string]string{"tls-mode": "require"}, []string{"--tls", "--insecure"}},
+		{
+			map[string]string{"tls-mode": "verify-full", "tls-ca": "ca.pem", "tls-server-name": "cache.internal"},
+			[]string{"--tls", "--cacert", "ca.pem", "--sni", "cache.internal"},
+		},
+	}
+
+	for _, tt := range tests {
+		if got := tlsArgs(tt.config); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("tlsArgs(%v) = %v, want %v", tt.config, got, tt.want)
+		}
+	}
+}
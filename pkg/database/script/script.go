@@ -0,0 +1,21 @@
+package script
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+)
+
+// CountCreateTables counts the CREATE TABLE statements of a SQL dump
+// script, as written by mysqldump, mysqlpump or pg_dump in plain format.
+func CountCreateTables(r io.Reader) (int, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024*1024)
+	count := 0
+	for scanner.Scan() {
+		if bytes.HasPrefix(scanner.Bytes(), []byte("CREATE TABLE ")) {
+			count++
+		}
+	}
+	return count, scanner.Err()
+}
@@ -0,0 +1,21 @@
+package script
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCountCreateTables(t *testing.T) {
+	dump := strings.Join([]string{
+		"-- Table structure for table `orders`",
+		"CREATE TABLE `orders` (",
+		"  `id` int NOT NULL",
+		") ENGINE=InnoDB;",
+		"INSERT INTO `orders` VALUES (1,'CREATE TABLE x');",
+		"CREATE TABLE public.items (id integer);",
+	}, "\n")
+	count, err := CountCreateTables(strings.NewReader(dump))
+	if err != nil || count != 2 {
+		t.Errorf("Expected 2 tables, got %d, %v", count, err)
+	}
+}
@@ -0,0 +1,210 @@
+package sqlite
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/itocode21/backup-tool/pkg/database/partial"
+	"github.com/itocode21/backup-tool/pkg/database/priority"
+	"github.com/itocode21/backup-tool/pkg/logging"
+)
+
+type SQLiteBackup struct {
+	Logger *logging.Logger
+}
+
+// PerformFullBackup copies a live database with the sqlite3 online backup
+// API (.backup), which takes a consistent snapshot while other connections
+// keep writing, and checks the copy before it is published.
+func (s *SQLiteBackup) PerformFullBackup(config map[string]string) error {
+	s.Logger.Info("Starting full SQLite backup...")
+
+	requiredParams := []string{"path", "dbname"}
+	for _, param := range requiredParams {
+		if config[param] == "" {
+			return errors.New("missing required parameter: " + param)
+		}
+	}
+
+	backupFilePath := config["backup-file"]
+	if backupFilePath == "" {
+		backupFilePath = DefaultBackupFile(config)
+	}
+
+	backupDir := filepath.Dir(backupFilePath)
+	err := os.MkdirAll(backupDir, os.ModePerm)
+	if err != nil {
+		s.Logger.Error("Failed to create backup directory: " + err.Error())
+		return err
+	}
+
+	tempFile := partial.Path(backupFilePath)
+	defer os.Remove(tempFile)
+
+	if _, err := s.run(config, config["path"], ".backup "+quote(tempFile)); err != nil {
+		s.Logger.Error("SQLite backup failed: " + err.Error())
+		return err
+	}
+	if err := s.checkIntegrity(config, tempFile); err != nil {
+		return err
+	}
+	if err := partial.Commit(tempFile, backupFilePath); err != nil {
+		s.Logger.Error("Failed to move backup into place: " + err.Error())
+		return err
+	}
+
+	s.Logger.Info("SQLite backup completed successfully. File saved to: " + backupFilePath)
+	return nil
+}
+
+// RestoreBackup stages a checked copy of the backup next to the database
+// and renames it over the database file, so readers see either the old or
+// the new database. Writers should be stopped first: their WAL and shared
+// memory files are removed because they belong to the old database.
+func (s *SQLiteBackup) RestoreBackup(config map[string]string) error {
+	s.Logger.Info("Starting SQLite restore...")
+
+	requiredParams := []string{"path", "dbname"}
+	for _, param := range requiredParams {
+		if config[param] == "" {
+			return errors.New("missing required parameter: " + param)
+		}
+	}
+	if config["tables"] != "" || config["exclude-tables"] != "" {
+		return errors.New("selective restore is not supported for SQLite")
+	}
+
+	backupFilePath := config["backup-file"]
+	if backupFilePath == "" {
+		backupFilePath = DefaultBackupFile(config)
+	}
+	if err := s.checkIntegrity(config, backupFilePath); err != nil {
+		return err
+	}
+
+	dbPath := config["path"]
+	if err := os.MkdirAll(filepath.Dir(dbPath), os.ModePerm); err != nil {
+		s.Logger.Error("Failed to create database directory: " + err.Error())
+		return err
+	}
+
+	staged := dbPath + ".restore"
+	defer os.Remove(staged)
+	if err := copyFile(backupFilePath, staged); err != nil {
+		s.Logger.Error("Failed to stage backup: " + err.Error())
+		return err
+	}
+
+	for _, suffix := range []string{"-wal", "-shm"} {
+		if err := os.Remove(dbPath + suffix); err == nil {
+			s.Logger.Warn("Removed stale " + dbPath + suffix)
+		}
+	}
+	if err := os.Rename(staged, dbPath); err != nil {
+		s.Logger.Error("SQLite restore failed: " + err.Error())
+		return err
+	}
+
+	s.Logger.Info("SQLite restore completed successfully.")
+	return nil
+}
+
+// HasData reports whether the database file exists and defines any tables.
+func (s *SQLiteBackup) HasData(config map[string]string) (bool, error) {
+	if _, err := os.Stat(config["path"]); os.IsNotExist(err) {
+		return false, nil
+	}
+	output, err := s.run(config, config["path"], "SELECT count(*) FROM sqlite_master")
+	if err != nil {
+		return false, err
+	}
+	return strings.TrimSpace(output) != "0", nil
+}
+
+// CountTables returns how many tables the backup file defines.
+func (s *SQLiteBackup) CountTables(config map[string]string) (int, error) {
+	output, err := s.run(config, config["backup-file"], "SELECT count(*) FROM sqlite_master WHERE type = 'table'")
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(strings.TrimSpace(output))
+}
+
+// Exec runs a statement against the database file.
+func (s *SQLiteBackup) Exec(ctx context.Context, config map[string]string, statement string) error {
+	_, err := s.runContext(ctx, config, config["path"], statement)
+	return err
+}
+
+func (s *SQLiteBackup) checkIntegrity(config map[string]string, path string) error {
+	output, err := s.run(config, path, "PRAGMA integrity_check")
+	if err != nil {
+		s.Logger.Error("SQLite integrity check failed: " + err.Error())
+		return err
+	}
+	if result := strings.TrimSpace(output); result != "ok" {
+		s.Logger.Error("SQLite integrity check failed for " + path + ": " + result)
+		return errors.New("integrity check failed: " + result)
+	}
+	return nil
+}
+
+func (s *SQLiteBackup) run(config map[string]string, path, command string) (string, error) {
+	return s.runContext(context.Background(), config, path, command)
+}
+
+// runContext is run with sqlite3 killed when ctx is done.
+func (s *SQLiteBackup) runContext(ctx context.Context, config map[string]string, path, command string) (string, error) {
+	cmd := priority.CommandContext(ctx, config, "sqlite3", "-bail", path, command)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	s.Logger.Debug("Executing sqlite3 command with arguments: " + strings.Join(cmd.Args, " "))
+	if err := cmd.Run(); err != nil {
+		return "", errors.New(err.Error() + ". Details: " + stderr.String())
+	}
+	return stdout.String(), nil
+}
+
+// DefaultBackupFile returns the artifact path used when no backup-file is
+// given: <backup-dir>/<dbname>.sqlite, or backups/sqlite/<dbname>.sqlite.
+func DefaultBackupFile(config map[string]string) string {
+	backupDir := config["backup-dir"]
+	if backupDir == "" {
+		backupDir = filepath.Join("backups", "sqlite")
+	}
+	return filepath.Join(backupDir, config["dbname"]+".sqlite")
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	file, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(file, in); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+func quote(path string) string {
+	return "'" + strings.ReplaceAll(path, "'", "''") + "'"
+}
@@ -0,0 +1,59 @@
+package sqlite
+
+import (
+	"bytes"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/itocode21/backup-tool/pkg/config"
+	"github.com/itocode21/backup-tool/pkg/logging"
+)
+
+func TestBackupAndRestore(t *testing.T) {
+	if _, err := exec.LookPath("sqlite3"); err != nil {
+		t.Skip("sqlite3 not installed")
+	}
+
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "app.db")
+	if out, err := exec.Command("sqlite3", dbPath, "CREATE TABLE t (id INTEGER); INSERT INTO t VALUES (1), (2);").CombinedOutput(); err != nil {
+		t.Fatalf("Failed to create database: %v: %s", err, out)
+	}
+
+	logger, err := logging.NewLogger(&config.Config{})
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+	logger.SetOutput(&bytes.Buffer{})
+	engine := &SQLiteBackup{Logger: logger}
+	params := map[string]string{
+		"path":       dbPath,
+		"dbname":     "app",
+		"backup-dir": filepath.Join(dir, "backups"),
+	}
+
+	if err := engine.PerformFullBackup(params); err != nil {
+		t.Fatalf("Backup failed: %v", err)
+	}
+
+	// Меняем базу после бэкапа и проверяем, что восстановление вернуло исходные данные
+	exec.Command("sqlite3", dbPath, "DELETE FROM t;").Run()
+	if err := engine.RestoreBackup(params); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+
+	out, err := exec.Command("sqlite3", dbPath, "SELECT count(*) FROM t;").Output()
+	if err != nil {
+		t.Fatalf("Failed to query restored database: %v", err)
+	}
+	if strings.TrimSpace(string(out)) != "2" {
+		t.Errorf("Expected 2 rows after restore, got %s", out)
+	}
+
+	hasData, err := engine.HasData(params)
+	if err != nil || !hasData {
+		t.Errorf("Expected restored database to have data, got %v, %v", hasData, err)
+	}
+}
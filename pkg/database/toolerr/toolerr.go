@@ -0,0 +1,28 @@
+package toolerr
+
+import "errors"
+
+// Error is returned by engines when a client tool fails. It keeps what the
+// tool printed to stderr so the job runner can record it; the message is
+// that of the underlying error.
+type Error struct {
+	Err    error
+	Stderr string
+}
+
+func (e *Error) Error() string { return e.Err.Error() }
+func (e *Error) Unwrap() error { return e.Err }
+
+// Wrap attaches the stderr of a failed tool to err.
+func Wrap(err error, stderr string) error {
+	return &Error{Err: err, Stderr: stderr}
+}
+
+// Stderr returns the stderr attached to err, if any.
+func Stderr(err error) string {
+	var toolErr *Error
+	if errors.As(err, &toolErr) {
+		return toolErr.Stderr
+	}
+	return ""
+}
@@ -0,0 +1,181 @@
+package history
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// Run statuses.
+const (
+	StatusSuccess = "success"
+	StatusFailure = "failure"
+)
+
+// MaxStderr bounds the stderr excerpt kept per run; the end of the output
+// is kept since tools print the actual failure last.
+const MaxStderr = 2048
+
+// Record is one finished backup, restore or prune run. Host is the machine
+// that ran it, DBHost the database server.
+type Record struct {
+	ID         string    `json:"id"`
+	Command    string    `json:"command"`
+	Target     string    `json:"target"`
+	Kind       string    `json:"kind,omitempty"`
+	Engine     string    `json:"engine,omitempty"`
+	Host       string    `json:"host,omitempty"`
+	DBHost     string    `json:"db_host,omitempty"`
+	Status     string    `json:"status"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+	Artifact   string    `json:"artifact,omitempty"`
+	Repository string    `json:"repository,omitempty"`
+	Bytes      int64     `json:"bytes,omitempty"`
+	Tables     *int      `json:"tables,omitempty"`
+	Suspicious []string  `json:"suspicious,omitempty"`
+	Removed    int       `json:"removed,omitempty"`
+	Phase      string    `json:"phase,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	Stderr     string    `json:"stderr,omitempty"`
+}
+
+// Store appends records to a JSON lines file. Each record is written with a
+// single append, so runs of several processes can share the file.
+type Store struct {
+	mu   sync.Mutex
+	path string
+}
+
+func Open(path string) *Store {
+	return &Store{path: path}
+}
+
+// Append adds records to the end of the history.
+func (s *Store) Append(records ...Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(s.path), os.ModePerm); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(s.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	for _, r := range records {
+		if len(r.Stderr) > MaxStderr {
+			r.Stderr = r.Stderr[len(r.Stderr)-MaxStderr:]
+		}
+		data, err := json.Marshal(r)
+		if err != nil {
+			file.Close()
+			return err
+		}
+		if _, err := file.Write(append(data, '\n')); err != nil {
+			file.Close()
+			return err
+		}
+	}
+	return file.Close()
+}
+
+// Filter selects records. Target and Command are path.Match patterns, so
+// "orders-*" or "orders/*" work; empty fields match everything. Limit keeps
+// only the newest records.
+type Filter struct {
+	Target  string
+	Command string
+	Status  string
+	Since   time.Time
+	Limit   int
+}
+
+func (f Filter) match(r Record) bool {
+	if f.Target != "" {
+		if ok, _ := path.Match(f.Target, r.Target); !ok {
+			return false
+		}
+	}
+	if f.Command != "" {
+		if ok, _ := path.Match(f.Command, r.Command); !ok {
+			return false
+		}
+	}
+	if f.Status != "" && r.Status != f.Status {
+		return false
+	}
+	return f.Since.IsZero() || !r.StartedAt.Before(f.Since)
+}
+
+// Query returns the matching records, oldest first. A missing history file
+// is an empty history; lines that cannot be parsed, e.g. a record cut short
+// by a crash, are skipped.
+func (s *Store) Query(f Filter) ([]Record, error) {
+	if f.Target != "" {
+		if _, err := path.Match(f.Target, ""); err != nil {
+			return nil, fmt.Errorf("invalid target pattern %q: %w", f.Target, err)
+		}
+	}
+
+	file, err := os.Open(s.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var records []Record
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var r Record
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			continue
+		}
+		if f.match(r) {
+			records = append(records, r)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if f.Limit > 0 && len(records) > f.Limit {
+		records = records[len(records)-f.Limit:]
+	}
+	return records, nil
+}
+
+// WriteTable prints records as an aligned table.
+func WriteTable(w io.Writer, records []Record) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "STARTED\tID\tCOMMAND\tTARGET\tSTATUS\tDURATION\tHOST\tERROR")
+	for _, r := range records {
+		errText := r.Error
+		if r.Phase != "" {
+			errText = r.Phase + ": " + errText
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", r.StartedAt.Local().Format(time.RFC3339), r.ID, r.Command, r.Target,
+			r.Status, r.FinishedAt.Sub(r.StartedAt).Round(time.Millisecond), r.Host, errText)
+	}
+	return tw.Flush()
+}
+
+// WriteJSON prints records as a JSON array.
+func WriteJSON(w io.Writer, records []Record) error {
+	if records == nil {
+		records = []Record{}
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(records)
+}
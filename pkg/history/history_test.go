@@ -0,0 +1,80 @@
+package history
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestQuery(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	store := Open(path)
+	now := time.Now().UTC()
+	records := []Record{
+		{ID: "1", Command: "backup", Target: "orders", Status: StatusSuccess, StartedAt: now.Add(-48 * time.Hour)},
+		{ID: "2", Command: "backup", Target: "orders", Status: StatusFailure, StartedAt: now.Add(-2 * time.Hour), Stderr: strings.Repeat("x", MaxStderr) + "denied"},
+		{ID: "3", Command: "prune", Target: "billing", Status: StatusSuccess, StartedAt: now.Add(-time.Hour)},
+		{ID: "4", Command: "backup", Target: "srv/app", Status: StatusSuccess, StartedAt: now},
+	}
+	if err := store.Append(records[:2]...); err != nil {
+		t.Fatalf("Append returned error: %v", err)
+	}
+	// A record cut short by a crash must not hide the ones after it.
+	file, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	file.WriteString(`{"id":"broken",` + "\n")
+	file.Close()
+	if err := store.Append(records[2:]...); err != nil {
+		t.Fatalf("Append returned error: %v", err)
+	}
+
+	tests := []struct {
+		filter Filter
+		want   string
+	}{
+		{Filter{}, "1,2,3,4"},
+		{Filter{Target: "orders"}, "1,2"},
+		{Filter{Target: "srv/*"}, "4"},
+		{Filter{Command: "prune"}, "3"},
+		{Filter{Status: StatusFailure}, "2"},
+		{Filter{Since: now.Add(-3 * time.Hour)}, "2,3,4"},
+		{Filter{Limit: 2}, "3,4"},
+	}
+	for _, tt := range tests {
+		got, err := store.Query(tt.filter)
+		if err != nil {
+			t.Fatalf("Query(%+v) returned error: %v", tt.filter, err)
+		}
+		var ids []string
+		for _, r := range got {
+			ids = append(ids, r.ID)
+		}
+		if strings.Join(ids, ",") != tt.want {
+			t.Errorf("Query(%+v): expected %s, got %s", tt.filter, tt.want, strings.Join(ids, ","))
+		}
+	}
+
+	failed, _ := store.Query(Filter{Status: StatusFailure})
+	if len(failed[0].Stderr) != MaxStderr || !strings.HasSuffix(failed[0].Stderr, "denied") {
+		t.Errorf("Expected the end of stderr to be kept, got %d bytes", len(failed[0].Stderr))
+	}
+
+	var buf bytes.Buffer
+	if err := WriteJSON(&buf, failed); err != nil {
+		t.Fatal(err)
+	}
+	var decoded []Record
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil || len(decoded) != 1 || decoded[0].ID != "2" {
+		t.Errorf("Unexpected JSON output %s: %v", buf.String(), err)
+	}
+}
+
+func TestQueryMissingFile(t *testing.T) {
+	records, err := Open(filepath.Join(t.TempDir(), "history.jsonl")).Query(Filter{})
+	if err != nil || len(records) != 0 {
+		t.Errorf("Expected an empty history, got %v, %v", records, err)
+	}
+}
@@ -0,0 +1,46 @@
+package lock
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// File locks with a file per name in Dir, for jobs on a single host. The
+// lock is tied to the open file, so a crashed process does not leave it
+// behind on Unix.
+type File struct {
+	Dir string
+}
+
+func (f File) Acquire(name string) (Lock, error) {
+	if err := os.MkdirAll(f.Dir, os.ModePerm); err != nil {
+		return nil, err
+	}
+	path := filepath.Join(f.Dir, url.PathEscape(name)+".lock")
+	file, err := lockFile(path)
+	if err == errBusy {
+		holder, _ := os.ReadFile(path)
+		return nil, fmt.Errorf("%w: %s is held by %s", ErrLocked, name, strings.TrimSpace(string(holder)))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// The holder is informational only, so failing to record it is fine.
+	if err := file.Truncate(0); err == nil {
+		file.WriteAt([]byte(Owner()+" since "+time.Now().Format(time.RFC3339)+"\n"), 0)
+	}
+	return &fileLock{file: file}, nil
+}
+
+type fileLock struct {
+	file *os.File
+}
+
+func (l *fileLock) Release() error {
+	return unlockFile(l.file)
+}
@@ -0,0 +1,25 @@
+//go:build !unix
+
+package lock
+
+import (
+	"errors"
+	"os"
+)
+
+var errBusy = errors.New("lock file is busy")
+
+// lockFile creates path exclusively. Without flock a crashed process
+// leaves the file behind and it has to be removed by hand.
+func lockFile(path string) (*os.File, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_RDWR, 0644)
+	if os.IsExist(err) {
+		return nil, errBusy
+	}
+	return file, err
+}
+
+func unlockFile(file *os.File) error {
+	file.Close()
+	return os.Remove(file.Name())
+}
@@ -0,0 +1,35 @@
+//go:build unix
+
+package lock
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+var errBusy = errors.New("lock file is busy")
+
+// lockFile opens path and takes an exclusive flock on it, which the kernel
+// releases when the process exits.
+func lockFile(path string) (*os.File, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		file.Close()
+		if err == syscall.EWOULDBLOCK {
+			return nil, errBusy
+		}
+		return nil, err
+	}
+	return file, nil
+}
+
+// unlockFile releases the lock. The file stays, removing it would race
+// with a process that just opened it.
+func unlockFile(file *os.File) error {
+	file.Truncate(0)
+	return file.Close()
+}
@@ -0,0 +1,228 @@
+package lock
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/itocode21/backup-tool/pkg/storage"
+)
+
+// DefaultTTL is how long a lease lasts without renewal when no TTL is set.
+const DefaultTTL = 5 * time.Minute
+
+// Lease locks with lease objects in a storage shared by several hosts. A
+// lease expires after TTL unless its holder renews it, which it does every
+// third of the TTL, so a crashed host blocks the lock for at most TTL.
+//
+// The leases of a name are numbered; the highest number is the current
+// one. Taking the lock means creating the next number with a conditional
+// create, so of two hosts that find the lock free only one succeeds.
+// Released leases stay behind as tombstones, so numbers are never reused
+// and a holder can always tell that its lease was taken over.
+type Lease struct {
+	Storage storage.Storage
+	TTL     time.Duration
+}
+
+// NewLease returns a lease locker on s, which must be a storage.Creator.
+func NewLease(s storage.Storage, ttl time.Duration) (*Lease, error) {
+	if _, ok := s.(storage.Creator); !ok {
+		return nil, errors.New("storage does not support conditional creates")
+	}
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+	return &Lease{Storage: s, TTL: ttl}, nil
+}
+
+type leaseRecord struct {
+	Owner string `json:"owner"`
+	// Token tells the holder's own lease apart from one of another job of
+	// the same process.
+	Token    string    `json:"token,omitempty"`
+	Expires  time.Time `json:"expires"`
+	Released bool      `json:"released,omitempty"`
+}
+
+// errLost marks a lease that is no longer the current one of its holder.
+var errLost = errors.New("lease lost")
+
+func (l *Lease) Acquire(name string) (Lock, error) {
+	prefix := url.PathEscape(name) + "/"
+	keys, err := l.Storage.List(prefix)
+	if err != nil {
+		return nil, err
+	}
+	latest, number := latestLease(prefix, keys)
+	if latest != "" {
+		// A lease that vanished meanwhile was taken over and released.
+		if record, err := l.read(latest); err == nil && !record.Released && time.Now().Before(record.Expires) {
+			return nil, fmt.Errorf("%w: %s is held by %s until %s", ErrLocked, name, record.Owner, record.Expires.Format(time.RFC3339))
+		}
+	}
+
+	held := &lease{locker: l, name: name, prefix: prefix, number: number + 1, token: newToken(), done: make(chan struct{})}
+	held.key = fmt.Sprintf("%s%012d", prefix, held.number)
+	data, expires, err := l.record(held.token, false)
+	if err != nil {
+		return nil, err
+	}
+	err = l.Storage.(storage.Creator).Create(held.key, bytes.NewReader(data))
+	if errors.Is(err, storage.ErrExist) {
+		return nil, fmt.Errorf("%w: %s was just taken by another job", ErrLocked, name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	// The new lease outnumbers these, so numbers are never reused.
+	for _, old := range keys {
+		l.Storage.Delete(old)
+	}
+
+	held.expires = expires
+	held.wg.Add(1)
+	go held.renew()
+	return held, nil
+}
+
+// latestLease returns the highest numbered lease among keys.
+func latestLease(prefix string, keys []string) (string, int) {
+	latest, number := "", 0
+	for _, key := range keys {
+		if n, err := strconv.Atoi(strings.TrimPrefix(key, prefix)); err == nil && n >= number {
+			latest, number = key, n
+		}
+	}
+	return latest, number
+}
+
+func (l *Lease) read(key string) (leaseRecord, error) {
+	var record leaseRecord
+	r, err := l.Storage.Get(key)
+	if err != nil {
+		return record, err
+	}
+	defer r.Close()
+	return record, json.NewDecoder(r).Decode(&record)
+}
+
+func (l *Lease) record(token string, released bool) ([]byte, time.Time, error) {
+	expires := time.Now().Add(l.TTL)
+	if released {
+		expires = time.Now()
+	}
+	data, err := json.Marshal(leaseRecord{Owner: Owner(), Token: token, Expires: expires, Released: released})
+	return data, expires, err
+}
+
+func newToken() string {
+	var b [8]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+type lease struct {
+	locker  *Lease
+	key     string
+	name    string
+	prefix  string
+	number  int
+	token   string
+	expires time.Time
+	done    chan struct{}
+	wg      sync.WaitGroup
+
+	mu   sync.Mutex
+	lost bool
+}
+
+// renew extends the lease until it is released. A lease that another job
+// has taken over is not renewed again, and one that could not be renewed
+// before it expired counts as lost, since another job may take it then.
+func (h *lease) renew() {
+	defer h.wg.Done()
+	ticker := time.NewTicker(h.locker.TTL / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-h.done:
+			return
+		case <-ticker.C:
+		}
+		err := h.check()
+		if err == nil {
+			var data []byte
+			var expires time.Time
+			if data, expires, err = h.locker.record(h.token, false); err == nil {
+				if err = h.locker.Storage.Put(h.key, bytes.NewReader(data)); err == nil {
+					h.expires = expires
+				}
+			}
+		}
+		if errors.Is(err, errLost) || (err != nil && time.Now().After(h.expires)) {
+			h.mu.Lock()
+			h.lost = true
+			h.mu.Unlock()
+			return
+		}
+	}
+}
+
+// check verifies that the lease is still the newest one of its name and
+// holds this holder's token.
+func (h *lease) check() error {
+	keys, err := h.locker.Storage.List(h.prefix)
+	if err != nil {
+		return err
+	}
+	if _, number := latestLease(h.prefix, keys); number > h.number {
+		return errLost
+	}
+	record, err := h.locker.read(h.key)
+	if errors.Is(err, storage.ErrNotExist) {
+		return errLost
+	}
+	if err != nil {
+		return err
+	}
+	if record.Token != h.token || record.Released {
+		return errLost
+	}
+	return nil
+}
+
+// Release marks the lease released. The object stays as a tombstone so
+// that the next lease gets a higher number. It reports a lease that
+// expired and was taken over while held, since the job then ran
+// unprotected, and leaves the new holder's lease alone.
+func (h *lease) Release() error {
+	close(h.done)
+	h.wg.Wait()
+	h.mu.Lock()
+	lost := h.lost
+	h.mu.Unlock()
+	if !lost {
+		if err := h.check(); errors.Is(err, errLost) {
+			lost = true
+		} else if err != nil {
+			return err
+		}
+	}
+	if lost {
+		return fmt.Errorf("lease on %s expired and was taken over while held", h.name)
+	}
+	data, _, err := h.locker.record(h.token, true)
+	if err != nil {
+		return err
+	}
+	return h.locker.Storage.Put(h.key, bytes.NewReader(data))
+}
@@ -0,0 +1,68 @@
+package lock
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// ErrLocked is returned when another job holds a lock.
+var ErrLocked = errors.New("locked by another job")
+
+// Lock is a held lock.
+type Lock interface {
+	Release() error
+}
+
+// Locker hands out exclusive locks by name, e.g. one per target.
+// Acquire fails with an error wrapping ErrLocked while another process
+// holds the lock.
+type Locker interface {
+	Acquire(name string) (Lock, error)
+}
+
+// Owner describes this process to other lock holders.
+func Owner() string {
+	host, _ := os.Hostname()
+	return fmt.Sprintf("%s (pid %d)", host, os.Getpid())
+}
+
+// None is a locker that never excludes anyone.
+type None struct{}
+
+func (None) Acquire(name string) (Lock, error) {
+	return noLock{}, nil
+}
+
+type noLock struct{}
+
+func (noLock) Release() error { return nil }
+
+// retryInterval is how often a waiting locker tries again.
+const retryInterval = time.Second
+
+// WithWait returns a locker that keeps trying a busy lock for up to wait
+// before giving up.
+func WithWait(l Locker, wait time.Duration) Locker {
+	if wait <= 0 {
+		return l
+	}
+	return waiting{Locker: l, wait: wait}
+}
+
+type waiting struct {
+	Locker
+	wait time.Duration
+}
+
+func (w waiting) Acquire(name string) (Lock, error) {
+	deadline := time.Now().Add(w.wait)
+	for {
+		held, err := w.Locker.Acquire(name)
+		if !errors.Is(err, ErrLocked) || time.Now().Add(retryInterval).After(deadline) {
+			return held, err
+		}
+		time.Sleep(retryInterval)
+	}
+}
@@ -0,0 +1,194 @@
+package lock
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/itocode21/backup-tool/pkg/storage"
+)
+
+func TestFileLock(t *testing.T) {
+	locker := File{Dir: t.TempDir()}
+
+	held, err := locker.Acquire("orders/main")
+	if err != nil {
+		t.Fatalf("Acquire failed: %v", err)
+	}
+	_, err = locker.Acquire("orders/main")
+	if !errors.Is(err, ErrLocked) || !strings.Contains(err.Error(), Owner()) {
+		t.Errorf("Expected the lock to be busy with its holder named, got %v", err)
+	}
+	if other, err := locker.Acquire("billing"); err != nil {
+		t.Errorf("Expected other names to be free, got %v", err)
+	} else {
+		other.Release()
+	}
+
+	if err := held.Release(); err != nil {
+		t.Fatalf("Release failed: %v", err)
+	}
+	again, err := locker.Acquire("orders/main")
+	if err != nil {
+		t.Fatalf("Expected the released lock to be free, got %v", err)
+	}
+	again.Release()
+}
+
+func TestLease(t *testing.T) {
+	store := storage.NewLocal(t.TempDir())
+	locker, err := NewLease(store, time.Minute)
+	if err != nil {
+		t.Fatalf("NewLease failed: %v", err)
+	}
+
+	held, err := locker.Acquire("orders")
+	if err != nil {
+		t.Fatalf("Acquire failed: %v", err)
+	}
+	if _, err := locker.Acquire("orders"); !errors.Is(err, ErrLocked) {
+		t.Errorf("Expected the lease to be busy, got %v", err)
+	}
+	if err := held.Release(); err != nil {
+		t.Fatalf("Release failed: %v", err)
+	}
+	held, err = locker.Acquire("orders")
+	if err != nil {
+		t.Fatalf("Expected the released lease to be free, got %v", err)
+	}
+	held.Release()
+}
+
+func TestLeaseTakeover(t *testing.T) {
+	store := storage.NewLocal(t.TempDir())
+	locker, _ := NewLease(store, time.Minute)
+
+	// Хост упал, не отпустив аренду: она истекла, но объект остался
+	data, _ := json.Marshal(leaseRecord{Owner: "crashed", Expires: time.Now().Add(-time.Second)})
+	store.Put("orders/000000000007", bytes.NewReader(data))
+
+	held, err := locker.Acquire("orders")
+	if err != nil {
+		t.Fatalf("Expected an expired lease to be taken over, got %v", err)
+	}
+	keys, _ := store.List("orders/")
+	if len(keys) != 1 || keys[0] != "orders/000000000008" {
+		t.Errorf("Expected only the new lease to remain, got %v", keys)
+	}
+
+	// Кто-то уже создал следующую аренду — условное создание не даёт захватить её второй раз
+	if err := store.Create("orders/000000000008", strings.NewReader("{}")); !errors.Is(err, storage.ErrExist) {
+		t.Errorf("Expected ErrExist for an existing key, got %v", err)
+	}
+	held.Release()
+}
+
+func TestLeaseRenewal(t *testing.T) {
+	store := storage.NewLocal(t.TempDir())
+	locker, _ := NewLease(store, 150*time.Millisecond)
+
+	held, err := locker.Acquire("orders")
+	if err != nil {
+		t.Fatalf("Acquire failed: %v", err)
+	}
+	time.Sleep(400 * time.Millisecond)
+	if _, err := locker.Acquire("orders"); !errors.Is(err, ErrLocked) {
+		t.Errorf("Expected a renewed lease to outlive its TTL, got %v", err)
+	}
+	if err := held.Release(); err != nil {
+		t.Errorf("Release failed: %v", err)
+	}
+}
+
+func TestLeaseNumbersAreNotReused(t *testing.T) {
+	store := storage.NewLocal(t.TempDir())
+	locker, _ := NewLease(store, time.Minute)
+
+	held, _ := locker.Acquire("orders")
+	if err := held.Release(); err != nil {
+		t.Fatalf("Release failed: %v", err)
+	}
+	// Отпущенная аренда остаётся надгробием, и следующая получает новый номер
+	again, err := locker.Acquire("orders")
+	if err != nil {
+		t.Fatalf("Acquire failed: %v", err)
+	}
+	defer again.Release()
+	keys, _ := store.List("orders/")
+	if len(keys) != 1 || keys[0] != "orders/000000000002" {
+		t.Errorf("Expected the next lease to get number 2, got %v", keys)
+	}
+}
+
+func TestLeaseLostToNewerHolder(t *testing.T) {
+	store := storage.NewLocal(t.TempDir())
+	locker, _ := NewLease(store, 150*time.Millisecond)
+
+	held, err := locker.Acquire("orders")
+	if err != nil {
+		t.Fatalf("Acquire failed: %v", err)
+	}
+	// Пока держатель «завис», его аренду забрал другой хост
+	data, _ := json.Marshal(leaseRecord{Owner: "other", Token: "other", Expires: time.Now().Add(time.Minute)})
+	store.Create("orders/000000000002", bytes.NewReader(data))
+	time.Sleep(200 * time.Millisecond)
+
+	if err := held.Release(); err == nil {
+		t.Error("Expected Release to report the lost lease")
+	}
+	record, err := locker.read("orders/000000000002")
+	if err != nil || record.Owner != "other" || record.Released {
+		t.Errorf("Expected the newer lease to stay untouched, got %+v, %v", record, err)
+	}
+	if _, err := locker.Acquire("orders"); !errors.Is(err, ErrLocked) {
+		t.Errorf("Expected the newer holder to keep the lock, got %v", err)
+	}
+}
+
+func TestWithWait(t *testing.T) {
+	locker := File{Dir: t.TempDir()}
+	held, _ := locker.Acquire("orders")
+	go func() {
+		time.Sleep(200 * time.Millisecond)
+		held.Release()
+	}()
+
+	waiting := WithWait(locker, 5*time.Second)
+	again, err := waiting.Acquire("orders")
+	if err != nil {
+		t.Fatalf("Expected to get the lock once released, got %v", err)
+	}
+	again.Release()
+}
+
+func TestSharedLock(t *testing.T) {
+	base := File{Dir: t.TempDir()}
+	locker := Shared(base)
+
+	// Загрузки одного процесса делят блокировку репозитория
+	first, err := locker.Acquire("repository")
+	if err != nil {
+		t.Fatalf("Acquire failed: %v", err)
+	}
+	second, err := locker.Acquire("repository")
+	if err != nil {
+		t.Fatalf("Expected a second holder to join, got %v", err)
+	}
+	// а GC, который берёт её напрямую, ждёт, пока не отпустят все
+	if _, err := base.Acquire("repository"); !errors.Is(err, ErrLocked) {
+		t.Errorf("Expected the shared lock to exclude others, got %v", err)
+	}
+	first.Release()
+	if _, err := base.Acquire("repository"); !errors.Is(err, ErrLocked) {
+		t.Errorf("Expected the lock to stay held by the second holder, got %v", err)
+	}
+	second.Release()
+	held, err := base.Acquire("repository")
+	if err != nil {
+		t.Fatalf("Expected the lock to be free after the last release, got %v", err)
+	}
+	held.Release()
+}
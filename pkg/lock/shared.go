@@ -0,0 +1,61 @@
+package lock
+
+import "sync"
+
+// Shared returns a locker that lets the jobs of this process share a lock:
+// the first Acquire of a name takes it from l, later ones join in and the
+// last Release gives it back. Other processes, and other lockers on l,
+// are still excluded while anyone here holds it.
+func Shared(l Locker) Locker {
+	return &shared{locker: l, held: make(map[string]*sharedLock)}
+}
+
+type shared struct {
+	locker Locker
+	mu     sync.Mutex
+	held   map[string]*sharedLock
+}
+
+type sharedLock struct {
+	lock    Lock
+	holders int
+}
+
+func (s *shared) Acquire(name string) (Lock, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	h := s.held[name]
+	if h == nil {
+		held, err := s.locker.Acquire(name)
+		if err != nil {
+			return nil, err
+		}
+		h = &sharedLock{lock: held}
+		s.held[name] = h
+	}
+	h.holders++
+	return &sharedHold{shared: s, name: name, lock: h}, nil
+}
+
+type sharedHold struct {
+	shared   *shared
+	name     string
+	lock     *sharedLock
+	released bool
+}
+
+func (h *sharedHold) Release() error {
+	s := h.shared
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if h.released {
+		return nil
+	}
+	h.released = true
+	h.lock.holders--
+	if h.lock.holders > 0 {
+		return nil
+	}
+	delete(s.held, h.name)
+	return h.lock.lock.Release()
+}
@@ -1,84 +1,167 @@
 package logging
 
 import (
+	"context"
+	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"os"
 	"path/filepath"
+	"runtime"
+	"strconv"
+	"sync"
+	"time"
 
 	"github.com/itocode21/backup-tool/pkg/config"
 )
 
+// LevelFatal is the level Fatal logs at before exiting.
+const LevelFatal = slog.Level(12)
+
+// Logger wraps a slog.Logger writing text or JSON records, depending on
+// logging.format. The Info/Warn/Error/Debug wrappers take a message and
+// optional key/value pairs, like slog.
 type Logger struct {
-	infoLog        *log.Logger
-	warnLog        *log.Logger
-	errorLog       *log.Logger
-	debugLog       *log.Logger
-	fatalLog       *log.Logger
-	output         io.Writer
-	isDebugEnabled bool
+	logger *slog.Logger
+	output *output
+	file   *rotatingFile
+}
+
+// levels maps logging.level to the minimum level written.
+var levels = map[string]slog.Level{
+	"":      slog.LevelInfo,
+	"debug": slog.LevelDebug,
+	"info":  slog.LevelInfo,
+	"warn":  slog.LevelWarn,
+	"error": slog.LevelError,
 }
 
-func NewLogger(cfg *config.Config) *Logger {
-	var outputs []io.Writer
+// NewLogger builds the logger described by cfg.Logging, writing to stdout
+// and, if configured, to a rotated log file.
+func NewLogger(cfg *config.Config) (*Logger, error) {
+	level, ok := levels[cfg.Logging.Level]
+	if !ok {
+		return nil, fmt.Errorf("invalid logging level: %s", cfg.Logging.Level)
+	}
 
+	logger := &Logger{}
+	outputs := []io.Writer{os.Stdout}
 	if cfg.Logging.File != "" {
-		logDir := filepath.Dir(cfg.Logging.File)
-		err := os.MkdirAll(logDir, os.ModePerm)
+		file, err := openRotatingFile(cfg.Logging.File, int64(cfg.Logging.MaxSizeMB)<<20,
+			cfg.Logging.MaxAge, cfg.Logging.MaxBackups, cfg.Logging.Compress)
 		if err != nil {
-			log.Fatalf("Ошибка создания директории для логов: %v", err)
+			return nil, fmt.Errorf("open log file: %w", err)
 		}
-		file, err := os.OpenFile(cfg.Logging.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
-		if err != nil {
-			log.Fatalf("Ошибка при открытии файла логов: %v", err)
-		}
-		outputs = append(outputs, file)
+		logger.file = file
+		outputs = append([]io.Writer{file}, outputs...)
 	}
+	logger.output = &output{w: io.MultiWriter(outputs...)}
 
-	outputs = append(outputs, os.Stdout)
+	options := &slog.HandlerOptions{
+		AddSource:   true,
+		Level:       level,
+		ReplaceAttr: replaceAttr,
+	}
+	var handler slog.Handler
+	if cfg.Logging.Format == "json" {
+		handler = slog.NewJSONHandler(logger.output, options)
+	} else {
+		handler = slog.NewTextHandler(logger.output, options)
+	}
+	logger.logger = slog.New(handler)
+	return logger, nil
+}
 
-	multiWriter := io.MultiWriter(outputs...)
+// Close closes the log file. Loggers derived with With share it.
+func (l *Logger) Close() error {
+	if l.file == nil {
+		return nil
+	}
+	return l.file.Close()
+}
 
-	logger := &Logger{
-		infoLog:        log.New(multiWriter, "INFO: ", log.Ldate|log.Ltime|log.Lshortfile),
-		warnLog:        log.New(multiWriter, "WARN: ", log.Ldate|log.Ltime|log.Lshortfile),
-		errorLog:       log.New(multiWriter, "ERROR: ", log.Ldate|log.Ltime|log.Lshortfile),
-		debugLog:       log.New(multiWriter, "DEBUG: ", log.Ldate|log.Ltime|log.Lshortfile),
-		fatalLog:       log.New(multiWriter, "FATAL: ", log.Ldate|log.Ltime|log.Lshortfile),
-		output:         multiWriter,
-		isDebugEnabled: cfg.Logging.Level == "debug",
+// replaceAttr shortens sources to file:line and names the fatal level.
+func replaceAttr(_ []string, attr slog.Attr) slog.Attr {
+	switch attr.Key {
+	case slog.SourceKey:
+		if source, ok := attr.Value.Any().(*slog.Source); ok {
+			attr.Value = slog.StringValue(filepath.Base(source.File) + ":" + strconv.Itoa(source.Line))
+		}
+	case slog.LevelKey:
+		if level, ok := attr.Value.Any().(slog.Level); ok && level == LevelFatal {
+			attr.Value = slog.StringValue("FATAL")
+		}
 	}
-	return logger
+	return attr
 }
 
-func (l *Logger) Info(msg string) {
-	l.infoLog.Println(msg)
+// With returns a logger sharing l's outputs that adds the given key/value
+// pairs to every record, e.g. the target of a job.
+func (l *Logger) With(args ...any) *Logger {
+	return &Logger{logger: l.logger.With(args...), output: l.output, file: l.file}
 }
 
-func (l *Logger) Warn(msg string) {
-	l.warnLog.Println(msg)
+func (l *Logger) Info(msg string, args ...any) {
+	l.log(slog.LevelInfo, msg, args)
 }
 
-func (l *Logger) Error(msg string) {
-	l.errorLog.Println(msg)
+func (l *Logger) Warn(msg string, args ...any) {
+	l.log(slog.LevelWarn, msg, args)
 }
 
-func (l *Logger) Debug(msg string) {
-	if l.isDebugEnabled {
-		l.debugLog.Println(msg)
-	}
+func (l *Logger) Error(msg string, args ...any) {
+	l.log(slog.LevelError, msg, args)
 }
 
-func (l *Logger) Fatal(msg string) {
-	l.fatalLog.Println(msg)
+func (l *Logger) Debug(msg string, args ...any) {
+	l.log(slog.LevelDebug, msg, args)
+}
+
+func (l *Logger) Fatal(msg string, args ...any) {
+	l.log(LevelFatal, msg, args)
 	os.Exit(1)
 }
 
-func (l *Logger) SetOutput(output io.Writer) {
-	l.infoLog.SetOutput(output)
-	l.warnLog.SetOutput(output)
-	l.errorLog.SetOutput(output)
-	l.debugLog.SetOutput(output)
-	l.fatalLog.SetOutput(output)
-	l.output = output
+// log records the caller of the wrapper as the source, not this file.
+func (l *Logger) log(level slog.Level, msg string, args []any) {
+	ctx := context.Background()
+	if !l.logger.Enabled(ctx, level) {
+		return
+	}
+	var pcs [1]uintptr
+	runtime.Callers(3, pcs[:])
+	record := slog.NewRecord(time.Now(), level, msg, pcs[0])
+	record.Add(args...)
+	_ = l.logger.Handler().Handle(ctx, record)
+}
+
+// SetOutput redirects l and every logger derived from it with With.
+func (l *Logger) SetOutput(w io.Writer) {
+	l.output.set(w)
+}
+
+// WrapOutput routes the output of l and every logger derived from it
+// through wrap, e.g. to keep a terminal progress display intact.
+func (l *Logger) WrapOutput(wrap func(io.Writer) io.Writer) {
+	l.output.mu.Lock()
+	l.output.w = wrap(l.output.w)
+	l.output.mu.Unlock()
+}
+
+// output is a writer that can be swapped after handlers were built on it.
+type output struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (o *output) Write(p []byte) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.w.Write(p)
+}
+
+func (o *output) set(w io.Writer) {
+	o.mu.Lock()
+	o.w = w
+	o.mu.Unlock()
 }
@@ -1,7 +1,10 @@
 package logging
 
 import (
+	"bytes"
+	"encoding/json"
 	"os"
+	"strings"
 	"testing"
 
 	"github.com/itocode21/backup-tool/pkg/config"
@@ -17,7 +20,11 @@ func TestLogger(t *testing.T) {
 			File:  logFile,
 		},
 	}
-	logger := NewLogger(cfg)
+	logger, err := NewLogger(cfg)
+	if err != nil {
+		t.Fatalf("NewLogger returned error: %v", err)
+	}
+	defer logger.Close()
 
 	logger.Info("Test info Message")
 	logger.Debug("Test debug Message")
@@ -37,3 +44,52 @@ func TestLogger(t *testing.T) {
 func contains(data []byte, substr string) bool {
 	return string(data) != "" && string(data) != substr
 }
+
+func TestJSONFormat(t *testing.T) {
+	logger, err := NewLogger(&config.Config{Logging: config.LoggingConfig{Level: "info", Format: "json"}})
+	if err != nil {
+		t.Fatalf("NewLogger returned error: %v", err)
+	}
+	var buf bytes.Buffer
+	logger.SetOutput(&buf)
+
+	logger.With("target", "orders").Info("Job finished", "bytes", 42)
+	logger.Debug("Hidden below info")
+
+	var record map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("Expected a single JSON record, got %q: %v", buf.String(), err)
+	}
+	if record["msg"] != "Job finished" || record["level"] != "INFO" || record["target"] != "orders" || record["bytes"] != float64(42) {
+		t.Errorf("Unexpected record: %v", record)
+	}
+	if source, _ := record["source"].(string); !strings.HasPrefix(source, "logger_test.go:") {
+		t.Errorf("Expected the caller as source, got %v", record["source"])
+	}
+}
+
+func TestLevelFilter(t *testing.T) {
+	logger, err := NewLogger(&config.Config{Logging: config.LoggingConfig{Level: "warn"}})
+	if err != nil {
+		t.Fatalf("NewLogger returned error: %v", err)
+	}
+	var buf bytes.Buffer
+	logger.SetOutput(&buf)
+
+	logger.Debug("debug message")
+	logger.Info("info message")
+	logger.Warn("warn message")
+	logger.Error("error message")
+
+	out := buf.String()
+	if strings.Contains(out, "debug message") || strings.Contains(out, "info message") {
+		t.Errorf("Expected records below warn to be dropped, got %q", out)
+	}
+	if !strings.Contains(out, "warn message") || !strings.Contains(out, "error message") {
+		t.Errorf("Expected warn and error records, got %q", out)
+	}
+
+	if _, err := NewLogger(&config.Config{Logging: config.LoggingConfig{Level: "verbose"}}); err == nil {
+		t.Error("Expected an error for an unknown level")
+	}
+}
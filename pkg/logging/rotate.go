@@ -0,0 +1,243 @@
+package logging
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// rotationTimeFormat is embedded in rotated file names; it sorts
+// chronologically as a string.
+const rotationTimeFormat = "20060102T150405.000"
+
+// rotatingFile is an append-only log file that is moved aside once it
+// would grow beyond maxSize. Rotated files are optionally gzipped and
+// removed when there are more than maxBackups or they are older than maxAge.
+// Compression and removal run in a background goroutine so that writers
+// are not held up while a rotated file is gzipped.
+type rotatingFile struct {
+	mu         sync.Mutex
+	path       string
+	maxSize    int64
+	maxAge     time.Duration
+	maxBackups int
+	compress   bool
+
+	file   *os.File
+	size   int64
+	closed bool
+
+	// millCh wakes the mill goroutine after a rotation; millDone is closed
+	// once it has exited.
+	millCh   chan struct{}
+	millDone chan struct{}
+}
+
+func openRotatingFile(path string, maxSize int64, maxAge time.Duration, maxBackups int, compress bool) (*rotatingFile, error) {
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return nil, err
+	}
+	r := &rotatingFile{
+		path:       path,
+		maxSize:    maxSize,
+		maxAge:     maxAge,
+		maxBackups: maxBackups,
+		compress:   compress,
+		millCh:     make(chan struct{}, 1),
+		millDone:   make(chan struct{}),
+	}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	go r.mill()
+	return r, nil
+}
+
+func (r *rotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return 0, os.ErrClosed
+	}
+	if r.maxSize > 0 && r.size > 0 && r.size+int64(len(p)) > r.maxSize {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// Close closes the file and waits for pending compression and removal.
+func (r *rotatingFile) Close() error {
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		return os.ErrClosed
+	}
+	r.closed = true
+	err := r.file.Close()
+	close(r.millCh)
+	r.mu.Unlock()
+
+	<-r.millDone
+	return err
+}
+
+func (r *rotatingFile) open() error {
+	file, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	r.file, r.size = file, info.Size()
+	return nil
+}
+
+// rotate moves the current file to <name>-<time><ext>, starts a new one and
+// leaves compression and removal of old files to the mill goroutine.
+func (r *rotatingFile) rotate() error {
+	if err := r.file.Close(); err != nil {
+		return err
+	}
+	ext := filepath.Ext(r.path)
+	rotated := strings.TrimSuffix(r.path, ext) + "-" + time.Now().UTC().Format(rotationTimeFormat) + ext
+	if err := os.Rename(r.path, rotated); err != nil {
+		// Keep logging to the old file rather than to a closed one.
+		if openErr := r.open(); openErr != nil {
+			return openErr
+		}
+		return err
+	}
+	if err := r.open(); err != nil {
+		return err
+	}
+
+	select {
+	case r.millCh <- struct{}{}:
+	default:
+		// A wake-up is already pending and will see this file too.
+	}
+	return nil
+}
+
+// mill compresses and removes rotated files after each rotation until the
+// file is closed. Its errors cannot go to the log it serves, so they are
+// reported on stderr.
+func (r *rotatingFile) mill() {
+	defer close(r.millDone)
+	for range r.millCh {
+		if err := r.millOnce(); err != nil {
+			fmt.Fprintln(os.Stderr, "Failed to clean up rotated logs of "+r.path+": "+err.Error())
+		}
+	}
+}
+
+func (r *rotatingFile) millOnce() error {
+	if r.compress {
+		rotated, _, err := r.rotatedFiles()
+		if err != nil {
+			return err
+		}
+		for _, name := range rotated {
+			if strings.HasSuffix(name, ".gz") {
+				continue
+			}
+			if err := compressFile(filepath.Join(filepath.Dir(r.path), name)); err != nil {
+				return err
+			}
+		}
+	}
+	return r.removeOld()
+}
+
+// removeOld applies maxBackups and maxAge to the rotated files.
+func (r *rotatingFile) removeOld() error {
+	rotated, stamps, err := r.rotatedFiles()
+	if err != nil {
+		return err
+	}
+	for i, name := range rotated {
+		expired := r.maxBackups > 0 && i >= r.maxBackups
+		if !expired && r.maxAge > 0 && time.Since(stamps[name]) > r.maxAge {
+			expired = true
+		}
+		if expired {
+			if err := os.Remove(filepath.Join(filepath.Dir(r.path), name)); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// rotatedFiles lists the names of the rotated files, newest first, with
+// the time each was rotated.
+func (r *rotatingFile) rotatedFiles() ([]string, map[string]time.Time, error) {
+	ext := filepath.Ext(r.path)
+	prefix := strings.TrimSuffix(filepath.Base(r.path), ext) + "-"
+	entries, err := os.ReadDir(filepath.Dir(r.path))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Only names that carry a rotation timestamp are ours; another log
+	// sharing the prefix, such as app-worker.log next to app.log, is not.
+	var rotated []string
+	stamps := make(map[string]time.Time)
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasPrefix(name, prefix) || !(strings.HasSuffix(name, ext) || strings.HasSuffix(name, ext+".gz")) {
+			continue
+		}
+		stamp := strings.TrimSuffix(strings.TrimSuffix(strings.TrimPrefix(name, prefix), ".gz"), ext)
+		t, err := time.Parse(rotationTimeFormat, stamp)
+		if err != nil {
+			continue
+		}
+		rotated = append(rotated, name)
+		stamps[name] = t
+	}
+	// Newest first; the timestamp follows the shared prefix.
+	sort.Sort(sort.Reverse(sort.StringSlice(rotated)))
+	return rotated, stamps, nil
+}
+
+// compressFile replaces path with path.gz.
+func compressFile(path string) error {
+	input, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer input.Close()
+
+	output, err := os.OpenFile(path+".gz", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	writer := gzip.NewWriter(output)
+	if _, err := io.Copy(writer, input); err != nil {
+		output.Close()
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		output.Close()
+		return err
+	}
+	if err := output.Close(); err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
@@ -0,0 +1,153 @@
+package logging
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRotatingFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "backup.log")
+	file, err := openRotatingFile(path, 64, 0, 2, true)
+	if err != nil {
+		t.Fatalf("openRotatingFile returned error: %v", err)
+	}
+
+	line := strings.Repeat("x", 39) + "\n"
+	for i := 0; i < 5; i++ {
+		if _, err := file.Write([]byte(line)); err != nil {
+			t.Fatalf("Write returned error: %v", err)
+		}
+		// Rotated names carry millisecond timestamps.
+		time.Sleep(2 * time.Millisecond)
+	}
+	// Close waits for the background compression and removal
+	if err := file.Close(); err != nil {
+		t.Fatalf("Close returned error: %v", err)
+	}
+
+	current, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read log file: %v", err)
+	}
+	if string(current) != line {
+		t.Errorf("Expected the current file to hold the last line, got %q", current)
+	}
+
+	rotated, err := filepath.Glob(filepath.Join(dir, "backup-*.log.gz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rotated) != 2 {
+		t.Fatalf("Expected 2 compressed rotated files, got %v", rotated)
+	}
+	if plain, _ := filepath.Glob(filepath.Join(dir, "backup-*.log")); len(plain) != 0 {
+		t.Errorf("Expected rotated files to be compressed, found %v", plain)
+	}
+
+	f, err := os.Open(rotated[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	reader, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatalf("Rotated file is not gzip: %v", err)
+	}
+	content, err := io.ReadAll(reader)
+	if err != nil || string(content) != line {
+		t.Errorf("Unexpected rotated content %q: %v", content, err)
+	}
+}
+
+func TestRotatingFileMaxAge(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "backup.log")
+	stale := filepath.Join(dir, "backup-"+time.Now().Add(-48*time.Hour).UTC().Format(rotationTimeFormat)+".log")
+	if err := os.WriteFile(stale, []byte("old\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := openRotatingFile(path, 8, 24*time.Hour, 0, false)
+	if err != nil {
+		t.Fatalf("openRotatingFile returned error: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := file.Write([]byte("message\n")); err != nil {
+			t.Fatalf("Write returned error: %v", err)
+		}
+	}
+	// Close waits for the background compression and removal
+	if err := file.Close(); err != nil {
+		t.Fatalf("Close returned error: %v", err)
+	}
+
+	if _, err := os.Stat(stale); !os.IsNotExist(err) {
+		t.Errorf("Expected %s to be removed by max age", stale)
+	}
+	if rotated, _ := filepath.Glob(filepath.Join(dir, "backup-*.log")); len(rotated) != 1 {
+		t.Errorf("Expected the fresh rotated file to be kept, got %v", rotated)
+	}
+}
+
+func TestRotatingFileKeepsOtherLogs(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "backup.log")
+	// backup-worker.log shares the prefix but is not a rotated backup.log
+	other := filepath.Join(dir, "backup-worker.log")
+	if err := os.WriteFile(other, []byte("worker\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := openRotatingFile(path, 8, 0, 1, false)
+	if err != nil {
+		t.Fatalf("openRotatingFile returned error: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := file.Write([]byte("message\n")); err != nil {
+			t.Fatalf("Write returned error: %v", err)
+		}
+		time.Sleep(2 * time.Millisecond)
+	}
+	// Close waits for the background compression and removal
+	if err := file.Close(); err != nil {
+		t.Fatalf("Close returned error: %v", err)
+	}
+
+	if _, err := os.Stat(other); err != nil {
+		t.Errorf("Expected %s to be kept: %v", other, err)
+	}
+	if rotated, _ := filepath.Glob(filepath.Join(dir, "backup-2*.log")); len(rotated) != 1 {
+		t.Errorf("Expected max backups to count rotated files only, got %v", rotated)
+	}
+}
+
+func TestRotatingFileReopensAfterFailedRename(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "backup.log")
+	file, err := openRotatingFile(path, 8, 0, 0, false)
+	if err != nil {
+		t.Fatalf("openRotatingFile returned error: %v", err)
+	}
+	defer file.Close()
+	if _, err := file.Write([]byte("message\n")); err != nil {
+		t.Fatalf("Write returned error: %v", err)
+	}
+
+	// Rename fails once the log file is gone from under the writer
+	os.Remove(path)
+	if _, err := file.Write([]byte("rotated\n")); err == nil {
+		t.Fatal("Expected the failed rotation to be reported")
+	}
+	if _, err := file.Write([]byte("after\n")); err != nil {
+		t.Fatalf("Expected writes to continue after a failed rotation, got %v", err)
+	}
+	if data, _ := os.ReadFile(path); !strings.Contains(string(data), "after") {
+		t.Errorf("Expected the log file to be reopened, got %q", data)
+	}
+}
@@ -0,0 +1,126 @@
+package manifest
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Suffix is appended to an artifact path to get its manifest path.
+const Suffix = ".manifest.json"
+
+// Backup kinds. A full backup stands alone; an incremental one depends on
+// the backup before it and a differential one on the last full backup.
+const (
+	KindFull         = "full"
+	KindIncremental  = "incremental"
+	KindDifferential = "differential"
+)
+
+// Manifest describes a finished backup artifact. It is stored as JSON next
+// to the artifact so restores and later runs can tell what they are looking at.
+type Manifest struct {
+	Target    string        `json:"target"`
+	Engine    string        `json:"engine"`
+	Host      string        `json:"host"`
+	Database  string        `json:"database"`
+	Artifact  string        `json:"artifact"`
+	Format    string        `json:"format,omitempty"`
+	Tables    []string      `json:"tables,omitempty"`
+	SizeBytes int64         `json:"size_bytes"`
+	CreatedAt time.Time     `json:"created_at"`
+	Duration  time.Duration `json:"duration"`
+
+	// Kind is empty for manifests written before chains existed, which
+	// were all full backups. Parent is the artifact a non-full backup
+	// applies on top of.
+	Kind   string `json:"kind,omitempty"`
+	Parent string `json:"parent,omitempty"`
+
+	// Repository is the id the artifact is stored under in the
+	// deduplicating repository; the artifact itself is then removed.
+	Repository string `json:"repository,omitempty"`
+
+	// Timings holds how long each phase of the backup took, e.g.
+	// "pre_hooks", "dump" and "upload".
+	Timings map[string]time.Duration `json:"timings,omitempty"`
+
+	// TableCount is the number of tables in the artifact, when the engine
+	// can count them. Suspicious lists why the backup deviates from the
+	// earlier ones of its target.
+	TableCount *int     `json:"table_count,omitempty"`
+	Suspicious []string `json:"suspicious,omitempty"`
+}
+
+// IsFull reports whether m can be restored on its own.
+func (m *Manifest) IsFull() bool {
+	return m.Kind == "" || m.Kind == KindFull
+}
+
+// Path returns the manifest path for an artifact.
+func Path(artifact string) string {
+	return filepath.Clean(artifact) + Suffix
+}
+
+// Write stores m next to m.Artifact.
+func Write(m *Manifest) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// The manifest marks an artifact as complete, so it is written to a
+	// temporary file and renamed, never left half-written.
+	path := Path(m.Artifact)
+	file, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(file.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), path)
+}
+
+// Read loads the manifest of an artifact. The returned error satisfies
+// os.IsNotExist when the artifact has no manifest.
+func Read(artifact string) (*Manifest, error) {
+	data, err := os.ReadFile(Path(artifact))
+	if err != nil {
+		return nil, err
+	}
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// Size returns the size of an artifact, summing files for directory
+// artifacts such as mongodump output.
+func Size(artifact string) (int64, error) {
+	var size int64
+	err := filepath.Walk(artifact, func(_ string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size, err
+}
@@ -0,0 +1,155 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Registry holds metric families and writes them in the Prometheus text
+// exposition format. It serves them over HTTP as well.
+type Registry struct {
+	mu       sync.Mutex
+	families []*Vec
+}
+
+// Vec is a counter or gauge family with a fixed set of label names.
+type Vec struct {
+	registry *Registry
+	name     string
+	help     string
+	kind     string
+	labels   []string
+	samples  map[string]*sample
+}
+
+type sample struct {
+	values []string
+	value  float64
+}
+
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// Counter registers a family whose samples only go up.
+func (r *Registry) Counter(name, help string, labels ...string) *Vec {
+	return r.register(name, help, "counter", labels)
+}
+
+// Gauge registers a family whose samples can be set to any value.
+func (r *Registry) Gauge(name, help string, labels ...string) *Vec {
+	return r.register(name, help, "gauge", labels)
+}
+
+func (r *Registry) register(name, help, kind string, labels []string) *Vec {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	v := &Vec{registry: r, name: name, help: help, kind: kind, labels: labels, samples: make(map[string]*sample)}
+	r.families = append(r.families, v)
+	return v
+}
+
+// Add increases the sample with the given label values by delta.
+func (v *Vec) Add(delta float64, values ...string) {
+	v.registry.mu.Lock()
+	defer v.registry.mu.Unlock()
+	v.sample(values).value += delta
+}
+
+// Set replaces the value of the sample with the given label values.
+func (v *Vec) Set(value float64, values ...string) {
+	v.registry.mu.Lock()
+	defer v.registry.mu.Unlock()
+	v.sample(values).value = value
+}
+
+// Max raises the sample with the given label values to value, if it is
+// lower. It keeps timestamps from going back.
+func (v *Vec) Max(value float64, values ...string) {
+	v.registry.mu.Lock()
+	defer v.registry.mu.Unlock()
+	s := v.sample(values)
+	s.value = max(s.value, value)
+}
+
+// Value returns the current value of a sample, zero if it was never set.
+func (v *Vec) Value(values ...string) float64 {
+	v.registry.mu.Lock()
+	defer v.registry.mu.Unlock()
+	if s, ok := v.samples[strings.Join(values, "\xff")]; ok {
+		return s.value
+	}
+	return 0
+}
+
+// Reset drops all samples, e.g. before a gauge is rebuilt from scratch.
+func (v *Vec) Reset() {
+	v.registry.mu.Lock()
+	defer v.registry.mu.Unlock()
+	v.samples = make(map[string]*sample)
+}
+
+func (v *Vec) sample(values []string) *sample {
+	if len(values) != len(v.labels) {
+		panic(fmt.Sprintf("metric %s: expected %d label values, got %d", v.name, len(v.labels), len(values)))
+	}
+	key := strings.Join(values, "\xff")
+	s, ok := v.samples[key]
+	if !ok {
+		s = &sample{values: append([]string(nil), values...)}
+		v.samples[key] = s
+	}
+	return s
+}
+
+// Write prints every family in the text exposition format, samples sorted
+// by label values.
+func (r *Registry) Write(w io.Writer) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var b strings.Builder
+	for _, v := range r.families {
+		fmt.Fprintf(&b, "# HELP %s %s\n", v.name, escapeHelp(v.help))
+		fmt.Fprintf(&b, "# TYPE %s %s\n", v.name, v.kind)
+
+		keys := make([]string, 0, len(v.samples))
+		for key := range v.samples {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			s := v.samples[key]
+			b.WriteString(v.name)
+			if len(v.labels) > 0 {
+				pairs := make([]string, len(v.labels))
+				for i, label := range v.labels {
+					pairs[i] = label + `="` + escapeLabel(s.values[i]) + `"`
+				}
+				b.WriteString("{" + strings.Join(pairs, ",") + "}")
+			}
+			b.WriteString(" " + strconv.FormatFloat(s.value, 'g', -1, 64) + "\n")
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// ServeHTTP serves the metrics for a Prometheus scrape.
+func (r *Registry) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	r.Write(w)
+}
+
+func escapeHelp(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(s)
+}
+
+func escapeLabel(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`).Replace(s)
+}
@@ -0,0 +1,60 @@
+package metrics
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWrite(t *testing.T) {
+	r := NewRegistry()
+	runs := r.Counter("backup_tool_runs_total", "Finished runs.", "target", "status")
+	size := r.Gauge("backup_tool_size_bytes", "Artifact size.")
+	last := r.Gauge("backup_tool_last_seconds", "Last success.", "target")
+
+	runs.Add(1, "orders", "success")
+	runs.Add(2, "orders", "success")
+	runs.Add(1, `we"ird`, "failure")
+	size.Set(1.5e9)
+	last.Max(200, "orders")
+	last.Max(100, "orders")
+
+	var buf bytes.Buffer
+	if err := r.Write(&buf); err != nil {
+		t.Fatalf("Write returned error: %v", err)
+	}
+	want := `# HELP backup_tool_runs_total Finished runs.
+# TYPE backup_tool_runs_total counter
+backup_tool_runs_total{target="orders",status="success"} 3
+backup_tool_runs_total{target="we\"ird",status="failure"} 1
+# HELP backup_tool_size_bytes Artifact size.
+# TYPE backup_tool_size_bytes gauge
+backup_tool_size_bytes 1.5e+09
+# HELP backup_tool_last_seconds Last success.
+# TYPE backup_tool_last_seconds gauge
+backup_tool_last_seconds{target="orders"} 200
+`
+	if buf.String() != want {
+		t.Errorf("Unexpected exposition:\n%s\nwant:\n%s", buf.String(), want)
+	}
+
+	last.Reset()
+	if last.Value("orders") != 0 {
+		t.Errorf("Expected Reset to drop samples")
+	}
+}
+
+func TestServeHTTP(t *testing.T) {
+	r := NewRegistry()
+	r.Counter("backup_tool_runs_total", "Finished runs.").Add(1)
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/plain; version=0.0.4") {
+		t.Errorf("Unexpected content type %q", rec.Header().Get("Content-Type"))
+	}
+	if !strings.Contains(rec.Body.String(), "backup_tool_runs_total 1\n") {
+		t.Errorf("Unexpected body: %s", rec.Body.String())
+	}
+}
@@ -0,0 +1,34 @@
+package notify
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSlack(t *testing.T) {
+	var got map[string]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("Unexpected content type %q", r.Header.Get("Content-Type"))
+		}
+		json.NewDecoder(r.Body).Decode(&got)
+	}))
+	defer server.Close()
+
+	if err := Slack(server.URL, "backup looks suspicious"); err != nil {
+		t.Fatalf("Slack failed: %v", err)
+	}
+	if got["text"] != "backup looks suspicious" {
+		t.Errorf("Unexpected payload: %v", got)
+	}
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "invalid_token", http.StatusForbidden)
+	}))
+	defer failing.Close()
+	if err := Slack(failing.URL, "text"); err == nil {
+		t.Error("Expected an error for a rejected webhook")
+	}
+}
@@ -0,0 +1,127 @@
+package progress
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/itocode21/backup-tool/pkg/logging"
+)
+
+const barWidth = 24
+
+// Bar draws one progress line per running phase at the bottom of a
+// terminal. Output written through Wrap is printed above the lines, so log
+// records and bars do not garble each other.
+type Bar struct {
+	mu     sync.Mutex
+	out    io.Writer
+	lines  int
+	active map[string]Snapshot
+	order  []string
+}
+
+func NewBar(out io.Writer) *Bar {
+	return &Bar{out: out, active: make(map[string]Snapshot)}
+}
+
+func (b *Bar) Report(s Snapshot) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	key := s.JobID + "/" + s.Phase
+	if _, ok := b.active[key]; !ok && !s.Done {
+		b.order = append(b.order, key)
+	}
+	if s.Done {
+		delete(b.active, key)
+		for i, k := range b.order {
+			if k == key {
+				b.order = append(b.order[:i], b.order[i+1:]...)
+				break
+			}
+		}
+	} else {
+		b.active[key] = s
+	}
+	b.clear()
+	b.draw()
+}
+
+// Wrap returns a writer that writes to w with the bars cleared.
+func (b *Bar) Wrap(w io.Writer) io.Writer {
+	return writerFunc(func(p []byte) (int, error) {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		b.clear()
+		n, err := w.Write(p)
+		b.draw()
+		return n, err
+	})
+}
+
+// clear erases the drawn lines; b.mu must be held.
+func (b *Bar) clear() {
+	if b.lines > 0 {
+		fmt.Fprintf(b.out, "\033[%dA\033[J", b.lines)
+		b.lines = 0
+	}
+}
+
+// draw prints a line per active phase; b.mu must be held.
+func (b *Bar) draw() {
+	var out strings.Builder
+	for _, key := range b.order {
+		out.WriteString(Line(b.active[key]) + "\n")
+	}
+	io.WriteString(b.out, out.String())
+	b.lines = len(b.order)
+}
+
+// Line formats a snapshot for humans, e.g.
+// "orders  dump  [=====>      ]  45%  1.2 GiB/2.7 GiB  48.0 MiB/s  ETA 32s".
+func Line(s Snapshot) string {
+	parts := []string{s.Target, s.Phase}
+	if percent, ok := s.Percent(); ok {
+		filled := int(percent / 100 * barWidth)
+		bar := strings.Repeat("=", filled)
+		if filled < barWidth {
+			bar += ">" + strings.Repeat(" ", barWidth-filled-1)
+		}
+		parts = append(parts, "["+bar+"]", fmt.Sprintf("%3.0f%%", percent), FormatBytes(s.Bytes)+"/"+FormatBytes(s.Total))
+	} else {
+		parts = append(parts, FormatBytes(s.Bytes))
+	}
+	parts = append(parts, FormatBytes(int64(s.Rate()))+"/s")
+	if eta, ok := s.ETA(); ok {
+		parts = append(parts, "ETA "+eta.Round(time.Second).String())
+	}
+	return strings.Join(parts, "  ")
+}
+
+type writerFunc func([]byte) (int, error)
+
+func (f writerFunc) Write(p []byte) (int, error) { return f(p) }
+
+// Log writes a progress record per report to logger, for output that is
+// not a terminal. Final reports are left to the job's own records.
+type Log struct {
+	Logger *logging.Logger
+}
+
+func (l Log) Report(s Snapshot) {
+	if s.Done {
+		return
+	}
+	args := []any{"job_id", s.JobID, "target", s.Target, "phase", s.Phase,
+		"bytes", s.Bytes, "elapsed", s.Elapsed.Round(time.Second), "rate_bytes_per_second", int64(s.Rate())}
+	if percent, ok := s.Percent(); ok {
+		args = append(args, "expected_bytes", s.Total, "percent", int(percent))
+	}
+	if eta, ok := s.ETA(); ok {
+		args = append(args, "eta", eta.Round(time.Second))
+	}
+	l.Logger.Info("Progress", args...)
+}
@@ -0,0 +1,123 @@
+package progress
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// Snapshot is the progress of one phase of a job. Total is the expected
+// size, taken from the previous backup for dumps; zero means unknown.
+type Snapshot struct {
+	JobID   string
+	Target  string
+	Phase   string
+	Bytes   int64
+	Total   int64
+	Elapsed time.Duration
+	Done    bool
+}
+
+// Rate returns the average throughput in bytes per second.
+func (s Snapshot) Rate() float64 {
+	if s.Elapsed <= 0 {
+		return 0
+	}
+	return float64(s.Bytes) / s.Elapsed.Seconds()
+}
+
+// Percent returns how much of Total is done, capped at 100.
+func (s Snapshot) Percent() (float64, bool) {
+	if s.Total <= 0 {
+		return 0, false
+	}
+	return min(100*float64(s.Bytes)/float64(s.Total), 100), true
+}
+
+// ETA estimates the time left from the average rate. It is unknown without
+// a total and once the phase outgrew it.
+func (s Snapshot) ETA() (time.Duration, bool) {
+	rate := s.Rate()
+	if s.Total <= 0 || rate <= 0 || s.Bytes >= s.Total {
+		return 0, false
+	}
+	return time.Duration(float64(s.Total-s.Bytes) / rate * float64(time.Second)), true
+}
+
+// Reporter receives snapshots periodically while a phase runs and a final
+// one with Done set when it ends. Reports of concurrent jobs may interleave.
+type Reporter interface {
+	Report(Snapshot)
+}
+
+// ReporterFunc adapts a function to Reporter.
+type ReporterFunc func(Snapshot)
+
+func (f ReporterFunc) Report(s Snapshot) { f(s) }
+
+// Multi sends every snapshot to each of its reporters.
+type Multi []Reporter
+
+func (m Multi) Report(s Snapshot) {
+	for _, r := range m {
+		r.Report(s)
+	}
+}
+
+// Watch reports s with Bytes set from size every interval until the
+// returned function is called, which sends the final report.
+func Watch(r Reporter, s Snapshot, interval time.Duration, size func() int64) (stop func()) {
+	start := time.Now()
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	report := func(final bool) {
+		s.Bytes, s.Elapsed, s.Done = size(), time.Since(start), final
+		r.Report(s)
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				report(false)
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(done)
+			wg.Wait()
+			report(true)
+		})
+	}
+}
+
+// IsTerminal reports whether f is a character device, e.g. an interactive
+// terminal rather than a pipe or file.
+func IsTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// FormatBytes prints n with a binary unit, e.g. 1.5 GiB.
+func FormatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
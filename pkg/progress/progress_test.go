@@ -0,0 +1,97 @@
+package progress
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSnapshot(t *testing.T) {
+	s := Snapshot{Bytes: 250, Total: 1000, Elapsed: 5 * time.Second}
+	if rate := s.Rate(); rate != 50 {
+		t.Errorf("Expected 50 B/s, got %v", rate)
+	}
+	if percent, ok := s.Percent(); !ok || percent != 25 {
+		t.Errorf("Expected 25%%, got %v %v", percent, ok)
+	}
+	if eta, ok := s.ETA(); !ok || eta != 15*time.Second {
+		t.Errorf("Expected an ETA of 15s, got %v %v", eta, ok)
+	}
+
+	// Дамп вырос больше прошлого — процент упирается в 100, ETA неизвестен
+	s.Bytes = 1500
+	if percent, _ := s.Percent(); percent != 100 {
+		t.Errorf("Expected the percentage to be capped, got %v", percent)
+	}
+	if _, ok := s.ETA(); ok {
+		t.Error("Expected no ETA past the total")
+	}
+	if _, ok := (Snapshot{Bytes: 10, Elapsed: time.Second}).Percent(); ok {
+		t.Error("Expected no percentage without a total")
+	}
+}
+
+func TestWatch(t *testing.T) {
+	var mu sync.Mutex
+	var reports []Snapshot
+	var size atomic.Int64
+	stop := Watch(ReporterFunc(func(s Snapshot) {
+		mu.Lock()
+		reports = append(reports, s)
+		mu.Unlock()
+	}), Snapshot{Target: "orders", Phase: "dump"}, 5*time.Millisecond, func() int64 {
+		return size.Add(10)
+	})
+	time.Sleep(30 * time.Millisecond)
+	stop()
+	stop()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(reports) < 2 {
+		t.Fatalf("Expected periodic reports, got %+v", reports)
+	}
+	last := reports[len(reports)-1]
+	if !last.Done || last.Target != "orders" || last.Bytes != int64(10*len(reports)) {
+		t.Errorf("Unexpected final report: %+v", last)
+	}
+	for _, r := range reports[:len(reports)-1] {
+		if r.Done {
+			t.Errorf("Expected only the last report to be final, got %+v", reports)
+		}
+	}
+}
+
+func TestBar(t *testing.T) {
+	var out bytes.Buffer
+	bar := NewBar(&out)
+	bar.Report(Snapshot{JobID: "1", Target: "orders", Phase: "dump", Bytes: 512, Total: 1024, Elapsed: time.Second})
+	if !strings.Contains(out.String(), " 50%") || !strings.Contains(out.String(), "512 B/1.0 KiB") {
+		t.Errorf("Unexpected bar: %q", out.String())
+	}
+
+	// Запись лога стирает полосу, печатает строку и рисует полосу заново
+	out.Reset()
+	io.WriteString(bar.Wrap(&out), "log line\n")
+	if !strings.HasPrefix(out.String(), "\033[1A\033[J"+"log line\n") || !strings.Contains(out.String(), "orders  dump") {
+		t.Errorf("Unexpected output around a log write: %q", out.String())
+	}
+
+	out.Reset()
+	bar.Report(Snapshot{JobID: "1", Target: "orders", Phase: "dump", Done: true})
+	if out.String() != "\033[1A\033[J" {
+		t.Errorf("Expected a finished phase to be erased, got %q", out.String())
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	for n, want := range map[int64]string{0: "0 B", 1023: "1023 B", 1536: "1.5 KiB", 3 << 30: "3.0 GiB"} {
+		if got := FormatBytes(n); got != want {
+			t.Errorf("FormatBytes(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
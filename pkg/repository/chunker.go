@@ -0,0 +1,92 @@
+package repository
+
+import (
+	"errors"
+	"io"
+	"math/bits"
+)
+
+// Chunker splits a stream at content-defined boundaries with a gear rolling
+// hash, so an insertion only changes the chunks around it and the rest of
+// a mostly unchanged dump deduplicates against the previous one.
+type Chunker struct {
+	Min int
+	Avg int
+	Max int
+}
+
+// DefaultAvgChunkSize is used when the configuration leaves it unset.
+const DefaultAvgChunkSize = 1 << 20
+
+// NewChunker derives the minimum and maximum chunk sizes from the average,
+// which is rounded down to a power of two.
+func NewChunker(avg int) Chunker {
+	if avg <= 0 {
+		avg = DefaultAvgChunkSize
+	}
+	avg = 1 << (bits.Len(uint(avg)) - 1)
+	return Chunker{Min: avg / 4, Avg: avg, Max: avg * 4}
+}
+
+// gear holds the per-byte values of the rolling hash. They come from a
+// fixed seed because changing them would change every chunk boundary and
+// defeat deduplication against existing backups.
+var gear = func() [256]uint64 {
+	var table [256]uint64
+	state := uint64(0x6261636b75702d74)
+	for i := range table {
+		// splitmix64
+		state += 0x9e3779b97f4a7c15
+		z := state
+		z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+		z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+		table[i] = z ^ (z >> 31)
+	}
+	return table
+}()
+
+// Split reads r to the end and calls emit with each chunk. The slice passed
+// to emit is only valid until emit returns.
+func (c Chunker) Split(r io.Reader, emit func(chunk []byte) error) error {
+	// The boundary test looks at the top bits of the hash, which depend on
+	// the last 64 bytes read.
+	shift := 64 - (bits.Len(uint(c.Avg)) - 1)
+	mask := ^uint64(0) << shift
+
+	// buf is refilled to Max before every cut, so a chunk without a
+	// boundary is exactly Max bytes long unless the stream ends.
+	buf := make([]byte, c.Max)
+	n := 0
+	eof := false
+	for {
+		if !eof {
+			read, err := io.ReadFull(r, buf[n:])
+			n += read
+			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+				eof = true
+			} else if err != nil {
+				return err
+			}
+		}
+		if n == 0 {
+			return nil
+		}
+
+		cut := n
+		if n > c.Min {
+			var hash uint64
+			for i := c.Min; i < n; i++ {
+				hash = hash<<1 + gear[buf[i]]
+				if hash&mask == 0 {
+					cut = i + 1
+					break
+				}
+			}
+		}
+
+		if err := emit(buf[:cut]); err != nil {
+			return err
+		}
+		n = copy(buf, buf[cut:n])
+	}
+}
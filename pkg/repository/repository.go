@@ -0,0 +1,254 @@
+package repository
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/itocode21/backup-tool/pkg/storage"
+)
+
+const (
+	chunkPrefix = "chunks/"
+	indexPrefix = "indexes/"
+	indexSuffix = ".json"
+)
+
+// Repository stores backup artifacts as deduplicated chunks. Chunks are
+// keyed by their SHA-256 and shared between backups; each backup has an
+// index listing the chunks of its files.
+type Repository struct {
+	Storage storage.Storage
+	Chunker Chunker
+
+	// Progress, if set, is called after each chunk Store reads with the
+	// number of bytes read so far.
+	Progress func(bytes int64)
+}
+
+// Index describes one stored artifact. A single-file artifact has one File
+// with Path "."; directory artifacts list their files relative to the root.
+type Index struct {
+	ID        string    `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	Files     []File    `json:"files"`
+}
+
+type File struct {
+	Path   string      `json:"path"`
+	Mode   os.FileMode `json:"mode"`
+	Size   int64       `json:"size"`
+	Chunks []string    `json:"chunks"`
+}
+
+// Stats reports how much of a stored artifact was new to the repository.
+type Stats struct {
+	Files     int
+	Bytes     int64
+	NewBytes  int64
+	Chunks    int
+	NewChunks int
+}
+
+func New(store storage.Storage, avgChunkSize int) *Repository {
+	return &Repository{Storage: store, Chunker: NewChunker(avgChunkSize)}
+}
+
+// Store chunks the artifact at path and records it under id.
+func (r *Repository) Store(id, path string) (Stats, error) {
+	var stats Stats
+	index := Index{ID: id, CreatedAt: time.Now().UTC()}
+
+	err := filepath.Walk(path, func(file string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(path, file)
+		if err != nil {
+			return err
+		}
+		entry, err := r.storeFile(file, &stats)
+		if err != nil {
+			return fmt.Errorf("store %s: %w", file, err)
+		}
+		entry.Path = filepath.ToSlash(rel)
+		entry.Mode = info.Mode().Perm()
+		index.Files = append(index.Files, entry)
+		stats.Files++
+		return nil
+	})
+	if err != nil {
+		return stats, err
+	}
+
+	data, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return stats, err
+	}
+	return stats, r.Storage.Put(indexKey(id), bytes.NewReader(data))
+}
+
+func (r *Repository) storeFile(path string, stats *Stats) (File, error) {
+	input, err := os.Open(path)
+	if err != nil {
+		return File{}, err
+	}
+	defer input.Close()
+
+	var entry File
+	err = r.Chunker.Split(input, func(chunk []byte) error {
+		sum := sha256.Sum256(chunk)
+		hash := hex.EncodeToString(sum[:])
+		entry.Chunks = append(entry.Chunks, hash)
+		entry.Size += int64(len(chunk))
+		stats.Chunks++
+		stats.Bytes += int64(len(chunk))
+		if r.Progress != nil {
+			r.Progress(stats.Bytes)
+		}
+
+		exists, err := r.Storage.Exists(chunkKey(hash))
+		if err != nil || exists {
+			return err
+		}
+		stats.NewChunks++
+		stats.NewBytes += int64(len(chunk))
+		return r.Storage.Put(chunkKey(hash), bytes.NewReader(chunk))
+	})
+	return entry, err
+}
+
+// Restore writes the artifact stored under id to path, verifying every
+// chunk against its hash.
+func (r *Repository) Restore(id, path string) error {
+	index, err := r.Index(id)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range index.Files {
+		target := path
+		if entry.Path != "." {
+			target = filepath.Join(path, filepath.FromSlash(entry.Path))
+		}
+		if err := os.MkdirAll(filepath.Dir(target), os.ModePerm); err != nil {
+			return err
+		}
+		if err := r.restoreFile(entry, target); err != nil {
+			return fmt.Errorf("restore %s: %w", target, err)
+		}
+	}
+	return nil
+}
+
+func (r *Repository) restoreFile(entry File, path string) error {
+	output, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, entry.Mode)
+	if err != nil {
+		return err
+	}
+	for _, hash := range entry.Chunks {
+		if err := r.copyChunk(output, hash); err != nil {
+			output.Close()
+			return err
+		}
+	}
+	return output.Close()
+}
+
+func (r *Repository) copyChunk(w io.Writer, hash string) error {
+	reader, err := r.Storage.Get(chunkKey(hash))
+	if err != nil {
+		return fmt.Errorf("chunk %s: %w", hash, err)
+	}
+	defer reader.Close()
+
+	digest := sha256.New()
+	if _, err := io.Copy(w, io.TeeReader(reader, digest)); err != nil {
+		return err
+	}
+	if hex.EncodeToString(digest.Sum(nil)) != hash {
+		return fmt.Errorf("chunk %s is corrupt", hash)
+	}
+	return nil
+}
+
+// Index loads the index of a stored artifact.
+func (r *Repository) Index(id string) (*Index, error) {
+	reader, err := r.Storage.Get(indexKey(id))
+	if err != nil {
+		return nil, fmt.Errorf("index %s: %w", id, err)
+	}
+	defer reader.Close()
+
+	var index Index
+	if err := json.NewDecoder(reader).Decode(&index); err != nil {
+		return nil, fmt.Errorf("index %s: %w", id, err)
+	}
+	return &index, nil
+}
+
+// Delete removes the index of id. Its chunks stay until GC finds them
+// unreferenced.
+func (r *Repository) Delete(id string) error {
+	return r.Storage.Delete(indexKey(id))
+}
+
+// GC deletes chunks that no index references and returns how many it
+// removed. It must not run concurrently with Store, whose chunks are
+// written before the index that references them; callers serialize the
+// two with a lock on the repository.
+func (r *Repository) GC() (int, error) {
+	keys, err := r.Storage.List(indexPrefix)
+	if err != nil {
+		return 0, err
+	}
+	referenced := make(map[string]bool)
+	for _, key := range keys {
+		index, err := r.Index(strings.TrimSuffix(strings.TrimPrefix(key, indexPrefix), indexSuffix))
+		if errors.Is(err, storage.ErrNotExist) {
+			// Deleted since the listing; its chunks are free to go.
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+		for _, entry := range index.Files {
+			for _, hash := range entry.Chunks {
+				referenced[hash] = true
+			}
+		}
+	}
+
+	chunks, err := r.Storage.List(chunkPrefix)
+	if err != nil {
+		return 0, err
+	}
+	removed := 0
+	for _, key := range chunks {
+		if referenced[key[strings.LastIndex(key, "/")+1:]] {
+			continue
+		}
+		if err := r.Storage.Delete(key); err != nil {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+// chunkKey spreads chunks over 256 directories by hash prefix.
+func chunkKey(hash string) string {
+	return chunkPrefix + hash[:2] + "/" + hash
+}
+
+func indexKey(id string) string {
+	return indexPrefix + id + indexSuffix
+}
@@ -0,0 +1,113 @@
+package repository
+
+import (
+	"bytes"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/itocode21/backup-tool/pkg/storage"
+)
+
+func randomData(seed int64, size int) []byte {
+	data := make([]byte, size)
+	rand.New(rand.NewSource(seed)).Read(data)
+	return data
+}
+
+func split(t *testing.T, c Chunker, data []byte) [][]byte {
+	t.Helper()
+	var chunks [][]byte
+	err := c.Split(bytes.NewReader(data), func(chunk []byte) error {
+		chunks = append(chunks, append([]byte(nil), chunk...))
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Split returned error: %v", err)
+	}
+	return chunks
+}
+
+func TestChunkerBoundaries(t *testing.T) {
+	c := NewChunker(64 << 10)
+	data := randomData(1, 4<<20)
+
+	chunks := split(t, c, data)
+	if !bytes.Equal(bytes.Join(chunks, nil), data) {
+		t.Fatal("Chunks do not add up to the input")
+	}
+	for i, chunk := range chunks {
+		if len(chunk) > c.Max || (len(chunk) < c.Min && i != len(chunks)-1) {
+			t.Errorf("Chunk %d has size %d outside [%d, %d]", i, len(chunk), c.Min, c.Max)
+		}
+	}
+
+	// Вставка в середину должна изменить только соседние чанки
+	edited := append(append(append([]byte{}, data[:2<<20]...), []byte("inserted row")...), data[2<<20:]...)
+	before := make(map[string]bool)
+	for _, chunk := range chunks {
+		before[string(chunk)] = true
+	}
+	changed := 0
+	for _, chunk := range split(t, c, edited) {
+		if !before[string(chunk)] {
+			changed++
+		}
+	}
+	if changed > 3 {
+		t.Errorf("Expected at most 3 changed chunks after an insertion, got %d of %d", changed, len(chunks))
+	}
+}
+
+func TestStoreRestoreAndGC(t *testing.T) {
+	dir := t.TempDir()
+	repo := New(storage.NewLocal(filepath.Join(dir, "repo")), 64<<10)
+
+	day1 := filepath.Join(dir, "day1.sql")
+	day2 := filepath.Join(dir, "day2")
+	data := randomData(2, 1<<20)
+	if err := os.WriteFile(day1, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(day2, "orders"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(day2, "orders", "data.sql"), append(data, "-- day 2\n"...), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := repo.Store("db/day1", day1); err != nil {
+		t.Fatalf("Store returned error: %v", err)
+	}
+	stats, err := repo.Store("db/day2", day2)
+	if err != nil {
+		t.Fatalf("Store returned error: %v", err)
+	}
+	if stats.NewBytes*4 > stats.Bytes {
+		t.Errorf("Expected most of day 2 to deduplicate, %d of %d bytes were new", stats.NewBytes, stats.Bytes)
+	}
+
+	restored := filepath.Join(dir, "restored")
+	if err := repo.Restore("db/day2", restored); err != nil {
+		t.Fatalf("Restore returned error: %v", err)
+	}
+	got, err := os.ReadFile(filepath.Join(restored, "orders", "data.sql"))
+	if err != nil || !bytes.Equal(got, append(data, "-- day 2\n"...)) {
+		t.Fatalf("Restored file differs from the original: %v", err)
+	}
+
+	if err := repo.Delete("db/day2"); err != nil {
+		t.Fatalf("Delete returned error: %v", err)
+	}
+	removed, err := repo.GC()
+	if err != nil {
+		t.Fatalf("GC returned error: %v", err)
+	}
+	if removed == 0 || removed > 2 {
+		t.Errorf("Expected GC to remove only the chunks unique to day 2, removed %d", removed)
+	}
+	if err := repo.Restore("db/day1", filepath.Join(dir, "day1-restored.sql")); err != nil {
+		t.Errorf("Day 1 must still restore after GC: %v", err)
+	}
+}
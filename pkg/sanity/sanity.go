@@ -0,0 +1,91 @@
+package sanity
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"time"
+
+	"github.com/itocode21/backup-tool/pkg/config"
+	"github.com/itocode21/backup-tool/pkg/progress"
+)
+
+// DefaultBaseline is how many earlier backups are compared against when
+// sanity.baseline is not set.
+const DefaultBaseline = 5
+
+// MinBaseline is how many earlier backups a comparison needs; with fewer a
+// new target would be flagged for whatever its first runs look like.
+const MinBaseline = 3
+
+// Sample is what is compared of one backup. Tables is nil when the engine
+// cannot count the tables of its artifacts.
+type Sample struct {
+	Bytes    int64
+	Tables   *int
+	Duration time.Duration
+}
+
+// Check compares current against the median of baseline and returns why
+// it looks wrong, or nothing if it does not.
+func Check(current Sample, baseline []Sample, cfg config.SanityConfig) []string {
+	var reasons []string
+	if cfg.MinSizeBytes > 0 && current.Bytes < cfg.MinSizeBytes {
+		reasons = append(reasons, fmt.Sprintf("size %s is below the minimum of %s",
+			progress.FormatBytes(current.Bytes), progress.FormatBytes(cfg.MinSizeBytes)))
+	}
+	if len(baseline) < MinBaseline {
+		return reasons
+	}
+
+	var sizes, tables, durations []float64
+	for _, s := range baseline {
+		sizes = append(sizes, float64(s.Bytes))
+		durations = append(durations, float64(s.Duration))
+		if s.Tables != nil {
+			tables = append(tables, float64(*s.Tables))
+		}
+	}
+
+	if reason, ok := deviates(float64(current.Bytes), sizes, cfg.SizeChangePercent); ok {
+		reasons = append(reasons, fmt.Sprintf("size %s %s the median of %s", progress.FormatBytes(current.Bytes),
+			reason, progress.FormatBytes(int64(median(sizes)))))
+	}
+	if current.Tables != nil && len(tables) >= MinBaseline {
+		if reason, ok := deviates(float64(*current.Tables), tables, cfg.TableChangePercent); ok {
+			reasons = append(reasons, fmt.Sprintf("%d tables %s the median of %.0f", *current.Tables, reason, median(tables)))
+		}
+	}
+	if reason, ok := deviates(float64(current.Duration), durations, cfg.DurationChangePercent); ok {
+		reasons = append(reasons, fmt.Sprintf("duration %s %s the median of %s", current.Duration.Round(time.Second),
+			reason, time.Duration(median(durations)).Round(time.Second)))
+	}
+	return reasons
+}
+
+// deviates reports whether value differs from the median of baseline by
+// more than percent, and describes by how much.
+func deviates(value float64, baseline []float64, percent int) (string, bool) {
+	m := median(baseline)
+	if percent <= 0 || m <= 0 {
+		return "", false
+	}
+	change := (value - m) / m * 100
+	if math.Abs(change) <= float64(percent) {
+		return "", false
+	}
+	if change < 0 {
+		return fmt.Sprintf("is %.0f%% below", -change), true
+	}
+	return fmt.Sprintf("is %.0f%% above", change), true
+}
+
+func median(values []float64) float64 {
+	sorted := slices.Clone(values)
+	slices.Sort(sorted)
+	n := len(sorted)
+	if n%2 == 1 {
+		return sorted[n/2]
+	}
+	return (sorted[n/2-1] + sorted[n/2]) / 2
+}
@@ -0,0 +1,57 @@
+package sanity
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/itocode21/backup-tool/pkg/config"
+)
+
+func tables(n int) *int {
+	return &n
+}
+
+func TestCheck(t *testing.T) {
+	cfg := config.SanityConfig{MinSizeBytes: 4096, SizeChangePercent: 50, TableChangePercent: 10, DurationChangePercent: 200}
+	baseline := []Sample{
+		{Bytes: 10 << 20, Tables: tables(40), Duration: time.Minute},
+		{Bytes: 11 << 20, Tables: tables(40), Duration: 70 * time.Second},
+		{Bytes: 9 << 20, Tables: tables(41), Duration: 50 * time.Second},
+	}
+
+	tests := []struct {
+		name    string
+		current Sample
+		want    []string
+	}{
+		{"normal", Sample{Bytes: 12 << 20, Tables: tables(41), Duration: 80 * time.Second}, nil},
+		{"empty dump", Sample{Bytes: 2048, Tables: tables(0), Duration: time.Second}, []string{"below the minimum", "size 2.0 KiB is 100% below", "0 tables is 100% below"}},
+		{"grown", Sample{Bytes: 30 << 20, Tables: tables(40), Duration: 4 * time.Minute}, []string{"size 30.0 MiB is 200% above", "duration 4m0s is 300% above"}},
+		{"tables unknown", Sample{Bytes: 10 << 20, Duration: time.Minute}, nil},
+	}
+	for _, tt := range tests {
+		reasons := Check(tt.current, baseline, cfg)
+		if len(reasons) != len(tt.want) {
+			t.Errorf("%s: expected %d reasons, got %q", tt.name, len(tt.want), reasons)
+			continue
+		}
+		for i, want := range tt.want {
+			if !strings.Contains(reasons[i], want) {
+				t.Errorf("%s: expected %q in %q", tt.name, want, reasons[i])
+			}
+		}
+	}
+}
+
+func TestCheckNeedsBaseline(t *testing.T) {
+	cfg := config.SanityConfig{MinSizeBytes: 4096, SizeChangePercent: 50}
+	// На первых запусках сравнивать не с чем — проверяется только минимальный размер
+	baseline := []Sample{{Bytes: 10 << 20}, {Bytes: 10 << 20}}
+	if reasons := Check(Sample{Bytes: 1 << 20}, baseline, cfg); len(reasons) != 0 {
+		t.Errorf("Expected no comparison with %d backups, got %q", len(baseline), reasons)
+	}
+	if reasons := Check(Sample{Bytes: 100}, nil, cfg); len(reasons) != 1 {
+		t.Errorf("Expected the minimum size to apply without a baseline, got %q", reasons)
+	}
+}
@@ -0,0 +1,30 @@
+package storage
+
+import (
+	"errors"
+	"io"
+)
+
+// ErrNotExist is returned by Get for keys that are not stored.
+var ErrNotExist = errors.New("object does not exist")
+
+// ErrExist is returned by Create for keys that are already stored.
+var ErrExist = errors.New("object already exists")
+
+// Storage is a flat object store addressed by slash-separated keys. It is
+// what the deduplicating repository writes chunks and indexes to, so new
+// backends only need to provide these operations.
+type Storage interface {
+	Put(key string, r io.Reader) error
+	Get(key string) (io.ReadCloser, error)
+	Exists(key string) (bool, error)
+	Delete(key string) error
+	// List returns the keys starting with prefix, in no particular order.
+	List(prefix string) ([]string, error)
+}
+
+// Creator is implemented by storages that can store an object only if its
+// key is still free, as one atomic step. Lease locks are built on it.
+type Creator interface {
+	Create(key string, r io.Reader) error
+}
@@ -0,0 +1,112 @@
+package throttle
+
+import (
+	"io"
+	"sync"
+	"time"
+
+	"github.com/itocode21/backup-tool/pkg/storage"
+)
+
+// chunk bounds how many bytes pass a limiter at once, so a single large
+// write is spread out instead of sent in a burst after a long pause.
+const chunk = 32 << 10
+
+// Limiter paces byte streams to a rate in bytes per second that may change
+// while they run. Streams sharing a limiter share its rate.
+type Limiter struct {
+	rate func() int64
+
+	mu   sync.Mutex
+	next time.Time
+}
+
+func NewLimiter(rate func() int64) *Limiter {
+	return &Limiter{rate: rate}
+}
+
+// Wait blocks until n more bytes fit into the rate. A nil limiter or a
+// rate of zero never blocks.
+func (l *Limiter) Wait(n int) {
+	if l == nil {
+		return
+	}
+	rate := l.rate()
+	if rate <= 0 {
+		return
+	}
+
+	l.mu.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	l.next = l.next.Add(time.Duration(float64(n) / float64(rate) * float64(time.Second)))
+	wait := l.next.Sub(now)
+	l.mu.Unlock()
+	time.Sleep(wait)
+}
+
+// Writer returns a writer that writes to w no faster than l allows.
+func Writer(w io.Writer, l *Limiter) io.Writer {
+	if l == nil {
+		return w
+	}
+	return &writer{w: w, l: l}
+}
+
+type writer struct {
+	w io.Writer
+	l *Limiter
+}
+
+func (w *writer) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		n := min(len(p), chunk)
+		w.l.Wait(n)
+		m, err := w.w.Write(p[:n])
+		written += m
+		if err != nil {
+			return written, err
+		}
+		p = p[n:]
+	}
+	return written, nil
+}
+
+// Reader returns a reader that reads from r no faster than l allows.
+func Reader(r io.Reader, l *Limiter) io.Reader {
+	if l == nil {
+		return r
+	}
+	return &reader{r: r, l: l}
+}
+
+type reader struct {
+	r io.Reader
+	l *Limiter
+}
+
+func (r *reader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p[:min(len(p), chunk)])
+	r.l.Wait(n)
+	return n, err
+}
+
+// Storage returns s with the data passed to Put limited by l.
+func Storage(s storage.Storage, l *Limiter) storage.Storage {
+	if l == nil {
+		return s
+	}
+	return limitedStorage{Storage: s, l: l}
+}
+
+type limitedStorage struct {
+	storage.Storage
+	l *Limiter
+}
+
+func (s limitedStorage) Put(key string, r io.Reader) error {
+	return s.Storage.Put(key, Reader(r, s.l))
+}
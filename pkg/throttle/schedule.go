@@ -0,0 +1,98 @@
+package throttle
+
+import (
+	"time"
+
+	"github.com/itocode21/backup-tool/pkg/config"
+)
+
+// Schedule tracks which throttle limits are in force at the current time
+// of day. Its limiters follow the schedule, so a profile that starts in
+// the middle of an upload slows the rest of it down.
+type Schedule struct {
+	defaults config.ThrottleLimits
+	windows  []window
+	now      func() time.Time
+
+	upload *Limiter
+	write  *Limiter
+}
+
+// window is a profile with its times as minutes since midnight.
+type window struct {
+	name     string
+	from, to int
+	limits   config.ThrottleLimits
+}
+
+func (w window) contains(minute int) bool {
+	if w.from < w.to {
+		return minute >= w.from && minute < w.to
+	}
+	return minute >= w.from || minute < w.to
+}
+
+// NewSchedule builds the schedule of a validated throttle config.
+func NewSchedule(cfg config.ThrottleConfig) *Schedule {
+	s := &Schedule{defaults: cfg.ThrottleLimits, now: time.Now}
+	uploads, writes := cfg.UploadKBPerSecond > 0, cfg.WriteKBPerSecond > 0
+	for _, profile := range cfg.Profiles {
+		from, _ := time.Parse("15:04", profile.From)
+		to, _ := time.Parse("15:04", profile.To)
+		s.windows = append(s.windows, window{
+			name:   profile.Name,
+			from:   from.Hour()*60 + from.Minute(),
+			to:     to.Hour()*60 + to.Minute(),
+			limits: profile.ThrottleLimits,
+		})
+		uploads = uploads || profile.UploadKBPerSecond > 0
+		writes = writes || profile.WriteKBPerSecond > 0
+	}
+
+	if uploads {
+		s.upload = NewLimiter(func() int64 { return int64(s.Limits().UploadKBPerSecond) << 10 })
+	}
+	if writes {
+		s.write = NewLimiter(func() int64 { return int64(s.Limits().WriteKBPerSecond) << 10 })
+	}
+	return s
+}
+
+// At returns the limits in force at t and the name of their profile,
+// which is empty for the defaults.
+func (s *Schedule) At(t time.Time) (config.ThrottleLimits, string) {
+	minute := t.Hour()*60 + t.Minute()
+	for _, w := range s.windows {
+		if w.contains(minute) {
+			return w.limits, w.name
+		}
+	}
+	return s.defaults, ""
+}
+
+// Limits returns the limits in force now. A nil schedule has none.
+func (s *Schedule) Limits() config.ThrottleLimits {
+	if s == nil {
+		return config.ThrottleLimits{}
+	}
+	limits, _ := s.At(s.now())
+	return limits
+}
+
+// Upload returns the limiter shared by all uploads, or nil if no profile
+// limits them.
+func (s *Schedule) Upload() *Limiter {
+	if s == nil {
+		return nil
+	}
+	return s.upload
+}
+
+// Write returns the limiter shared by all local artifact writes, or nil
+// if no profile limits them.
+func (s *Schedule) Write() *Limiter {
+	if s == nil {
+		return nil
+	}
+	return s.write
+}
@@ -0,0 +1,96 @@
+package throttle
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/itocode21/backup-tool/pkg/config"
+	"github.com/itocode21/backup-tool/pkg/storage"
+)
+
+func TestWriterPacesToRate(t *testing.T) {
+	var out bytes.Buffer
+	limiter := NewLimiter(func() int64 { return 256 << 10 })
+	data := bytes.Repeat([]byte("x"), 64<<10)
+
+	start := time.Now()
+	n, err := Writer(&out, limiter).Write(data)
+	elapsed := time.Since(start)
+	if err != nil || n != len(data) || !bytes.Equal(out.Bytes(), data) {
+		t.Fatalf("Unexpected write: %d, %v", n, err)
+	}
+	// 64 KiB при 256 KiB/s — около четверти секунды
+	if elapsed < 200*time.Millisecond || elapsed > time.Second {
+		t.Errorf("Expected the write to take about 250ms, took %v", elapsed)
+	}
+}
+
+func TestUnlimited(t *testing.T) {
+	var out bytes.Buffer
+	if w := Writer(&out, nil); w != io.Writer(&out) {
+		t.Error("Expected a nil limiter to leave the writer as is")
+	}
+
+	// Нулевая скорость в текущем профиле снимает ограничение
+	limiter := NewLimiter(func() int64 { return 0 })
+	start := time.Now()
+	Writer(&out, limiter).Write(make([]byte, 1<<20))
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("Expected an unlimited write to be immediate, took %v", elapsed)
+	}
+}
+
+func TestStorageLimitsPut(t *testing.T) {
+	local := storage.NewLocal(t.TempDir())
+	s := Storage(local, NewLimiter(func() int64 { return 128 << 10 }))
+
+	start := time.Now()
+	if err := s.Put("chunk", bytes.NewReader(make([]byte, 32<<10))); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+		t.Errorf("Expected Put to be paced, took %v", elapsed)
+	}
+	if ok, _ := s.Exists("chunk"); !ok {
+		t.Error("Expected the chunk to be stored")
+	}
+}
+
+func TestScheduleProfiles(t *testing.T) {
+	s := NewSchedule(config.ThrottleConfig{
+		ThrottleLimits: config.ThrottleLimits{UploadKBPerSecond: 1024},
+		Profiles: []config.ThrottleProfile{
+			{Name: "night", From: "22:00", To: "06:00"},
+			{Name: "day", From: "09:00", To: "18:00", ThrottleLimits: config.ThrottleLimits{UploadKBPerSecond: 256, Nice: 19, IONiceClass: "idle"}},
+		},
+	})
+
+	tests := []struct {
+		clock   string
+		profile string
+		upload  int
+	}{
+		{"23:30", "night", 0},
+		{"05:59", "night", 0},
+		{"06:00", "", 1024},
+		{"12:00", "day", 256},
+		{"18:00", "", 1024},
+	}
+	for _, tt := range tests {
+		at, _ := time.Parse("15:04", tt.clock)
+		limits, profile := s.At(at)
+		if profile != tt.profile || limits.UploadKBPerSecond != tt.upload {
+			t.Errorf("At %s: expected %q with %d KiB/s, got %q with %d KiB/s", tt.clock, tt.profile, tt.upload, profile, limits.UploadKBPerSecond)
+		}
+	}
+
+	if s.Upload() == nil || s.Write() != nil {
+		t.Error("Expected only uploads to be limited")
+	}
+	var none *Schedule
+	if none.Upload() != nil || none.Limits() != (config.ThrottleLimits{}) {
+		t.Error("Expected a nil schedule to limit nothing")
+	}
+}